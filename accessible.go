@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// accessibleMode - флаг --accessible (ключ accessible): вместо полноэкранного
+// fzf - нумерованный список и вопросы построчно, без цветов и превью, а
+// каждое открытие и действие сообщается текстом. Так с fzf-open работают
+// экранные чтецы в терминале.
+var accessibleMode bool
+
+// plainPageSize - сколько строк показывает за раз построчный выбор
+const plainPageSize = 20
+
+// announce сообщает в режиме --accessible, что делает fzf-open: экранный
+// чтец озвучит строку, а запущенное приложение может не показать ничего
+func announce(format string, args ...any) {
+	if accessibleMode {
+		fmt.Fprintf(logOut, format+"\n", args...)
+	}
+}
+
+// announceLaunch сообщает, каким приложением открывается файл
+func announceLaunch(appPath, filePath string) {
+	announce("Opening %s with %s", filePath, filepath.Base(appPath))
+}
+
+// runPlain - построчный выбор (select --plain): печатает совпадения
+// нумерованным списком и читает ответ строкой. Номер выбирает строку (с
+// --multi - несколько номеров через пробел), текст фильтрует список, пустой
+// ввод показывает следующую страницу, q отменяет. Возвращает false, если
+// выбор отменён.
+func (s *selector) runPlain(tty io.ReadWriter, printQuery bool) bool {
+	in := bufio.NewReader(tty)
+	page := 0
+	for {
+		s.printPlainPage(tty, page)
+		fmt.Fprintf(tty, "Type a number to pick, text to filter, nothing for more, q to cancel.\n%s", s.prompt)
+		answer, err := in.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if err != nil && answer == "" {
+			fmt.Fprintln(tty)
+			return false
+		}
+
+		switch {
+		case answer == "":
+			if (page+1)*plainPageSize < len(s.matches) {
+				page++
+			} else {
+				page = 0
+			}
+			continue
+		case answer == "q" || answer == "Q":
+			return false
+		}
+
+		if picks, ok := plainNumbers(answer); ok {
+			if n := slices.IndexFunc(picks, func(n int) bool { return n < 1 || n > len(s.matches) }); n >= 0 {
+				fmt.Fprintf(tty, "There is no item %d.\n", picks[n])
+				continue
+			}
+			if len(picks) > 1 && !s.multi {
+				fmt.Fprintln(tty, "Pick one number.")
+				continue
+			}
+			for _, n := range picks {
+				if s.multi {
+					s.marked[s.matches[n-1]] = true
+				} else {
+					s.cursor = n - 1
+				}
+			}
+			return true
+		}
+
+		// "/2024" ищет текст из одних цифр
+		s.query = []rune(strings.TrimPrefix(answer, "/"))
+		s.filter()
+		page = 0
+		if len(s.matches) == 0 {
+			fmt.Fprintf(tty, "No matches for %q.\n", string(s.query))
+			if printQuery {
+				// Как Enter в fzf без совпадений: вызывающему нужен сам запрос
+				return true
+			}
+		}
+	}
+}
+
+// printPlainPage печатает заголовок, число совпадений и страницу page списка
+func (s *selector) printPlainPage(w io.Writer, page int) {
+	if page == 0 {
+		for _, line := range s.header {
+			fmt.Fprintln(w, line)
+		}
+	}
+	if len(s.matches) == 0 {
+		return
+	}
+	start := page * plainPageSize
+	end := min(start+plainPageSize, len(s.matches))
+	noun := "items"
+	if len(s.query) > 0 {
+		noun = fmt.Sprintf("matches for %q", string(s.query))
+	}
+	if len(s.matches) == 1 {
+		noun = strings.Replace(strings.Replace(noun, "items", "item", 1), "matches", "match", 1)
+	}
+	fmt.Fprintf(w, "%d %s, showing %d to %d:\n", len(s.matches), noun, start+1, end)
+	for i := start; i < end; i++ {
+		fmt.Fprintf(w, "%d. %s\n", i+1, s.items[s.matches[i]])
+	}
+}
+
+// plainNumbers разбирает ответ из номеров строк через пробел или запятую.
+// ok == false - это не номера, а текст для фильтра.
+func plainNumbers(answer string) ([]int, bool) {
+	fields := strings.FieldsFunc(answer, func(r rune) bool { return r == ' ' || r == ',' })
+	var picks []int
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, false
+		}
+		picks = append(picks, n)
+	}
+	return picks, len(picks) > 0
+}
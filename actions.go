@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ActionConfig - пользовательское действие из таблицы [actions.<имя>]
+type ActionConfig struct {
+	// Key - клавиша fzf, которая запускает действие для выбранных файлов
+	Key string `toml:"key,omitempty"`
+	// Command - команда шелла; {file}, {files}, {dir} и {name} заменяются
+	// путями в кавычках
+	Command string `toml:"command"`
+	// Multi - выполнять команду один раз для всех выбранных файлов, а не для каждого
+	Multi bool `toml:"multi,omitempty"`
+	// Confirm - спрашивать подтверждение перед запуском
+	Confirm bool `toml:"confirm,omitempty"`
+
+	// Output - расширение файла, который команда записывает в {out}; такое
+	// действие может быть шагом конвейера
+	Output string `toml:"output,omitempty"`
+	// Pipeline - шаги конвейера: имена других действий или "open"
+	Pipeline []string `toml:"pipeline,omitempty"`
+}
+
+// userActions - действия из конфигурации по именам
+var userActions = map[string]ActionConfig{}
+
+// actionMenuPrefix отличает действия от встроенных пунктов меню
+const actionMenuPrefix = "Action: "
+
+// builtinAction возвращает встроенное действие с именем name, если нужные ему
+// программы установлены. Клавиша встроенному действию назначается в таблице
+// [actions.<имя>] без command; действие с command заменяет встроенное.
+func builtinAction(name string) (func(context.Context, []string) error, bool) {
+	switch name {
+	case actionOCR:
+		return runOCR, ocrAvailable()
+	}
+	return nil, false
+}
+
+// builtinActionNames - имена встроенных действий
+var builtinActionNames = []string{actionOCR}
+
+// isCustom сообщает, задана ли у действия своя команда или конвейер
+func (a ActionConfig) isCustom() bool {
+	return a.Command != "" || len(a.Pipeline) > 0
+}
+
+// actionNames возвращает имена действий в алфавитном порядке. В режиме --safe
+// действий нет: их команды выполняет шелл; в режиме киоска они отключены.
+func actionNames() []string {
+	if safeMode || kioskMode {
+		return nil
+	}
+	names := make([]string, 0, len(userActions)+len(builtinActionNames))
+	for name, action := range userActions {
+		if action.isCustom() {
+			names = append(names, name)
+		}
+	}
+	for _, name := range builtinActionNames {
+		if _, ok := builtinAction(name); ok && !userActions[name].isCustom() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// actionForKey возвращает имя действия, привязанного к клавише fzf в [keys]
+// или ключом key действия
+func actionForKey(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	if name, ok := keyBindings[normalizeKey(key)]; ok && name != "" {
+		return name, true
+	}
+	for _, name := range actionNames() {
+		if userActions[name].Key == key {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// addActionKeys добавляет клавиши действий в параметры fzf и описывает их в заголовке
+func addActionKeys(opts *pickerOptions) {
+	// Построчный выбор --accessible не ловит клавиши; действия доступны в
+	// меню после ошибки открытия
+	if accessibleMode {
+		return
+	}
+	var hints []string
+	for _, name := range actionNames() {
+		action := userActions[name]
+		if action.Key == "" {
+			continue
+		}
+		opts.Expect = append(opts.Expect, action.Key)
+		hints = append(hints, action.Key+": "+name)
+		if action.Multi {
+			opts.Multi = true
+		}
+	}
+	keys := make([]string, 0, len(keyBindings))
+	for key := range keyBindings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := keyBindings[key]
+		if name == "" {
+			continue
+		}
+		if _, builtin := keyCommands[name]; safeMode && !builtin && !isLayoutAction(name) {
+			continue
+		}
+		if kioskMode && !kioskKeyCommands[name] && !isLayoutAction(name) {
+			continue
+		}
+		opts.Expect = append(opts.Expect, key)
+		hints = append(hints, key+": "+name)
+		if isLayoutAction(name) {
+			opts.Multi = true
+		}
+	}
+	if len(hints) == 0 {
+		return
+	}
+
+	header := strings.Join(hints, "  ")
+	if opts.Header != "" {
+		header = opts.Header + " │ " + header
+	}
+	opts.Header = header
+}
+
+// runAction выполняет действие name для файлов. Команда запускается в текущем
+// терминале в каталоге первого файла.
+func runAction(ctx context.Context, name string, files []string) error {
+	if layout, ok := strings.CutPrefix(name, layoutActionPrefix); ok {
+		return openLayout(ctx, layout, files)
+	}
+	action := userActions[name]
+	if command, ok := keyCommands[name]; ok && !action.isCustom() {
+		return command(ctx, files)
+	}
+	builtin, available := builtinAction(name)
+	if !action.isCustom() && !available {
+		return fmt.Errorf("unknown action %q", name)
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	if action.Confirm && !confirmAction(name, files) {
+		return nil
+	}
+	announce("Running %s on %s", name, strings.Join(files, ", "))
+
+	if !action.isCustom() {
+		return builtin(ctx, files)
+	}
+
+	if len(action.Pipeline) > 0 {
+		for _, file := range files {
+			if err := runPipeline(ctx, name, action.Pipeline, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	batches := [][]string{files}
+	if !action.Multi {
+		batches = batches[:0]
+		for _, file := range files {
+			batches = append(batches, []string{file})
+		}
+	}
+
+	for _, batch := range batches {
+		if err := runActionCommand(action.Command, batch, ""); err != nil {
+			return fmt.Errorf("action %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// runActionCommand выполняет команду действия в текущем терминале в каталоге
+// первого файла
+func runActionCommand(command string, files []string, out string) error {
+	argv := shellArgv(expandActionCommand(command, files, out))
+	cmd := execCommand(context.Background(), "action", argv[0], argv[1:]...)
+	cmd.Dir = filepath.Dir(files[0])
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// confirmAction спрашивает подтверждение через меню fzf
+func confirmAction(name string, files []string) bool {
+	if !isInteractive() {
+		fmt.Fprintf(logOut, "Warning: action %q needs confirmation, but there is no terminal\n", name)
+		return false
+	}
+
+	target := filepath.Base(files[0])
+	if len(files) > 1 {
+		target = fmt.Sprintf("%d files", len(files))
+	}
+	choice, err := fzfMenu(fmt.Sprintf("Run %s on %s?> ", name, target), false, "Run", menuCancel)
+	return err == nil && choice == "Run"
+}
+
+// expandActionCommand подставляет пути в шаблон команды действия; out - файл
+// результата шага конвейера или конвертера
+func expandActionCommand(command string, files []string, out string) string {
+	quoted := make([]string, len(files))
+	for i, file := range files {
+		quoted[i] = shellQuote(file)
+	}
+
+	return strings.NewReplacer(
+		"{file}", quoted[0],
+		"{files}", strings.Join(quoted, " "),
+		"{dir}", shellQuote(filepath.Dir(files[0])),
+		"{name}", shellQuote(filepath.Base(files[0])),
+		"{outdir}", shellQuote(filepath.Dir(out)),
+		"{out}", shellQuote(out),
+	).Replace(command)
+}
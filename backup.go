@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat - метка времени в имени резервной копии
+const backupTimeFormat = "20060102-150405"
+
+// backupRoot возвращает каталог резервных копий: backup_dir или
+// $XDG_STATE_HOME/fzf-open/backups
+func backupRoot() (string, error) {
+	if defaultConfig.BackupDir != "" {
+		return expandPath(defaultConfig.BackupDir)
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups"), nil
+}
+
+// backupFile копирует файл перед открытием по правилу с backup = true. Копия
+// лежит в каталоге резервных копий по полному пути оригинала, а в имя перед
+// расширением добавляется время: notes.20240501-093000.md. Возвращает путь копии.
+func backupFile(filePath string) (string, error) {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+	root, err := backupRoot()
+	if err != nil {
+		return "", err
+	}
+
+	// Буква диска Windows не может быть частью пути внутри каталога
+	rel := strings.TrimPrefix(filepath.VolumeName(abs), `\\`)
+	rel = filepath.Join(strings.TrimSuffix(rel, ":"), strings.TrimPrefix(abs, filepath.VolumeName(abs)))
+	dir := filepath.Join(root, filepath.Dir(rel))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	name := filepath.Base(abs)
+	ext := filepath.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	out := filepath.Join(dir, fmt.Sprintf("%s.%s%s", strings.TrimSuffix(name, ext), time.Now().Format(backupTimeFormat), ext))
+
+	src, err := os.Open(abs)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+	dst, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm()|0o600)
+	if errors.Is(err, fs.ErrExist) {
+		// Файл открывают второй раз за секунду - копия уже сделана
+		return out, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out)
+		return "", err
+	}
+	os.Chtimes(out, fi.ModTime(), fi.ModTime())
+	return out, nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// benchRunTimeout ограничивает один прогон bench: обход огромного дерева не
+// должен подвешивать всё сравнение
+const benchRunTimeout = 2 * time.Minute
+
+// benchCase - один вариант сравнения: источник списка или программа выбора с
+// настройками. run выполняет прогон и возвращает число строк вывода.
+type benchCase struct {
+	name      string
+	settings  string
+	hint      string // строка конфигурации, которая выбирает этот вариант
+	gitignore bool   // список учитывает .gitignore
+	run       func(ctx context.Context) (int, error)
+}
+
+// benchResult - замеры варианта: первый прогон и медиана повторных
+type benchResult struct {
+	benchCase
+	count       int
+	first, warm time.Duration
+	err         error
+}
+
+// runBench реализует подкоманду bench: замеряет источники списка и программы
+// выбора на настоящем дереве (по умолчанию домашнем каталоге) и печатает
+// таблицу для выбора source, no_ignore и finder. Первый прогон читает диск,
+// если дерево ещё не в кэше, повторные показывают работу с тёплым кэшем.
+func runBench(args []string) int {
+	fset := flag.NewFlagSet("bench", flag.ExitOnError)
+	dir := fset.String("d", "", "Directory to list (default: home directory)")
+	runs := fset.Int("n", 3, "Warm runs per case")
+	query := fset.String("q", "conf", "Query for the finder runs")
+	profile := fset.String("p", "", "Configuration profile")
+	fset.Parse(args)
+
+	if err := loadConfig(*profile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	root := *dir
+	if root == "" {
+		home, err := resolveHomeDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		root = home
+	}
+	root, err := expandPath(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		fmt.Fprintf(os.Stderr, "Error: %q is not a directory\n", root)
+		return 1
+	}
+	*runs = max(*runs, 1)
+
+	fmt.Printf("Benchmark in %s: first run and median of %d warm runs\n\n", root, *runs)
+	fmt.Printf("%-10s %-16s %9s %9s %9s\n", "LIST", "SETTINGS", "FILES", "FIRST", "WARM")
+	sources := measureBench(benchSourceCases(root), *runs)
+
+	// Программы выбора фильтруют один и тот же список встроенного обходчика
+	var list bytes.Buffer
+	opts := walkOptions{hidden: defaultConfig.Hidden, noIgnore: defaultConfig.NoIgnore, exclude: ignorePatterns}
+	walkFiles(root, opts, func(rel string) {
+		list.WriteString(rel)
+		list.WriteByte('\n')
+	})
+	fmt.Printf("\n%-10s %-16s %9s %9s %9s\n", "FINDER", "QUERY", "MATCHES", "FIRST", "WARM")
+	finders := measureBench(benchFinderCases(list.Bytes(), *query), *runs)
+
+	fmt.Println()
+	// Списки с .gitignore и без него короче и длиннее, сравнивать их нечестно
+	sources = slices.DeleteFunc(sources, func(r benchResult) bool { return r.gitignore == defaultConfig.NoIgnore })
+	if best, ok := fastestBench(sources); ok {
+		fmt.Printf("Fastest list with the current no_ignore: %s (%s) - %s\n", best.name, best.settings, best.hint)
+	}
+	if best, ok := fastestBench(finders); ok {
+		fmt.Printf("Fastest finder: %s - %s\n", best.name, best.hint)
+	}
+	fmt.Println("The first run is cold only if the tree is not in the page cache yet (for example, right after a reboot).")
+	return 0
+}
+
+// benchSourceCases возвращает источники списка, доступные на этой машине: с
+// учётом .gitignore и без него
+func benchSourceCases(root string) []benchCase {
+	var cases []benchCase
+	for _, noIgnore := range []bool{false, true} {
+		settings, hint := ".gitignore", ""
+		if noIgnore {
+			settings, hint = "no-ignore", ", no_ignore = true"
+		}
+		if hasPosixShell() {
+			if fdProgram() != "" {
+				cases = append(cases, benchCase{name: sourceFd, settings: settings, hint: `source = "fd"` + hint, gitignore: !noIgnore,
+					run: benchShell(root, presetSourceCommand(sourceFd, noIgnore))})
+			}
+			if _, err := cachedLookPath("rg"); err == nil {
+				cases = append(cases, benchCase{name: sourceRg, settings: settings, hint: `source = "rg"` + hint, gitignore: !noIgnore,
+					run: benchShell(root, presetSourceCommand(sourceRg, noIgnore))})
+			}
+		}
+		opts := walkOptions{hidden: defaultConfig.Hidden, noIgnore: noIgnore, exclude: ignorePatterns}
+		cases = append(cases, benchCase{name: sourceWalk, settings: settings, hint: `source = "walk"` + hint, gitignore: !noIgnore,
+			run: func(ctx context.Context) (int, error) {
+				n := 0
+				err := walkFiles(root, opts, func(string) { n++ })
+				return n, err
+			}})
+	}
+
+	// find и обходчик fzf не читают .gitignore
+	if hasPosixShell() {
+		if _, err := cachedLookPath("find"); err == nil {
+			cases = append(cases, benchCase{name: sourceFind, settings: "all files", hint: `source = "find"`,
+				run: benchShell(root, findSourceCommand(ignorePatterns, defaultConfig.Hidden))})
+		}
+	}
+	if _, err := cachedLookPath(finderFzf); err == nil {
+		walker := "file,follow"
+		if defaultConfig.Hidden {
+			walker += ",hidden"
+		}
+		cases = append(cases, benchCase{name: "fzf", settings: "walker", hint: "source unset (fzf walks the tree itself)",
+			run: benchCommand(root, nil, finderFzf, "--filter=", "--walker="+walker)})
+	}
+	return cases
+}
+
+// benchFinderCases возвращает программы выбора, которые умеют фильтровать
+// список без экрана: fzf и skim (--filter), fzy (--show-matches) и встроенный
+// выбор. У peco такого режима нет.
+func benchFinderCases(list []byte, query string) []benchCase {
+	var cases []benchCase
+	commands := []struct {
+		name string
+		args []string
+	}{
+		{finderFzf, []string{"--filter=" + query}},
+		{finderSkim, []string{"--filter=" + query}},
+		{finderFzy, []string{"--show-matches=" + query}},
+	}
+	for _, c := range commands {
+		if _, err := cachedLookPath(c.name); err != nil {
+			continue
+		}
+		cases = append(cases, benchCase{name: c.name, settings: query, hint: fmt.Sprintf("finder = %q", c.name),
+			run: benchCommand("", list, c.name, c.args...)})
+	}
+	items := strings.Split(strings.TrimSuffix(string(list), "\n"), "\n")
+	cases = append(cases, benchCase{name: finderBuiltin, settings: query, hint: fmt.Sprintf("finder = %q", finderBuiltin),
+		run: func(context.Context) (int, error) {
+			s := &selector{items: items, query: []rune(query)}
+			s.filter()
+			return len(s.matches), nil
+		}})
+	return cases
+}
+
+// benchShell возвращает прогон команды списка через шелл в каталоге root
+func benchShell(root, command string) func(context.Context) (int, error) {
+	argv := shellArgv(command)
+	return benchCommand(root, nil, argv[0], argv[1:]...)
+}
+
+// benchCommand возвращает прогон программы в каталоге dir со стандартным
+// вводом stdin; результат - число строк вывода (разделённых переводом строки
+// или NUL). Код 1 у программ выбора означает "нет совпадений" и ошибкой не считается.
+func benchCommand(dir string, stdin []byte, name string, args ...string) func(context.Context) (int, error) {
+	return func(ctx context.Context) (int, error) {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Dir = dir
+		// Обходчик fzf работает, только если команда списка не задана
+		cmd.Env = slices.DeleteFunc(os.Environ(), func(kv string) bool {
+			return strings.HasPrefix(kv, "FZF_DEFAULT_COMMAND=") || strings.HasPrefix(kv, "SKIM_DEFAULT_COMMAND=")
+		})
+		if stdin != nil {
+			cmd.Stdin = bytes.NewReader(stdin)
+		}
+		var counter benchLineCounter
+		cmd.Stdout = &counter
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && stdin != nil {
+			err = nil
+		}
+		return int(counter), err
+	}
+}
+
+// benchLineCounter считает строки вывода, не сохраняя его
+type benchLineCounter int
+
+func (c *benchLineCounter) Write(p []byte) (int, error) {
+	*c += benchLineCounter(bytes.Count(p, []byte{'\n'}) + bytes.Count(p, []byte{0}))
+	return len(p), nil
+}
+
+// measureBench прогоняет каждый вариант один раз и ещё runs раз, печатая
+// строку таблицы сразу после замера
+func measureBench(cases []benchCase, runs int) []benchResult {
+	var results []benchResult
+	for _, c := range cases {
+		r := benchResult{benchCase: c}
+		var warm []time.Duration
+		for i := 0; i <= runs && r.err == nil; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), benchRunTimeout)
+			start := time.Now()
+			count, err := c.run(ctx)
+			elapsed := time.Since(start)
+			if ctx.Err() != nil {
+				err = fmt.Errorf("timed out after %v", benchRunTimeout)
+			}
+			cancel()
+			if i == 0 {
+				r.first, r.count, r.err = elapsed, count, err
+			} else {
+				warm = append(warm, elapsed)
+				r.err = err
+			}
+		}
+		if r.err != nil {
+			fmt.Printf("%-10s %-16s error: %v\n", c.name, c.settings, r.err)
+		} else {
+			slices.Sort(warm)
+			r.warm = warm[len(warm)/2]
+			fmt.Printf("%-10s %-16s %9d %9s %9s\n", c.name, c.settings, r.count, formatBench(r.first), formatBench(r.warm))
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// formatBench печатает время с тремя-четырьмя значащими цифрами
+func formatBench(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(10 * time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// fastestBench возвращает вариант с наименьшим тёплым временем
+func fastestBench(results []benchResult) (benchResult, bool) {
+	var best benchResult
+	found := false
+	for _, r := range results {
+		if r.err == nil && (!found || r.warm < best.warm) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// pipeDrainTimeout - сколько после выхода шелла дочитывать канал. Дескриптор
+// могла унаследовать фоновая программа из rc-файла, и конца данных тогда не будет.
+const pipeDrainTimeout = 500 * time.Millisecond
+
+// outputCapture принимает то, что шелл с fzf пишет в перенаправление: выбор
+// или каталог fzf. По возможности это анонимный канал, который шелл получает
+// дескриптором fd, и на диске ничего не остаётся. Терминал, открытый флагом
+// -n, дескрипторы не передаёт - тогда это временный файл со случайным именем,
+// созданный только для владельца.
+type outputCapture struct {
+	fd   int
+	w    *os.File
+	r    *os.File
+	done chan []byte
+
+	file string
+}
+
+// newPipeCapture создаёт канал, который дочерний процесс получит дескриптором
+// fd; ExtraFiles с первого элемента соответствуют дескрипторам с 3
+func newPipeCapture(fd int) (*outputCapture, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	c := &outputCapture{fd: fd, r: r, w: w, done: make(chan []byte, 1)}
+	go func() {
+		data, _ := io.ReadAll(r)
+		c.done <- data
+	}()
+	return c, nil
+}
+
+// newFileCapture создаёт временный файл в dir
+func newFileCapture(dir, pattern string) (*outputCapture, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return &outputCapture{file: f.Name()}, nil
+}
+
+// redirect возвращает перенаправление шелла в этот приёмник
+func (c *outputCapture) redirect() string {
+	if c.file != "" {
+		return "> " + shellQuote(c.file)
+	}
+	return ">&" + strconv.Itoa(c.fd)
+}
+
+// bytes возвращает записанное. Вызывается после завершения шелла; канал
+// дочитывается не дольше, чем до deadline.
+func (c *outputCapture) bytes(deadline time.Time) []byte {
+	if c.file != "" {
+		data, _ := os.ReadFile(c.file)
+		return data
+	}
+
+	// Копия дескриптора осталась только у дочерних процессов
+	c.w.Close()
+	select {
+	case data := <-c.done:
+		return data
+	case <-time.After(time.Until(deadline)):
+		c.r.Close()
+		return <-c.done
+	}
+}
+
+// close освобождает канал или удаляет файл
+func (c *outputCapture) close() {
+	if c.file != "" {
+		os.Remove(c.file)
+		return
+	}
+	c.w.Close()
+	c.r.Close()
+}
+
+// newPickerCaptures создаёт приёмники выбора fzf и его каталога: каналы с
+// дескрипторами 3 и 4 или, если files, временные файлы в runtimeDir
+func newPickerCaptures(files bool) (output, cwd *outputCapture, err error) {
+	if files {
+		dir, err := runtimeDir()
+		if err != nil {
+			return nil, nil, err
+		}
+		if output, err = newFileCapture(dir, "selection-*"); err != nil {
+			return nil, nil, err
+		}
+		if cwd, err = newFileCapture(dir, "cwd-*"); err != nil {
+			output.close()
+			return nil, nil, err
+		}
+		return output, cwd, nil
+	}
+
+	if output, err = newPipeCapture(3); err != nil {
+		return nil, nil, err
+	}
+	if cwd, err = newPipeCapture(4); err != nil {
+		output.close()
+		return nil, nil, err
+	}
+	return output, cwd, nil
+}
+
+// pickerExtraFiles возвращает концы каналов для exec.Cmd.ExtraFiles
+func pickerExtraFiles(captures ...*outputCapture) []*os.File {
+	var files []*os.File
+	for _, c := range captures {
+		if c.w != nil {
+			files = append(files, c.w)
+		}
+	}
+	return files
+}
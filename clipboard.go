@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+)
+
+// clipboardCommands - программы для записи в буфер обмена в порядке предпочтения
+var clipboardCommands = [][]string{
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"pbcopy"},
+	{"clip"},
+	{"termux-clipboard-set"},
+}
+
+// copyToClipboard помещает текст в системный буфер обмена
+func copyToClipboard(text string) error {
+	for _, candidate := range clipboardCommands {
+		if candidate[0] == "wl-copy" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			continue
+		}
+
+		path, err := cachedLookPath(candidate[0])
+		if err != nil {
+			continue
+		}
+
+		cmd := execCommand(context.Background(), "clipboard", path, candidate[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return errors.New("no clipboard tool found (install wl-clipboard, xclip or xsel)")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// coalesceWindow - повторное открытие того же пути в пределах этого окна
+// (двойной Enter, дребезг клавиши) объединяется с первым
+const coalesceWindow = time.Second
+
+// coalesceDirName - подкаталог с метками недавних запусков
+const coalesceDirName = "launches"
+
+// claimLaunch захватывает запуск по ключу key (путь файла или запрос к
+// серверу). false - тот же запуск был менее coalesceWindow назад, в том
+// числе в другом процессе fzf-open. Метка на ключ создаётся через O_EXCL,
+// поэтому из двух одновременных вызовов запуск достаётся только одному.
+// release снимает метку, если запуск не удался, чтобы повтор не отбрасывался.
+func claimLaunch(key string) (release func(), claimed bool) {
+	release = func() {}
+	dir, err := coalesceDir()
+	if err != nil {
+		return release, true
+	}
+	if err := ensurePrivateDir(dir); err != nil {
+		return release, true
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	marker := filepath.Join(dir, hex.EncodeToString(sum[:16]))
+	now := time.Now()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(marker, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			pruneLaunchMarkers(dir, now)
+			return func() { os.Remove(marker) }, true
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return release, true
+		}
+
+		fi, err := os.Stat(marker)
+		if err != nil {
+			continue
+		}
+		if now.Sub(fi.ModTime()) < coalesceWindow {
+			return release, false
+		}
+		os.Remove(marker)
+	}
+
+	// Метку пересоздал параллельный процесс - он и выполнит запуск
+	return release, false
+}
+
+// coalesceDir возвращает каталог меток недавних запусков
+func coalesceDir() (string, error) {
+	dir, err := runtimeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, coalesceDirName), nil
+}
+
+// pruneLaunchMarkers удаляет устаревшие метки, чтобы каталог не разрастался
+func pruneLaunchMarkers(dir string, now time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) > coalesceWindow {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
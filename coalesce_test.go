@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClaimLaunch(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	release, claimed := claimLaunch("/tmp/a.txt")
+	if !claimed {
+		t.Fatal("first claim was refused")
+	}
+	if _, claimed := claimLaunch("/tmp/a.txt"); claimed {
+		t.Fatal("repeated claim within coalesceWindow was granted")
+	}
+	if _, claimed := claimLaunch("/tmp/b.txt"); !claimed {
+		t.Fatal("claim for another key was refused")
+	}
+
+	release()
+	if _, claimed := claimLaunch("/tmp/a.txt"); !claimed {
+		t.Fatal("claim after release was refused")
+	}
+}
+
+func TestOpenPathReleasesFailedLaunch(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	// Пустой каталог заглушек: ни одно приложение не найдётся
+	old := fakeExecDir
+	fakeExecDir = t.TempDir()
+	t.Cleanup(func() { fakeExecDir = old })
+
+	dir := t.TempDir()
+	if err := openPath(context.Background(), dir, false); err == nil {
+		t.Fatal("openPath() succeeded without any application")
+	}
+	if _, claimed := claimLaunch(dir); !claimed {
+		t.Error("a failed launch still suppresses the next attempt")
+	}
+}
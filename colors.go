@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Цвета встроенной палитры по категориям файлов (параметры SGR, как в LS_COLORS)
+const (
+	colorDir     = "1;34"
+	colorCode    = "36"
+	colorMedia   = "35"
+	colorDocs    = "33"
+	colorArchive = "31"
+	colorParent  = "2" // каталог в пути перед именем файла
+)
+
+// categoryColors - цвет категории файла по ключу ассоциации его расширения
+var categoryColors = map[string]string{
+	assocTextEditor:        colorCode,
+	assocWebBrowser:        colorCode,
+	assocImageViewer:       colorMedia,
+	assocVideoPlayer:       colorMedia,
+	assocFontViewer:        colorMedia,
+	assocPDFViewer:         colorDocs,
+	assocDocxViewer:        colorDocs,
+	assocSpreadsheetEditor: colorDocs,
+	assocEbookReader:       colorDocs,
+	assocArchiveManager:    colorArchive,
+	assocTorrentClient:     colorArchive,
+}
+
+// candidateColors сообщает, что строки списка раскрашиваются, как в ls
+// (ключ colors). Цвета понимают fzf и skim с --ansi; строки --grep -
+// не пути, а список сервера перезагружают клиенты без цветов.
+func candidateColors() bool {
+	return defaultConfig.Colors && currentFinder().fzfFlags() && !grepMode && !serverMode && !serverClient
+}
+
+// addListDecoration включает в fzf разбор цветов, если список раскрашен, и
+// отмечает, что из выбора нужно убрать значки и столбцы --details
+func addListDecoration(opts *pickerOptions) {
+	opts.Ansi = candidateColors()
+	opts.Icons = candidateIcons()
+	opts.Details = candidateDetails()
+}
+
+// listDecorator оформляет строки списка для программы выбора: раскрашивает
+// их и ставит впереди значки
+type listDecorator struct {
+	colors  lsColors
+	colored bool
+	icons   bool
+	details bool   // столбцы размера и времени изменения (--details)
+	dirs    bool   // все строки - каталоги (список -D)
+	base    string // каталог, от которого отсчитаны пути строк
+}
+
+// newListDecorator возвращает оформление по ключам colors и icons
+func newListDecorator() listDecorator {
+	d := listDecorator{colored: candidateColors(), icons: candidateIcons(), details: candidateDetails(), dirs: dirsMode}
+	if d.colored {
+		d.colors = readLSColors()
+	}
+	return d
+}
+
+// enabled сообщает, что строки списка чем-то оформляются
+func (d listDecorator) enabled() bool {
+	return d.colored || d.icons || d.details
+}
+
+// decorate возвращает оформленную строку списка
+func (d listDecorator) decorate(line string) string {
+	decorated := line
+	file := filepath.Join(d.base, line)
+	if d.colored {
+		decorated = d.colors.colorLine(line, file, d.dirs)
+	}
+	if d.icons {
+		decorated = fileIcon(line, d.dirs) + " " + decorated
+	}
+	if d.details {
+		decorated = fileDetails(file) + decorated
+	}
+	return decorated
+}
+
+// decoratedCommand пропускает список файлов через подкоманду colorize, если
+// включены ключи colors или icons или флаг --details. Конвейер выполняет sh,
+// какой бы ни была оболочка пользователя.
+func decoratedCommand(source string) string {
+	d := listDecorator{colored: candidateColors(), icons: candidateIcons(), details: candidateDetails()}
+	if !d.enabled() || source == "" {
+		return source
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return source
+	}
+	colorize := []string{exe, "colorize"}
+	if nulSeparated() {
+		colorize = append(colorize, "-0")
+	}
+	if dirsMode {
+		colorize = append(colorize, "-d")
+	}
+	if d.icons {
+		colorize = append(colorize, "-i")
+	}
+	if d.details {
+		colorize = append(colorize, "-l")
+	}
+	if !d.colored {
+		colorize = append(colorize, "-n")
+	}
+	return shCommand("(" + source + ") | " + quoteCommand(colorize))
+}
+
+// categoryColor возвращает цвет имени файла по категории его расширения из
+// встроенной палитры; пусто - без цвета
+func categoryColor(name string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if ext == "" {
+		return ""
+	}
+	if key := associationForExt(ext); key != "" {
+		return categoryColors[key]
+	}
+	// Расширения вне таблиц ассоциаций - по MIME-типу
+	switch mimeType := builtinExtensionMIME("." + ext); {
+	case strings.HasPrefix(mimeType, mimeImagePrefix), strings.HasPrefix(mimeType, mimeVideoPrefix):
+		return colorMedia
+	case strings.HasPrefix(mimeType, mimeTextPrefix):
+		return colorCode
+	}
+	return ""
+}
+
+// associationForExt возвращает ключ ассоциации, в таблице расширений которого
+// есть ext; пусто - расширение ни в одну не входит
+func associationForExt(ext string) string {
+	tables := []struct {
+		key  string
+		exts map[string]struct{}
+	}{
+		{assocPDFViewer, extToPDFViewer},
+		{assocDocxViewer, extToDocxViewer},
+		{assocImageViewer, extToImageViewer},
+		{assocVideoPlayer, extToVideoPlayer},
+		{assocSpreadsheetEditor, extToSpreadsheet},
+		{assocWebBrowser, extToWebBrowser},
+		{assocEbookReader, extToEbookReader},
+		{assocFontViewer, extToFontViewer},
+		{assocArchiveManager, extToArchiveManager},
+		{assocTorrentClient, extToTorrentClient},
+		{assocTextEditor, extToTextEditor},
+	}
+	for _, table := range tables {
+		if _, ok := table.exts[ext]; ok {
+			return table.key
+		}
+	}
+	return ""
+}
+
+// colorLine раскрашивает строку списка: каталог в пути приглушён, а имя
+// окрашено как в ls или, без LS_COLORS, по категории файла. file - путь
+// строки на диске, dir - строка заведомо каталог (список -D).
+func (c lsColors) colorLine(line, file string, dir bool) string {
+	parent, name := "", line
+	if i := strings.LastIndex(strings.TrimSuffix(line, "/"), "/"); i >= 0 {
+		parent, name = line[:i+1], line[i+1:]
+	}
+
+	var code string
+	switch {
+	case dir || strings.HasSuffix(line, "/"):
+		code = c.keys["di"]
+	case c.fromLS:
+		code = c.pathColor(file, name)
+	default:
+		code = categoryColor(name)
+	}
+
+	var sb strings.Builder
+	if parent != "" {
+		sb.WriteString("\033[" + colorParent + "m" + parent + "\033[0m")
+	}
+	if code == "" {
+		sb.WriteString(name)
+	} else {
+		sb.WriteString("\033[" + code + "m" + name + "\033[0m")
+	}
+	return sb.String()
+}
+
+// runColorize реализует подкоманду colorize: печатает строки стандартного
+// ввода, раскрашенные для fzf --ansi. С -0 строки разделяет NUL, с -d все
+// строки - каталоги, -i ставит перед строками значки Nerd Fonts, -l - столбцы
+// размера и времени изменения, а -n оставляет их без цветов.
+func runColorize(args []string) int {
+	sep := byte('\n')
+	d := listDecorator{colored: true}
+	for _, arg := range args {
+		switch arg {
+		case "-0":
+			sep = 0
+		case "-d":
+			d.dirs = true
+		case "-i":
+			d.icons = true
+		case "-l":
+			d.details = true
+		case "-n":
+			d.colored = false
+		default:
+			fmt.Fprintln(os.Stderr, "usage: fzf-open colorize [-0] [-d] [-i] [-l] [-n] < paths")
+			return 2
+		}
+	}
+
+	if d.colored {
+		d.colors = readLSColors()
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	in := bufio.NewReader(os.Stdin)
+	for {
+		line, err := in.ReadString(sep)
+		if line = strings.TrimSuffix(line, string(sep)); line != "" {
+			out.WriteString(d.decorate(line))
+			out.WriteByte(sep)
+		}
+		// Строки, пришедшие сразу (файлы из базы frecency), fzf видит до конца обхода
+		if in.Buffered() == 0 {
+			out.Flush()
+		}
+		if err == io.EOF {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecoratedCommandUnderFish(t *testing.T) {
+	dir := t.TempDir()
+	// fzf_command не по умолчанию: fzf-open не проверяет, установлен ли fzf
+	useConfig(t, writeConfig(t, dir, "config.toml", "icons = true\nfzf_command = \"fzf --no-sort\"\nsource = \"echo b.txt; echo a.txt\"\n"))
+	if err := loadConfig(""); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL", "/usr/bin/fish")
+	t.Setenv("FZF_OPEN_TEST_MAIN", "1")
+
+	command := decoratedCommand(defaultConfig.Source)
+	if !strings.HasPrefix(command, quoteCommand([]string{"sh", "-c"})+" ") {
+		t.Fatalf("decoratedCommand() = %q, want a sh -c command", command)
+	}
+	want := fileIcon("b.txt", false) + " b.txt\n" + fileIcon("a.txt", false) + " a.txt\n"
+	for shell, got := range runListCommand(t, dir, command, "sh", "fish") {
+		if got != want {
+			t.Errorf("%s: list = %q, want %q", shell, got, want)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configFileName - имя файла конфигурации в $XDG_CONFIG_HOME/fzf-open
+const configFileName = "config.toml"
+
+// ProfileConfig - настройки, которые задаются на верхнем уровне файла
+// конфигурации и могут быть переопределены именованным профилем
+type ProfileConfig struct {
+	DefaultConfig
+	Associations AppAssociations            `toml:"associations"`
+	Timeouts     map[string]time.Duration   `toml:"timeouts,omitempty"`
+	TUI          map[string]bool            `toml:"tui,omitempty"`
+	Multi        map[string]bool            `toml:"multi,omitempty"`
+	Ignore       []string                   `toml:"ignore,omitempty"`
+	Extensions   map[string]string          `toml:"extensions,omitempty"`
+	MIME         map[string]string          `toml:"mime,omitempty"`
+	MIMETypes    map[string]string          `toml:"mime_types,omitempty"`
+	Rules        []FilenameRule             `toml:"rules,omitempty"`
+	Filters      []SelectionFilter          `toml:"selection_filters,omitempty"`
+	Picker       PickerConfig               `toml:"picker,omitempty"`
+	Actions      map[string]ActionConfig    `toml:"actions,omitempty"`
+	Converters   map[string]ConverterConfig `toml:"converters,omitempty"`
+	Previewers   map[string]string          `toml:"previewers,omitempty"`
+	Packaging    map[string]PackagingConfig `toml:"packaging,omitempty"`
+	Keys         map[string]string          `toml:"keys,omitempty"`
+	Layouts      map[string]LayoutConfig    `toml:"layouts,omitempty"`
+
+	// FuzzyKeys разрешает исправлять опечатки в ключах файла, где он задан
+	FuzzyKeys bool `toml:"fuzzy_keys,omitempty"`
+
+	// defined - ключи, заданные в файле. Только они переопределяют настройки,
+	// поэтому false и пустая строка тоже переопределяют.
+	defined definedKeys
+}
+
+// FileConfig - содержимое файла конфигурации. Незаданные ключи не
+// переопределяют встроенные настройки.
+type FileConfig struct {
+	ProfileConfig
+	Profiles map[string]ProfileConfig `toml:"profiles"`
+
+	// Include - файлы, которые загружаются раньше текущего; его собственные
+	// значения имеют приоритет. Относительные пути и шаблоны (conf.d/*.toml)
+	// отсчитываются от каталога включающего файла.
+	Include []string `toml:"include"`
+}
+
+// projectConfigName - файл настроек проекта в начальном каталоге или в корне git-репозитория
+const projectConfigName = ".fzf-open.toml"
+
+// configPath возвращает путь к файлу конфигурации. $FZF_OPEN_CONFIG имеет приоритет.
+func configPath() (string, error) {
+	if path := os.Getenv("FZF_OPEN_CONFIG"); path != "" {
+		return expandPath(path)
+	}
+
+	dir, err := xdgDir("XDG_CONFIG_HOME", ".config")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configFileName), nil
+}
+
+// readConfigFile читает файл конфигурации вместе со всеми включёнными файлами.
+// Отсутствующий основной файл - не ошибка, отсутствующий включённый - ошибка.
+func readConfigFile(path string) (*FileConfig, error) {
+	fc, err := readConfigTree(path, nil)
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, statErr := os.Stat(path); errors.Is(statErr, fs.ErrNotExist) {
+			return &FileConfig{}, nil
+		}
+	}
+	return fc, err
+}
+
+// readConfigTree читает файл и рекурсивно его include. stack - цепочка
+// включающих файлов для обнаружения циклов.
+func readConfigTree(path string, stack []string) (*FileConfig, error) {
+	for _, parent := range stack {
+		if parent == path {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), path)
+		}
+	}
+	stack = append(stack, path)
+	if len(stack) > 1 {
+		loadedConfigFiles = append(loadedConfigFiles, path)
+	}
+
+	own := &FileConfig{}
+	defined, err := decodeConfigFile(path, own, func() bool { return own.FuzzyKeys })
+	if err != nil {
+		return nil, err
+	}
+	own.defined = defined
+
+	merged := &FileConfig{}
+	merged.defined = definedKeys{}
+	for _, pattern := range own.Include {
+		includes, err := resolveIncludes(filepath.Dir(path), pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: include %q: %w", path, pattern, err)
+		}
+		for _, include := range includes {
+			fc, err := readConfigTree(include, stack)
+			if err != nil {
+				return nil, err
+			}
+			mergeDefined(merged, *fc, fc.defined)
+			maps.Copy(merged.defined, fc.defined)
+		}
+	}
+
+	own.Include = nil
+	delete(own.defined, "include")
+	mergeDefined(merged, *own, own.defined)
+	maps.Copy(merged.defined, own.defined)
+	return merged, nil
+}
+
+// resolveIncludes превращает элемент include в список файлов. Шаблон без
+// совпадений - не ошибка, обычный путь должен существовать.
+func resolveIncludes(baseDir string, pattern string) ([]string, error) {
+	path, err := expandPath(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+
+	if !strings.ContainsAny(path, "*?[") {
+		return []string{path}, nil
+	}
+
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// loadConfig применяет файл конфигурации и профиль к defaultConfig и appAssociations
+func loadConfig(profile string) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	loadedConfigFiles = append(loadedConfigFiles, path)
+
+	fc, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := applyProfileConfig(fc.ProfileConfig); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// Без -p в режиме энергосбережения применяется профиль power_saver, если он есть
+	updatePowerSaver()
+	if _, ok := fc.Profiles[powerSaverProfile]; ok && profile == "" && powerSaver {
+		profile = powerSaverProfile
+	}
+	if profile == "" {
+		return nil
+	}
+	p, ok := fc.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (available: %s)", profile, profileNames(fc))
+	}
+	p.defined = fc.defined.under("profiles", profile)
+	if err := applyProfileConfig(p); err != nil {
+		return fmt.Errorf("%s: profile %q: %w", path, profile, err)
+	}
+	updatePowerSaver()
+	return nil
+}
+
+// applyFileConfig загружает конфигурацию и переносит её в cfg, не трогая явно заданные флаги
+func applyFileConfig(cfg *Config) error {
+	if err := loadConfig(cfg.Profile); err != nil {
+		return err
+	}
+
+	if !cfg.explicitFlags["t"] {
+		cfg.Terminal = defaultConfig.Terminal
+	}
+	if !cfg.explicitFlags["d"] {
+		cfg.StartingDir = defaultConfig.StartingDir
+	}
+	return nil
+}
+
+func applyProfileConfig(p ProfileConfig) error {
+	for _, target := range p.Extensions {
+		if err := checkRuleTarget(target); err != nil {
+			return err
+		}
+	}
+	for _, target := range p.MIME {
+		if err := checkRuleTarget(target); err != nil {
+			return err
+		}
+	}
+	for _, r := range p.Rules {
+		if err := checkRuleTarget(r.Open); err != nil {
+			return err
+		}
+	}
+	for ext, mimeType := range p.MIMETypes {
+		if err := checkMIMEType(ext, mimeType); err != nil {
+			return err
+		}
+	}
+	if err := checkPowerSaver(p.PowerSaver); err != nil {
+		return err
+	}
+	if err := checkFinderLayout(p.FinderLayout); err != nil {
+		return err
+	}
+	if err := checkSort(p.Sort); err != nil {
+		return err
+	}
+	if err := checkMouse(p.Mouse); err != nil {
+		return err
+	}
+	if err := checkFinder(p.Finder); err != nil {
+		return err
+	}
+	if err := checkSystemFiles(p.SystemFiles); err != nil {
+		return err
+	}
+
+	mergeDefined(&defaultConfig, p.DefaultConfig, p.defined)
+	mergeDefined(&appAssociations, p.Associations, p.defined, "associations")
+	mergeDefined(&associationTimeouts, p.Timeouts, p.defined, "timeouts")
+	mergeDefined(&tuiAssociations, p.TUI, p.defined, "tui")
+	mergeDefined(&multiAssociations, p.Multi, p.defined, "multi")
+	mergeDefined(&ignorePatterns, p.Ignore, p.defined, "ignore")
+	extensionRules = addRules(extensionRules, p.Extensions, normalizeExtension)
+	mimeRules = addRules(mimeRules, p.MIME, normalizeMIME)
+	extensionMIMETypes = addMIMETypes(extensionMIMETypes, p.MIMETypes)
+	previewers = addRules(previewers, p.Previewers, normalizeMIME)
+	mergeDefined(&fzfOptions, p.Picker.FZF, p.defined, "picker", "fzf")
+	mergeDefined(&userActions, p.Actions, p.defined, "actions")
+	mergeDefined(&converters, p.Converters, p.defined, "converters")
+	mergeDefined(&packagingHelpers, p.Packaging, p.defined, "packaging")
+	keyBindings = addRules(keyBindings, p.Keys, normalizeKey)
+	mergeDefined(&layouts, p.Layouts, p.defined, "layouts")
+	if err := addSelectionFilters(p.Filters); err != nil {
+		return err
+	}
+	return addFilenameRules(p.Rules)
+}
+
+// applyProjectConfig накладывает .fzf-open.toml проекта, в котором находится
+// cfg.StartingDir, поверх глобальной конфигурации и профиля
+func applyProjectConfig(cfg *Config) error {
+	path := findProjectConfig(cfg.StartingDir)
+	if path == "" {
+		return nil
+	}
+	loadedConfigFiles = append(loadedConfigFiles, path)
+
+	if err := checkProjectConfigOwner(path); err != nil {
+		fmt.Fprintf(logOut, "Warning: ignoring project config %q: %v\n", path, err)
+		return nil
+	}
+
+	var p ProfileConfig
+	defined, err := decodeConfigFile(path, &p, func() bool { return p.FuzzyKeys })
+	if err != nil {
+		return err
+	}
+	p.defined = defined
+
+	// Начальный каталог уже выбран - именно по нему и найден файл проекта
+	p.StartingDir = ""
+	delete(p.defined, "starting_dir")
+	p = restrictProjectConfig(path, p)
+	if err := applyProfileConfig(p); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if !cfg.explicitFlags["t"] {
+		cfg.Terminal = defaultConfig.Terminal
+	}
+	return nil
+}
+
+// findProjectConfig ищет .fzf-open.toml в startDir, а затем в корне git-репозитория,
+// содержащего startDir. Возвращает пустую строку, если файла нет.
+func findProjectConfig(startDir string) string {
+	candidate := filepath.Join(startDir, projectConfigName)
+	if fi, err := os.Stat(candidate); err == nil && fi.Mode().IsRegular() {
+		return candidate
+	}
+
+	for dir := startDir; ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			candidate := filepath.Join(dir, projectConfigName)
+			if fi, err := os.Stat(candidate); err == nil && fi.Mode().IsRegular() {
+				return candidate
+			}
+			return ""
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func profileNames(fc *FileConfig) string {
+	if len(fc.Profiles) == 0 {
+		return "none defined"
+	}
+	names := make([]string, 0, len(fc.Profiles))
+	for name := range fc.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// definedKeys - ключи, заданные в файле конфигурации, по их записи в toml
+type definedKeys map[string]toml.Key
+
+// keysOf возвращает ключи, заданные в декодированном файле
+func keysOf(md toml.MetaData) definedKeys {
+	keys := definedKeys{}
+	for _, key := range md.Keys() {
+		keys[key.String()] = key
+	}
+	return keys
+}
+
+// has сообщает, задан ли ключ key
+func (d definedKeys) has(key toml.Key) bool {
+	_, ok := d[key.String()]
+	return ok
+}
+
+// under возвращает ключи внутри таблицы prefix относительно неё
+// (profiles.work.preview -> preview)
+func (d definedKeys) under(prefix ...string) definedKeys {
+	keys := definedKeys{}
+	for _, key := range d {
+		if len(key) > len(prefix) && slices.Equal(key[:len(prefix)], prefix) {
+			rel := toml.Key(slices.Clone(key[len(prefix):]))
+			keys[rel.String()] = rel
+		}
+	}
+	return keys
+}
+
+// mergeDefined копирует в dst поля src, ключи которых заданы в defined;
+// prefix - путь src в файле. Вложенные структуры сливаются рекурсивно, карты -
+// по ключам (структуры в картах - тоже рекурсивно). Без defined копируются
+// все ненулевые поля.
+func mergeDefined(dst any, src any, defined definedKeys, prefix ...string) {
+	mergeValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src), defined, toml.Key(prefix))
+}
+
+func mergeValue(dst reflect.Value, src reflect.Value, defined definedKeys, key toml.Key) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			f := dst.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			mergeValue(dst.Field(i), src.Field(i), defined, fieldKey(key, f))
+		}
+	case reflect.Map:
+		if src.Len() == 0 {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		}
+		iter := src.MapRange()
+		for iter.Next() {
+			value := iter.Value()
+			if existing := dst.MapIndex(iter.Key()); existing.IsValid() && value.Kind() == reflect.Struct {
+				merged := reflect.New(value.Type()).Elem()
+				merged.Set(existing)
+				mergeValue(merged, value, defined, append(slices.Clip(key), fmt.Sprint(iter.Key())))
+				value = merged
+			}
+			dst.SetMapIndex(iter.Key(), value)
+		}
+	default:
+		if defined == nil && !src.IsZero() || defined.has(key) {
+			dst.Set(src)
+		}
+	}
+}
+
+// fieldKey возвращает ключ поля f структуры с ключом key. Поля встроенных
+// структур лежат в той же таблице.
+func fieldKey(key toml.Key, f reflect.StructField) toml.Key {
+	name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+	if f.Anonymous && name == "" {
+		return key
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return append(slices.Clip(key), name)
+}
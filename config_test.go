@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig записывает файл конфигурации в dir
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// useConfig делает path файлом конфигурации и сбрасывает глобальные настройки
+func useConfig(t *testing.T, path string) {
+	t.Helper()
+	t.Setenv("FZF_OPEN_CONFIG", path)
+	resetConfig()
+	t.Cleanup(resetConfig)
+}
+
+func TestProfileOverridesWithZeroValues(t *testing.T) {
+	dir := t.TempDir()
+	useConfig(t, writeConfig(t, dir, "config.toml", `
+preview = true
+hidden = true
+colors = true
+frecency = true
+terminal = "foot"
+
+[associations]
+text_editor = "nvim"
+
+[profiles.plain]
+preview = false
+hidden = false
+colors = false
+terminal = ""
+
+[profiles.plain.associations]
+text_editor = ""
+`))
+
+	if err := loadConfig("plain"); err != nil {
+		t.Fatal(err)
+	}
+	if defaultConfig.Preview || defaultConfig.Hidden || defaultConfig.Colors {
+		t.Errorf("preview, hidden, colors = %v, %v, %v; want the profile to turn them off",
+			defaultConfig.Preview, defaultConfig.Hidden, defaultConfig.Colors)
+	}
+	if defaultConfig.Terminal != "" {
+		t.Errorf("terminal = %q, want it cleared by the profile", defaultConfig.Terminal)
+	}
+	if len(appAssociations.TextEditor) != 0 {
+		t.Errorf("text_editor = %q, want it cleared by the profile", appAssociations.TextEditor)
+	}
+	// Ключи, которых нет в профиле, остаются из верхнего уровня
+	if !defaultConfig.Frecency {
+		t.Error("frecency was reset by a profile that does not set it")
+	}
+}
+
+func TestIncludeOverriddenWithZeroValues(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "base.toml", "hidden = true\nicons = true\n")
+	useConfig(t, writeConfig(t, dir, "config.toml", "include = [\"base.toml\"]\nhidden = false\n"))
+
+	if err := loadConfig(""); err != nil {
+		t.Fatal(err)
+	}
+	if defaultConfig.Hidden {
+		t.Error("hidden = true, want the including file to turn it off")
+	}
+	if !defaultConfig.Icons {
+		t.Error("icons = false, want the value from the included file")
+	}
+}
+
+func TestProjectConfigOverridesWithZeroValues(t *testing.T) {
+	dir := t.TempDir()
+	useConfig(t, writeConfig(t, dir, "config.toml", "preview = true\nfrecency = true\nstarting_dir = \"/srv\"\n"))
+	project := t.TempDir()
+	writeConfig(t, project, projectConfigName, "preview = false\nstarting_dir = \"/elsewhere\"\n")
+
+	if err := loadConfig(""); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{StartingDir: project}
+	if err := applyProjectConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if defaultConfig.Preview {
+		t.Error("preview = true, want the project file to turn it off")
+	}
+	if !defaultConfig.Frecency {
+		t.Error("frecency was reset by a project file that does not set it")
+	}
+	// starting_dir проекта не применяется: каталог уже выбран
+	if defaultConfig.StartingDir != "/srv" {
+		t.Errorf("starting_dir = %q, want /srv", defaultConfig.StartingDir)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configTemplate - содержимое нового файла конфигурации, создаваемого `config edit`
+const configTemplate = `# Конфигурация fzf-open. Незаданные параметры берутся из встроенных значений.
+# Действующие значения: fzf-open config show
+
+# terminal = "alacritty"
+# starting_dir = "~"
+
+# [associations]
+# text_editor = "nvim"
+# pdf_viewer = "zathura"
+
+# [profiles.media]
+# starting_dir = "~/Videos"
+#
+# [profiles.media.associations]
+# video_player = "mpv"
+`
+
+// runConfig реализует подкоманду config: show, edit и path
+func runConfig(args []string) int {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: fzf-open config <show [-p profile] | edit | path>\n")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	path, err := configPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving config path: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "path":
+		fmt.Println(path)
+		return 0
+	case "show":
+		flags := flag.NewFlagSet("config show", flag.ExitOnError)
+		profile := flags.String("p", "", "Configuration profile to apply")
+		flags.Parse(args[1:])
+		return showConfig(*profile)
+	case "edit":
+		return editConfig(path)
+	default:
+		usage()
+		return 2
+	}
+}
+
+// showConfig печатает действующую конфигурацию после слияния файла и профиля
+func showConfig(profile string) int {
+	if err := loadConfig(profile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		return 1
+	}
+
+	effective := ProfileConfig{
+		DefaultConfig: defaultConfig,
+		Associations:  appAssociations,
+		Timeouts:      associationTimeouts,
+		TUI:           tuiAssociations,
+		Multi:         multiAssociations,
+		Extensions:    extensionRules,
+		MIME:          mimeRules,
+		MIMETypes:     extensionMIMETypes,
+		Rules:         configuredFilenameRules(),
+		Filters:       configuredSelectionFilters(),
+		Picker:        PickerConfig{FZF: fzfOptions},
+		Actions:       userActions,
+		Converters:    converters,
+		Previewers:    previewers,
+		Packaging:     packagingHelpers,
+		Keys:          keyBindings,
+		Layouts:       layouts,
+	}
+	enc := toml.NewEncoder(os.Stdout)
+	enc.Indent = ""
+	if err := enc.Encode(effective); err != nil {
+		fmt.Fprintf(os.Stderr, "Error printing configuration: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// editConfig открывает файл конфигурации в текстовом редакторе, при необходимости создав его
+func editConfig(path string) int {
+	if err := loadConfig(""); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: current configuration has errors: %v\n", err)
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if err := ensureDir(filepath.Dir(path)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating config directory: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(path, []byte(configTemplate), 0o600); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating config file %q: %v\n", path, err)
+			return 1
+		}
+	}
+
+	editor := appAssociations.TextEditor.available()
+	parts := strings.Fields(editor)
+	if len(parts) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no text editor configured\n")
+		return 1
+	}
+
+	cmd := execCommand(context.Background(), "edit", parts[0], append(parts[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running editor %q: %v\n", editor, err)
+		return 1
+	}
+	return 0
+}
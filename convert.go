@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConverterConfig - конвертер из таблицы [converters.<имя>]: файлы с
+// расширениями From, для которых нет приложения, можно преобразовать в To
+type ConverterConfig struct {
+	From []string `toml:"from"`
+	To   string   `toml:"to"`
+	// Command - команда шелла; {file} - исходный файл, {out} - файл результата,
+	// {outdir} - его каталог
+	Command string `toml:"command"`
+}
+
+// convertLockTimeout - сколько ждать конвертацию того же файла другим процессом
+const convertLockTimeout = 10 * time.Minute
+
+// converters - конвертеры из конфигурации по именам
+var converters = map[string]ConverterConfig{}
+
+// Пункты меню конвертации
+const (
+	menuConvertPrefix = "Convert to "
+	menuOpenAnyway    = "Open with fallback opener"
+)
+
+// converterFor возвращает конвертер для расширения, если его команда есть в PATH
+func converterFor(ext string) (ConverterConfig, bool) {
+	if safeMode {
+		return ConverterConfig{}, false
+	}
+	names := make([]string, 0, len(converters))
+	for name := range converters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		conv := converters[name]
+		if conv.Command == "" || conv.To == "" {
+			continue
+		}
+		for _, from := range conv.From {
+			if normalizeExtension(from) != ext {
+				continue
+			}
+			if fields := strings.Fields(conv.Command); len(fields) > 0 {
+				if _, err := cachedLookPath(fields[0]); err == nil {
+					return conv, true
+				}
+			}
+		}
+	}
+	return ConverterConfig{}, false
+}
+
+// offerConversion предлагает преобразовать файл и открыть результат. В терминале
+// показывается меню, без терминала конвертация выполняется сразу. Возвращает
+// false, если пользователь выбрал обычное открытие.
+func offerConversion(ctx context.Context, filePath string, conv ConverterConfig) (bool, error) {
+	to := strings.TrimPrefix(conv.To, ".")
+	if isInteractive() {
+		choice, err := fzfMenu(fmt.Sprintf("No application for %s> ", filepath.Base(filePath)), false,
+			menuConvertPrefix+to, menuOpenAnyway, menuCancel)
+		if err != nil || choice == "" || choice == menuCancel {
+			return true, nil
+		}
+		if choice == menuOpenAnyway {
+			return false, nil
+		}
+	}
+
+	out, err := convertFile(filePath, conv)
+	if err != nil {
+		return true, err
+	}
+	return true, openPath(ctx, out, false)
+}
+
+// convertFile преобразует файл или берёт готовый результат из кэша. Ключ кэша
+// учитывает путь, размер и время изменения файла, а также команду конвертера.
+func convertFile(filePath string, conv ConverterConfig) (string, error) {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+	base, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d\x00%s", filePath, fi.Size(), fi.ModTime().UnixNano(), conv.Command)))
+	dir := filepath.Join(base, "converted", hex.EncodeToString(sum[:16]))
+	stem := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	out := filepath.Join(dir, stem+"."+strings.TrimPrefix(conv.To, "."))
+
+	if err := ensurePrivateDir(filepath.Dir(dir)); err != nil {
+		return "", err
+	}
+	// Параллельный вызов для того же файла дожидается первого и берёт его
+	// результат, а не конвертирует заново и не открывает недописанный файл
+	unlock, err := lockFile(dir+".lock", convertLockTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(out); err == nil {
+		fmt.Fprintf(logOut, "Info: using cached conversion %q\n", out)
+		return out, nil
+	}
+	if err := ensurePrivateDir(dir); err != nil {
+		return "", err
+	}
+
+	// Неудачный результат удаляется вместе с его блокировкой
+	discard := func() {
+		os.RemoveAll(dir)
+		os.Remove(dir + ".lock")
+	}
+	fmt.Fprintf(logOut, "Converting %q to %s...\n", filePath, conv.To)
+	if err := runActionCommand(conv.Command, []string{filePath}, out); err != nil {
+		discard()
+		return "", fmt.Errorf("converting %q: %w", filePath, err)
+	}
+	if _, err := os.Stat(out); err != nil {
+		discard()
+		return "", fmt.Errorf("converter did not produce %s", out)
+	}
+	pruneOrphanLocks(filepath.Dir(dir))
+	return out, nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Разделы mimeapps.list, из которых берутся приложения, в порядке приоритета
+var mimeappsSections = []string{"Default Applications", "Added Associations"}
+
+// mimeappsSpec возвращает приложение по умолчанию из mimeapps.list, если
+// включено use_mimeapps. MIME-тип определяется при необходимости и
+// сохраняется в info.
+func mimeappsSpec(filePath string, info *FileTypeInfo) (launchSpec, bool) {
+	if !defaultConfig.UseMimeapps {
+		return launchSpec{}, false
+	}
+	if info.MIMEType == "" {
+		info.MIMEType = getMimeType(filePath)
+	}
+	return desktopDefaultSpec(info.MIMEType, filePath)
+}
+
+// desktopDefaultSpec возвращает команду приложения, назначенного в mimeapps.list
+// для mimeType, с подставленным путём к файлу
+func desktopDefaultSpec(mimeType, filePath string) (launchSpec, bool) {
+	if mimeType == "" {
+		return launchSpec{}, false
+	}
+
+	for _, section := range mimeappsSections {
+		for _, list := range mimeappsFiles() {
+			for _, id := range readMimeappsEntry(list, section, mimeType) {
+				entry, err := readDesktopEntry(id)
+				if err != nil {
+					continue
+				}
+				argv, err := entry.command(filePath)
+				if err != nil {
+					fmt.Fprintf(logOut, "Warning: %s: %v\n", entry.path, err)
+					continue
+				}
+				return launchSpec{Command: strings.Join(argv, " "), Argv: argv}, true
+			}
+		}
+	}
+	return launchSpec{}, false
+}
+
+// mimeappsFiles возвращает пути mimeapps.list в порядке поиска по спецификации
+// freedesktop: сначала файлы текущего окружения рабочего стола, затем общие
+func mimeappsFiles() []string {
+	var desktops []string
+	for _, d := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		if d != "" {
+			desktops = append(desktops, strings.ToLower(d))
+		}
+	}
+
+	var files []string
+	add := func(dir string) {
+		for _, d := range desktops {
+			files = append(files, filepath.Join(dir, d+"-mimeapps.list"))
+		}
+		files = append(files, filepath.Join(dir, "mimeapps.list"))
+	}
+
+	for _, dir := range xdgBaseDirs("XDG_CONFIG_HOME", ".config", "XDG_CONFIG_DIRS", "/etc/xdg") {
+		add(dir)
+	}
+	for _, dir := range applicationDirs() {
+		add(dir)
+	}
+	return files
+}
+
+// applicationDirs возвращает каталоги с .desktop-файлами
+func applicationDirs() []string {
+	dirs := xdgBaseDirs("XDG_DATA_HOME", filepath.Join(".local", "share"), "XDG_DATA_DIRS", "/usr/local/share:/usr/share")
+	for i, dir := range dirs {
+		dirs[i] = filepath.Join(dir, "applications")
+	}
+	return dirs
+}
+
+// xdgBaseDirs возвращает пользовательский каталог XDG и системные каталоги из
+// списка через двоеточие (без подкаталога fzf-open)
+func xdgBaseDirs(homeVar, homeFallback, dirsVar, dirsFallback string) []string {
+	var dirs []string
+	if base := os.Getenv(homeVar); filepath.IsAbs(base) {
+		dirs = append(dirs, base)
+	} else if home, err := expandPath("~"); err == nil && home != "" {
+		dirs = append(dirs, filepath.Join(home, homeFallback))
+	}
+
+	list := os.Getenv(dirsVar)
+	if list == "" {
+		list = dirsFallback
+	}
+	for _, dir := range strings.Split(list, ":") {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// readMimeappsEntry возвращает идентификаторы .desktop-файлов для mimeType
+// из раздела section файла mimeapps.list
+func readMimeappsEntry(path, section, mimeType string) []string {
+	values := readIniSection(path, section)
+	var ids []string
+	for _, id := range strings.Split(values[mimeType], ";") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// readIniSection читает пары ключ=значение одного раздела ini-файла.
+// Отсутствующий файл - пустой результат.
+func readIniSection(path, section string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = line[1:len(line)-1] == section
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			key = strings.TrimSpace(key)
+			if _, seen := values[key]; !seen {
+				values[key] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return values
+}
+
+// desktopEntry - нужные fzf-open поля раздела [Desktop Entry]
+type desktopEntry struct {
+	path     string
+	name     string
+	icon     string
+	exec     string
+	terminal bool
+}
+
+// readDesktopEntry находит .desktop-файл по идентификатору. Идентификатор
+// "kde-foo.desktop" может соответствовать файлу kde/foo.desktop.
+func readDesktopEntry(id string) (*desktopEntry, error) {
+	candidates := []string{id}
+	if prefix, rest, ok := strings.Cut(id, "-"); ok {
+		candidates = append(candidates, filepath.Join(prefix, rest))
+	}
+
+	for _, dir := range applicationDirs() {
+		for _, name := range candidates {
+			path := filepath.Join(dir, name)
+			values := readIniSection(path, "Desktop Entry")
+			if values == nil {
+				continue
+			}
+			if values["Exec"] == "" || values["Hidden"] == "true" {
+				return nil, fmt.Errorf("%s: no Exec entry", path)
+			}
+			return &desktopEntry{
+				path:     path,
+				name:     values["Name"],
+				icon:     values["Icon"],
+				exec:     values["Exec"],
+				terminal: values["Terminal"] == "true",
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("desktop entry %q not found", id)
+}
+
+// command раскрывает коды полей Exec (%f, %u и т.д.) для filePath. Приложения с
+// Terminal=true запускаются в терминале из конфигурации.
+func (e *desktopEntry) command(filePath string) ([]string, error) {
+	args, err := splitExec(e.exec)
+	if err != nil {
+		return nil, err
+	}
+
+	var argv []string
+	hasFile := false
+	for _, arg := range args {
+		switch arg {
+		case "%f", "%F", "%u", "%U":
+			argv = append(argv, filePath)
+			hasFile = true
+			continue
+		case "%i":
+			if e.icon != "" {
+				argv = append(argv, "--icon", e.icon)
+			}
+			continue
+		}
+
+		var sb strings.Builder
+		for i := 0; i < len(arg); i++ {
+			if arg[i] != '%' || i+1 == len(arg) {
+				sb.WriteByte(arg[i])
+				continue
+			}
+			i++
+			switch arg[i] {
+			case '%':
+				sb.WriteByte('%')
+			case 'f', 'F', 'u', 'U':
+				sb.WriteString(filePath)
+				hasFile = true
+			case 'c':
+				sb.WriteString(e.name)
+			case 'k':
+				sb.WriteString(e.path)
+			}
+		}
+		if sb.Len() > 0 {
+			argv = append(argv, sb.String())
+		}
+	}
+
+	if len(argv) == 0 {
+		return nil, errors.New("empty Exec entry")
+	}
+	if !hasFile {
+		argv = append(argv, filePath)
+	}
+	if e.terminal {
+		argv = inTerminal(argv)
+	}
+	return argv, nil
+}
+
+// splitExec разбивает значение Exec на аргументы с учётом кавычек и
+// экранирования по спецификации Desktop Entry
+func splitExec(exec string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg, quoted := false, false
+
+	for i := 0; i < len(exec); i++ {
+		c := exec[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(exec):
+			i++
+			cur.WriteByte(exec[i])
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteByte(c)
+			inArg = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in Exec %q", exec)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
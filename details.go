@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// detailsMode - флаг --details: перед каждым файлом в списке стоят размер и
+// время изменения. fzf ищет и по ним, а fzf-open отрезает их по табуляции,
+// прежде чем искать файл.
+var detailsMode bool
+
+// detailsSeparator отделяет столбцы --details от пути; в самих столбцах
+// табуляции нет, поэтому путь с табуляцией не страдает
+const detailsSeparator = "\t"
+
+// detailsTimeFormat - время изменения в столбце --details
+const detailsTimeFormat = "2006-01-02 15:04"
+
+// candidateDetails сообщает, что строки списка начинаются со столбцов
+// --details. Их понимают fzf и skim; строки --grep и список сервера остаются
+// без столбцов.
+func candidateDetails() bool {
+	return detailsMode && currentFinder().fzfFlags() && !grepMode && !serverMode && !serverClient
+}
+
+// fileDetails возвращает столбцы размера и времени изменения файла с
+// разделителем; для каталога вместо размера - прочерк, для недоступного
+// файла - пустые столбцы той же ширины
+func fileDetails(file string) string {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return fmt.Sprintf("%6s  %-*s%s", "", len(detailsTimeFormat), "", detailsSeparator)
+	}
+	size := "-"
+	if !fi.IsDir() {
+		size = humanSize(fi.Size())
+	}
+	return fmt.Sprintf("%6s  %s%s", size, fi.ModTime().Format(detailsTimeFormat), detailsSeparator)
+}
+
+// humanSize возвращает размер в байтах коротко, как ls -h: 512B, 1.5K, 23M
+func humanSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n)
+	for _, unit := range []string{"K", "M", "G", "T", "P"} {
+		value /= 1024
+		if value < 10 {
+			return fmt.Sprintf("%.1f%s", value, unit)
+		}
+		if value < 1024 || unit == "P" {
+			return fmt.Sprintf("%.0f%s", value, unit)
+		}
+	}
+	return ""
+}
+
+// stripDetails отрезает столбцы --details от строки списка
+func stripDetails(line string) string {
+	if _, path, ok := strings.Cut(line, detailsSeparator); ok {
+		return path
+	}
+	return line
+}
+
+// stripDecoration убирает из строки списка столбцы --details и значок icons.
+// Нужна командам, которые fzf вызывает со строкой ({}), - превью и навигации:
+// они не знают, как оформлен список.
+func stripDecoration(line string) string {
+	return stripIcon(stripDetails(line))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+)
+
+// knownAlternatives - распространённые приложения для каждой ассоциации;
+// doctor предлагает установленные из них, если настроенное не найдено
+var knownAlternatives = map[string][]string{
+	"terminal":             {"alacritty", "kitty", "foot", "wezterm", "gnome-terminal", "konsole", "xfce4-terminal", "xterm", "wt"},
+	assocTextEditor:        {"zeditor", "code", "nvim", "vim", "hx", "micro", "nano", "gedit", "kate", "emacs"},
+	assocPDFViewer:         {"zathura", "evince", "okular", "mupdf", "qpdfview", "atril"},
+	assocImageViewer:       {"eog", "imv", "nsxiv", "sxiv", "feh", "gwenview", "ristretto", "loupe"},
+	assocVideoPlayer:       {"mpv", "vlc", "celluloid", "totem", "haruna"},
+	assocSpreadsheetEditor: {"libreoffice", "localc", "gnumeric", "wps", "et"},
+	assocWebBrowser:        {"firefox", "chromium", "google-chrome-stable", "brave", "thorium-browser", "librewolf"},
+	assocDocxViewer:        {"libreoffice", "lowriter", "wps", "abiword"},
+	assocEbookReader:       {"foliate", "zathura", "okular", "evince", "calibre", "koreader"},
+	assocFontViewer:        {"font-manager", "gnome-font-viewer", "fontforge", "kfontview"},
+	assocArchiveManager:    {"file-roller", "ark", "xarchiver", "engrampa", "peazip"},
+	assocTorrentClient:     {"transmission-gtk", "qbittorrent", "deluge", "fragments", "ktorrent"},
+	assocDirectoryOpener:   {"yazi", "lf", "ranger", "nnn", "nautilus", "dolphin", "thunar", "nemo", "pcmanfm"},
+	assocLogViewer:         {"lnav", "less", "multitail"},
+	assocFallbackOpener:    {"xdg-open", "gio", "exo-open", "mimeopen", "open", "rundll32", "wslview", "explorer.exe", "termux-open"},
+}
+
+// doctorCheck - результат одной проверки
+type doctorCheck struct {
+	name     string
+	command  string
+	required bool
+	hint     string
+}
+
+// runDoctor реализует подкоманду doctor: проверяет наличие всех внешних программ,
+// от которых зависит работа, и предлагает установленные альтернативы
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	profile := fs.String("p", "", "Configuration profile to check")
+	fs.Parse(args)
+
+	if err := loadConfig(*profile); err != nil {
+		fmt.Printf("[error]   configuration: %v\n", err)
+		return 1
+	}
+
+	var checks []doctorCheck
+	switch {
+	case finderName() != finderBuiltin:
+		checks = append(checks, doctorCheck{name: finderName(), command: finderCommand(), required: true,
+			hint: finderHint()})
+	case defaultConfig.Finder != finderBuiltin:
+		// fzf не найден, и выбор идёт во встроенной программе
+		checks = append(checks, doctorCheck{name: finderFzf, command: finderFzf,
+			hint: "the built-in finder is used instead; install fzf for previews and key bindings"})
+	}
+	// В Termux -n недоступен, и терминал не нужен
+	if !isTermux() {
+		checks = append(checks, doctorCheck{name: "terminal", command: terminalProgram(defaultConfig.Terminal),
+			hint: "needed only for -n (spawn a new terminal window)"})
+	}
+	if program := sourceProgram(); program != "" {
+		checks = append(checks, doctorCheck{name: "source", command: program,
+			hint: "set source = \"walk\" to list files without external programs"})
+	}
+	if mimeQueryTool != "" {
+		checks = append(checks, doctorCheck{name: mimeQueryTool, command: mimeQueryTool, hint: mimeQueryHint})
+	}
+
+	rv := reflect.ValueOf(appAssociations)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		key := rt.Field(i).Tag.Get("toml")
+		checks = append(checks, doctorCheck{
+			name:     key,
+			command:  rv.Field(i).Interface().(CommandList).available(),
+			required: key == assocFallbackOpener,
+		})
+	}
+
+	failed := false
+	for _, check := range checks {
+		if !runDoctorCheck(check) && check.required {
+			failed = true
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// runDoctorCheck печатает результат проверки и возвращает true, если программа найдена
+func runDoctorCheck(check doctorCheck) bool {
+	parts := strings.Fields(check.command)
+	if len(parts) == 0 {
+		fmt.Printf("[skip]    %s: not configured\n", check.name)
+		if alternatives := installedAlternatives(check.name, ""); len(alternatives) > 0 {
+			fmt.Printf("          installed candidates: %s\n", strings.Join(alternatives, ", "))
+		}
+		return !check.required
+	}
+
+	if path, err := exec.LookPath(parts[0]); err == nil {
+		fmt.Printf("[ok]      %s: %s\n", check.name, path)
+		return true
+	}
+
+	status := "[warn]   "
+	if check.required {
+		status = "[missing]"
+	}
+	fmt.Printf("%s %s: %q not found in PATH\n", status, check.name, parts[0])
+
+	if alternatives := installedAlternatives(check.name, parts[0]); len(alternatives) > 0 {
+		fmt.Printf("          installed alternatives: %s\n", strings.Join(alternatives, ", "))
+	}
+	if check.hint != "" {
+		fmt.Printf("          %s\n", check.hint)
+	}
+	return false
+}
+
+// installedAlternatives возвращает найденные в PATH известные альтернативы, кроме missing
+func installedAlternatives(key string, missing string) []string {
+	var found []string
+	for _, candidate := range knownAlternatives[key] {
+		if candidate == missing {
+			continue
+		}
+		if _, err := exec.LookPath(candidate); err == nil {
+			found = append(found, candidate)
+		}
+	}
+	return found
+}
+
+// finderHint подсказывает, где взять программу выбора из ключа finder
+func finderHint() string {
+	if usingFzf() {
+		return "install fzf: https://github.com/junegunn/fzf"
+	}
+	return fmt.Sprintf("install %s or set finder = %q", finderName(), finderFzf)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// editorProbeTimeout ограничивает опрос сервера редактора (kak -l)
+const editorProbeTimeout = time.Second
+
+// editorProfile - встроенные настройки серверного редактора. Применяются, если
+// в ассоциации указана только сама программа, без аргументов и шаблона.
+type editorProfile struct {
+	// args возвращает аргументы для открытия файла; terminal - редактор
+	// запускается в терминале
+	args func(appPath, filePath string, line int, terminal bool) []string
+	// tui - редактору всегда нужен терминал
+	tui bool
+}
+
+// editorProfiles - профили по имени программы
+var editorProfiles = map[string]editorProfile{
+	"emacsclient": {args: emacsclientArgs},
+	"kak":         {args: kakArgs, tui: true},
+}
+
+// editorProfileFor возвращает профиль для ассоциации без собственных аргументов
+func editorProfileFor(spec launchSpec, appPath string, appArgs []string) (editorProfile, bool) {
+	if spec.Argv != nil || len(appArgs) > 0 {
+		return editorProfile{}, false
+	}
+	name := strings.TrimSuffix(filepath.Base(appPath), ".exe")
+	profile, ok := editorProfiles[name]
+	return profile, ok
+}
+
+// plusLineEditors - редакторы, которые переходят к строке по аргументу +строка
+// перед файлом
+var plusLineEditors = map[string]bool{
+	"vi": true, "vim": true, "nvim": true, "gvim": true, "mvim": true, "view": true,
+	"nano": true, "emacs": true, "micro": true, "joe": true, "ne": true, "mg": true, "less": true,
+}
+
+// colonLineEditors - редакторы, которые понимают путь вида файл:строка; в
+// значении - флаги перед таким путём
+var colonLineEditors = map[string][]string{
+	"hx": nil, "helix": nil, "subl": nil, "zed": nil,
+	"code": {"--goto"}, "codium": {"--goto"}, "code-oss": {"--goto"},
+}
+
+// guiEditors - графические редакторы: из plusLineEditors и colonLineEditors и
+// другие известные. Остальные программы в $VISUAL считаются терминальными.
+var guiEditors = map[string]bool{
+	"gvim": true, "mvim": true, "subl": true, "zed": true, "zeditor": true,
+	"code": true, "codium": true, "code-oss": true,
+	"gedit": true, "gnome-text-editor": true, "kate": true, "kwrite": true,
+	"mousepad": true, "pluma": true, "xed": true,
+}
+
+// guiEditor сообщает, что команда запускает графический редактор
+func guiEditor(command string) bool {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return false
+	}
+	return guiEditors[strings.TrimSuffix(filepath.Base(fields[0]), ".exe")]
+}
+
+// editorLineArgs возвращает аргументы, открывающие файл в редакторе на
+// строке line, если ассоциация задана без шаблона. false - редактор неизвестен.
+func editorLineArgs(appPath, filePath string, line int) ([]string, bool) {
+	name := strings.TrimSuffix(filepath.Base(appPath), ".exe")
+	if plusLineEditors[name] {
+		return []string{"+" + strconv.Itoa(line), filePath}, true
+	}
+	if flags, ok := colonLineEditors[name]; ok {
+		return append(append([]string(nil), flags...), filePath+":"+strconv.Itoa(line)), true
+	}
+	return nil, false
+}
+
+// lineArg - аргумент +строка, который понимают emacsclient и kak
+func lineArg(args []string, line int) []string {
+	if line > 0 {
+		return append(args, "+"+strconv.Itoa(line))
+	}
+	return args
+}
+
+// emacsclientArgs открывает файл в новом фрейме сервера Emacs (-c), не дожидаясь
+// закрытия буфера (-n), а в терминале - в текущем терминале (-t). Если сервер
+// не запущен, -a "" поручает emacsclient запустить его, так что следующие
+// файлы откроются в том же сервере.
+func emacsclientArgs(appPath, filePath string, line int, terminal bool) []string {
+	args := []string{"-n", "-c"}
+	if terminal {
+		args = []string{"-t"}
+	}
+	if !emacsServerRunning() {
+		fmt.Fprintln(logOut, "Info: no Emacs server is running, emacsclient will start one")
+		args = append(args, "-a", "")
+	}
+	return append(lineArg(args, line), filePath)
+}
+
+// emacsServerRunning ищет сокет сервера Emacs там же, где его ищет emacsclient
+func emacsServerRunning() bool {
+	name := os.Getenv("EMACS_SOCKET_NAME")
+	if filepath.IsAbs(name) {
+		return isSocket(name)
+	}
+	if name == "" {
+		name = "server"
+	}
+
+	var dirs []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		dirs = append(dirs, filepath.Join(dir, "emacs"))
+	}
+	dirs = append(dirs, filepath.Join(os.TempDir(), "emacs"+strconv.Itoa(os.Getuid())))
+	for _, dir := range dirs {
+		if isSocket(filepath.Join(dir, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSocket сообщает, является ли path сокетом
+func isSocket(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode()&os.ModeSocket != 0
+}
+
+// kakArgs подключает файл к уже работающей сессии Kakoune (-c): той, из
+// которой запущен fzf-open, или первой из kak -l. Без сессий kak запускается
+// как обычно.
+func kakArgs(appPath, filePath string, line int, terminal bool) []string {
+	var args []string
+	if session := kakSession(appPath); session != "" {
+		args = []string{"-c", session}
+	}
+	return append(lineArg(args, line), filePath)
+}
+
+// kakSession возвращает имя сессии Kakoune для подключения
+func kakSession(appPath string) string {
+	if session := os.Getenv("KAKOUNE_SESSION"); session != "" {
+		return session
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), editorProbeTimeout)
+	defer cancel()
+	out, err := execCommand(ctx, "probe", appPath, "-l").Output()
+	if err != nil {
+		return ""
+	}
+	for _, session := range strings.Split(string(out), "\n") {
+		// Сессии упавших серверов kak -l помечает "(dead)"
+		if session = strings.TrimSpace(session); session != "" && !strings.HasSuffix(session, "(dead)") {
+			return session
+		}
+	}
+	return ""
+}
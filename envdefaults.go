@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// envEditors - команды из $EDITOR и $VISUAL, которые запускаются в терминале,
+// как приложения из [tui]. $EDITOR по соглашению терминальный; $VISUAL тоже,
+// если это не известный графический редактор (guiEditors), - так же его
+// запускает consoleSpecs.
+var envEditors = map[string]bool{}
+
+// defaultTextEditors возвращает text_editor по умолчанию: $VISUAL и $EDITOR,
+// если заданы, а за ними встроенный редактор
+func defaultTextEditors() CommandList {
+	var editors CommandList
+	for _, envVar := range []string{"VISUAL", "EDITOR"} {
+		command := strings.TrimSpace(os.Getenv(envVar))
+		if command == "" {
+			continue
+		}
+		if !slices.Contains(editors, command) {
+			editors = append(editors, command)
+		}
+		if envVar == "EDITOR" || !guiEditor(command) {
+			envEditors[command] = true
+		}
+	}
+	return append(editors, "zeditor")
+}
+
+// defaultWebBrowsers возвращает web_browser по умолчанию: программы из
+// $BROWSER (список через ":", %s - адрес или путь), а за ними встроенный браузер
+func defaultWebBrowsers() CommandList {
+	var browsers CommandList
+	for _, command := range strings.Split(os.Getenv("BROWSER"), ":") {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			continue
+		}
+		command = strings.ReplaceAll(strings.ReplaceAll(command, "%s", "{file}"), "%%", "%")
+		browsers = append(browsers, command)
+	}
+	return append(browsers, "thorium-browser")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// stubEnvEditors задаёт $VISUAL и $EDITOR и пересчитывает text_editor по умолчанию
+func stubEnvEditors(t *testing.T, visual, editor string) {
+	t.Helper()
+	t.Setenv("VISUAL", visual)
+	t.Setenv("EDITOR", editor)
+	oldEditors, oldAssoc, oldTUI := envEditors, appAssociations, tuiAssociations
+	t.Cleanup(func() { envEditors, appAssociations, tuiAssociations = oldEditors, oldAssoc, oldTUI })
+
+	envEditors = map[string]bool{}
+	appAssociations.TextEditor = defaultTextEditors()
+	tuiAssociations = map[string]bool{}
+}
+
+// editorTUI возвращает, какие команды text_editor запускаются в терминале
+func editorTUI() map[string]bool {
+	tui := map[string]bool{}
+	for _, spec := range associationSpecs(assocTextEditor) {
+		tui[spec.Command] = spec.TUI
+	}
+	return tui
+}
+
+func TestDefaultTextEditors(t *testing.T) {
+	stubEnvEditors(t, "code -w", "nvim")
+
+	if want := (CommandList{"code -w", "nvim", "zeditor"}); !slices.Equal(appAssociations.TextEditor, want) {
+		t.Errorf("text_editor = %q, want %q", appAssociations.TextEditor, want)
+	}
+	tui := editorTUI()
+	if tui["code -w"] {
+		t.Error("graphical $VISUAL is started in a terminal")
+	}
+	if !tui["nvim"] {
+		t.Error("$EDITOR is not started in a terminal")
+	}
+}
+
+func TestTerminalVisual(t *testing.T) {
+	for _, visual := range []string{"nvim", "/usr/bin/vim -p", "hx"} {
+		stubEnvEditors(t, visual, "")
+
+		if !editorTUI()[visual] {
+			t.Errorf("$VISUAL %q is not started in a terminal", visual)
+		}
+	}
+}
+
+func TestDefaultTextEditorsSameCommand(t *testing.T) {
+	stubEnvEditors(t, "vim", "vim")
+
+	if want := (CommandList{"vim", "zeditor"}); !slices.Equal(appAssociations.TextEditor, want) {
+		t.Errorf("text_editor = %q, want %q", appAssociations.TextEditor, want)
+	}
+	if !editorTUI()["vim"] {
+		t.Error("$EDITOR equal to $VISUAL is not started in a terminal")
+	}
+}
+
+func TestTUITableOverridesEditor(t *testing.T) {
+	stubEnvEditors(t, "", "hx")
+	tuiAssociations[assocTextEditor] = false
+
+	if editorTUI()["hx"] {
+		t.Error("[tui] text_editor = false does not override $EDITOR")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// fakeExecDir - каталог заглушек для внутреннего режима --fake-exec. В этом
+// режиме все программы ищутся только в нём, а поиск и запуски записываются в
+// журнал; так проверяется вся цепочка выбор → определение → запуск без
+// настоящих приложений. Заглушкой становится и шелл (/bin/sh из shellArgv):
+// если сценарию нужен настоящий, его ссылку кладут в каталог заглушек.
+var fakeExecDir string
+
+// fakeExecJournal - имя журнала в каталоге заглушек
+const fakeExecJournal = "journal"
+
+var fakeJournalLock sync.Mutex
+
+// setupFakeExec включает режим --fake-exec. PATH дочерних процессов (в том
+// числе шелла, который запускает fzf) заменяется каталогом заглушек; прежний
+// PATH доступен заглушкам в FZF_OPEN_REAL_PATH, путь журнала - в
+// FZF_OPEN_FAKE_JOURNAL.
+func setupFakeExec(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return fmt.Errorf("--fake-exec: %q is not a directory", dir)
+	}
+
+	fakeExecDir = dir
+	clearPathCache()
+	os.Setenv("FZF_OPEN_REAL_PATH", os.Getenv("PATH"))
+	os.Setenv("FZF_OPEN_FAKE_JOURNAL", filepath.Join(dir, fakeExecJournal))
+	os.Setenv("PATH", dir)
+	return nil
+}
+
+// inheritFakeExec включает режим --fake-exec в подкомандах, которые fzf
+// запускает из сессии с --fake-exec (nav, preview, rank и другие): каталог
+// заглушек - каталог журнала из FZF_OPEN_FAKE_JOURNAL
+func inheritFakeExec() {
+	if journal := os.Getenv("FZF_OPEN_FAKE_JOURNAL"); journal != "" && filepath.IsAbs(journal) {
+		fakeExecDir = filepath.Dir(journal)
+		clearPathCache()
+	}
+}
+
+// clearPathCache забывает пути, найденные до включения --fake-exec: init
+// заранее ищет в настоящем PATH распространённые программы, и без очистки
+// они запускались бы в обход заглушек. Сначала дожидаемся этого поиска.
+func clearPathCache() {
+	pathCacheOnce.Do(func() {})
+	pathCacheLock.Lock()
+	clear(pathCache)
+	pathCacheLock.Unlock()
+}
+
+// execCommand создаёт команду, как exec.CommandContext, и в режиме
+// --fake-exec записывает её в журнал событием event. Программа, в том числе
+// заданная абсолютным путём, в этом режиме берётся из каталога заглушек: без
+// заглушки запуск завершится ошибкой, а не запустит настоящую программу.
+func execCommand(ctx context.Context, event, name string, args ...string) *exec.Cmd {
+	if fakeExecDir != "" {
+		journalExec(event, append([]string{name}, args...)...)
+		name = filepath.Join(fakeExecDir, filepath.Base(name))
+	}
+	return exec.CommandContext(ctx, name, args...)
+}
+
+// fakeLookPath ищет заглушку с именем программы name. Абсолютные пути тоже
+// перенаправляются, чтобы не запустить настоящую программу.
+func fakeLookPath(name string) (string, error) {
+	path := filepath.Join(fakeExecDir, filepath.Base(name))
+	if fi, err := os.Stat(path); err != nil || fi.IsDir() || fi.Mode()&0o111 == 0 {
+		journalExec("lookup", name, "(missing)")
+		return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	journalExec("lookup", name, path)
+	return path, nil
+}
+
+// journalExec дописывает событие в журнал режима --fake-exec
+func journalExec(event string, args ...string) {
+	if fakeExecDir == "" {
+		return
+	}
+
+	fakeJournalLock.Lock()
+	defer fakeJournalLock.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(fakeExecDir, fakeExecJournal), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	fmt.Fprintf(f, "%s %s\n", event, strings.Join(quoted, " "))
+}
@@ -0,0 +1,239 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain позволяет интеграционным тестам запускать тестовый бинарник как
+// fzf-open: с FZF_OPEN_TEST_MAIN=1 он выполняет main. Так же его запускают fzf
+// и шелл, когда вызывают подкоманды fzf-open.
+func TestMain(m *testing.M) {
+	if os.Getenv("FZF_OPEN_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeFzf - заглушка fzf: печатает пустые строки запроса и клавиши, если их
+// просили, и выбирает файл $FAKE_FZF_SELECT, как fzf с --print0 или без него
+const fakeFzf = `#!/bin/sh
+sep='\n'
+query=
+key=
+for arg in "$@"; do
+	case $arg in
+	--print0) sep='\0' ;;
+	--print-query) query=1 ;;
+	--expect=*) key=1 ;;
+	esac
+done
+[ -n "$query" ] && printf "$sep"
+[ -n "$key" ] && printf "$sep"
+printf "%s$sep" "$FAKE_FZF_SELECT"
+`
+
+// writeStub кладёт исполняемую заглушку в каталог stubs
+func writeStub(t *testing.T, stubs, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(stubs, name), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runFakeExec запускает fzf-open с --fake-exec stubs и возвращает журнал
+func runFakeExec(t *testing.T, stubs string, env []string, args ...string) []string {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, exe, append([]string{"--fake-exec", stubs}, args...)...)
+	cmd.Env = append(os.Environ(), "FZF_OPEN_TEST_MAIN=1", "DISPLAY=:0", "WAYLAND_DISPLAY=")
+	cmd.Env = append(cmd.Env, env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("fzf-open %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+
+	return readJournal(t, stubs)
+}
+
+// journalHas сообщает, есть ли в журнале строка события event с аргументами args
+func journalHas(journal []string, event string, args ...string) bool {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	want := event + " " + strings.Join(quoted, " ")
+	for _, line := range journal {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFakeExecPickAndOpen(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh in PATH")
+	}
+	root := t.TempDir()
+	stubs := filepath.Join(root, "stubs")
+	dir := filepath.Join(root, "files")
+	for _, d := range []string{stubs, dir} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Шелл в режиме --fake-exec тоже берётся из каталога заглушек
+	if err := os.Symlink(sh, filepath.Join(stubs, "sh")); err != nil {
+		t.Fatal(err)
+	}
+	writeStub(t, stubs, "fzf", fakeFzf)
+	writeStub(t, stubs, "test-editor", "#!/bin/sh\nexit 0\n")
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("notes\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(root, "config.toml")
+	if err := os.WriteFile(config, []byte("[associations]\ntext_editor = \"test-editor\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	journal := runFakeExec(t, stubs, []string{
+		"FZF_OPEN_CONFIG=" + config,
+		"FZF_OPEN_HOME=" + root,
+		"XDG_STATE_HOME=" + filepath.Join(root, "state"),
+		"XDG_RUNTIME_DIR=" + filepath.Join(root, "run"),
+		"FAKE_FZF_SELECT=notes.txt",
+	}, "-d", dir)
+
+	var picker bool
+	for _, line := range journal {
+		if strings.HasPrefix(line, "picker ") && strings.Contains(line, "fzf ") {
+			picker = true
+		}
+	}
+	if !picker {
+		t.Errorf("journal has no fzf picker run:\n%s", strings.Join(journal, "\n"))
+	}
+	if !journalHas(journal, "lookup", "test-editor", filepath.Join(stubs, "test-editor")) {
+		t.Errorf("journal has no lookup of the editor stub:\n%s", strings.Join(journal, "\n"))
+	}
+	if !journalHas(journal, "launch", filepath.Join(stubs, "test-editor"), file) {
+		t.Errorf("journal has no launch of the editor for %s:\n%s", file, strings.Join(journal, "\n"))
+	}
+	for _, line := range journal {
+		if strings.HasPrefix(line, "launch ") && !strings.HasPrefix(line, "launch '"+stubs+string(filepath.Separator)) {
+			t.Errorf("launch outside the stub directory: %s", line)
+		}
+	}
+}
+
+// useFakeExec включает режим --fake-exec в самом тесте до его конца
+func useFakeExec(t *testing.T, stubs string) {
+	t.Helper()
+	for _, name := range []string{"PATH", "FZF_OPEN_REAL_PATH", "FZF_OPEN_FAKE_JOURNAL"} {
+		t.Setenv(name, os.Getenv(name))
+	}
+	t.Cleanup(func() {
+		fakeExecDir = ""
+		clearPathCache()
+	})
+	if err := setupFakeExec(stubs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readJournal возвращает строки журнала --fake-exec
+func readJournal(t *testing.T, stubs string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(stubs, fakeExecJournal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestFakeExecClearsPathCache(t *testing.T) {
+	stubs := t.TempDir()
+	writeStub(t, stubs, "fzf", fakeFzf)
+
+	// Путь, который init нашёл в настоящем PATH до --fake-exec
+	pathCacheOnce.Do(func() {})
+	pathCacheLock.Lock()
+	pathCache["fzf"] = "/usr/bin/fzf"
+	pathCacheLock.Unlock()
+
+	useFakeExec(t, stubs)
+	path, err := cachedLookPath("fzf")
+	if want := filepath.Join(stubs, "fzf"); err != nil || path != want {
+		t.Errorf("cachedLookPath(fzf) = %q, %v; want the stub %q", path, err, want)
+	}
+}
+
+func TestLaunchFirstOrder(t *testing.T) {
+	t.Setenv("DISPLAY", ":0")
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	specs := []launchSpec{
+		{Key: assocTextEditor, Command: "missing"},
+		{Key: assocTextEditor, Command: "first"},
+		{Key: assocTextEditor, Command: "second"},
+		{Key: assocTextEditor, Command: "third"},
+	}
+
+	tests := []struct {
+		name  string
+		first string
+		want  string
+	}{
+		// Запускается первый найденный кандидат, даже если следующие тоже есть
+		{"first available", "#!/bin/sh\nexit 0\n", "first"},
+		// Кандидат, который не запустился, пропускается, и пробуется следующий
+		{"fallback", "#!/nonexistent/interpreter\n", "second"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubs := t.TempDir()
+			writeStub(t, stubs, "first", tt.first)
+			writeStub(t, stubs, "second", "#!/bin/sh\nexit 0\n")
+			writeStub(t, stubs, "third", "#!/bin/sh\nexit 0\n")
+			useFakeExec(t, stubs)
+
+			if err := launchFirst(context.Background(), file, specs...); err != nil {
+				t.Fatal(err)
+			}
+			journal := readJournal(t, stubs)
+			if !journalHas(journal, "lookup", "missing", "(missing)") {
+				t.Errorf("journal has no failed lookup of the missing candidate:\n%s", strings.Join(journal, "\n"))
+			}
+			var launched []string
+			for _, line := range journal {
+				if rest, ok := strings.CutPrefix(line, "launch "); ok {
+					launched = append(launched, rest)
+				}
+			}
+			if len(launched) == 0 || !strings.HasPrefix(launched[len(launched)-1], shellQuote(filepath.Join(stubs, tt.want))+" ") {
+				t.Errorf("launched %q, want %s last", launched, tt.want)
+			}
+			for _, line := range launched {
+				if strings.HasPrefix(line, shellQuote(filepath.Join(stubs, "third"))) {
+					t.Errorf("launched the last candidate after an earlier one started: %s", line)
+				}
+			}
+		})
+	}
+}
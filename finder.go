@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Значения ключа finder - программы нечёткого поиска, в которых выбирается файл
+const (
+	finderFzf  = "fzf"
+	finderSkim = "sk"
+	finderFzy  = "fzy"
+	finderPeco = "peco"
+	// finderBuiltin - встроенный выбор (подкоманда select); он же используется
+	// вместо fzf, если тот не установлен
+	finderBuiltin = "builtin"
+)
+
+// defaultFzfCommand - fzf_command по умолчанию
+const defaultFzfCommand = "fzf --ansi --prompt='Select file> ' --no-multi"
+
+// finder - программа выбора файла. fzf-open описывает сессию выбора
+// параметрами fzf, а finder переводит их во флаги своей программы и
+// отбрасывает то, чего она не умеет.
+type finder interface {
+	// defaultCommand заменяет fzf_command, если тот не изменён
+	defaultCommand() string
+	// sourceEnv - переменная окружения с командой списка файлов; пусто -
+	// список подаётся на стандартный ввод
+	sourceEnv() string
+	// fzfFlags сообщает, понимает ли программа флаги fzf из [picker.fzf]
+	fzfFlags() bool
+	// adapt убирает из параметров то, чего программа не поддерживает
+	adapt(o pickerOptions) pickerOptions
+	// args возвращает флаги программы для параметров
+	args(o pickerOptions) []string
+	// menuArgv возвращает команду меню fzfMenu вместе с программой
+	menuArgv(prompt string, allowQuery bool) []string
+}
+
+// finders - поддерживаемые программы по значению ключа finder
+var finders = map[string]finder{
+	finderFzf:     fzfFinder{},
+	finderSkim:    skimFinder{},
+	finderFzy:     fzyFinder{},
+	finderPeco:    pecoFinder{},
+	finderBuiltin: builtinFinder{},
+}
+
+// checkFinder проверяет значение ключа finder
+func checkFinder(value string) error {
+	if _, ok := finders[value]; ok || value == "" {
+		return nil
+	}
+	return fmt.Errorf("finder: unknown value %q (expected %s, %s, %s, %s or %s)",
+		value, finderFzf, finderSkim, finderFzy, finderPeco, finderBuiltin)
+}
+
+var (
+	fzfMissingOnce sync.Once
+	fzfMissing     bool
+)
+
+// finderName возвращает имя программы выбора - оно же имя её исполняемого
+// файла. Если fzf не установлен, а fzf_command не изменён, выбор идёт во
+// встроенной программе.
+func finderName() string {
+	// Полноэкранный fzf экранный чтец не прочитает
+	if accessibleMode {
+		return finderBuiltin
+	}
+	name := finderFzf
+	if _, ok := finders[defaultConfig.Finder]; ok {
+		name = defaultConfig.Finder
+	}
+	if name == finderFzf && defaultConfig.FzfCommand == defaultFzfCommand {
+		fzfMissingOnce.Do(func() {
+			_, err := cachedLookPath(finderFzf)
+			fzfMissing = err != nil
+		})
+		if fzfMissing {
+			return finderBuiltin
+		}
+	}
+	return name
+}
+
+// currentFinder возвращает программу выбора из ключа finder
+func currentFinder() finder {
+	return finders[finderName()]
+}
+
+// usingFzf сообщает, что файл выбирается в fzf: режим сервера, навигация и
+// переключатель .gitignore держатся на его действиях reload и transform
+func usingFzf() bool {
+	return finderName() == finderFzf
+}
+
+// finderCommand возвращает команду программы выбора: fzf_command или, если
+// он не изменён, а finder - не fzf, команду этой программы по умолчанию
+func finderCommand() string {
+	if accessibleMode || !usingFzf() && defaultConfig.FzfCommand == defaultFzfCommand {
+		return currentFinder().defaultCommand()
+	}
+	return defaultConfig.FzfCommand
+}
+
+// fzfFinder - fzf
+type fzfFinder struct{}
+
+func (fzfFinder) defaultCommand() string              { return defaultFzfCommand }
+func (fzfFinder) sourceEnv() string                   { return "FZF_DEFAULT_COMMAND" }
+func (fzfFinder) fzfFlags() bool                      { return true }
+func (fzfFinder) adapt(o pickerOptions) pickerOptions { return o }
+func (fzfFinder) args(o pickerOptions) []string       { return o.args() }
+func (fzfFinder) menuArgv(prompt string, allowQuery bool) []string {
+	args := []string{finderFzf, "--prompt", prompt, "--no-multi", "--height", "~40%", "--layout", "reverse"}
+	if allowQuery {
+		args = append(args, "--print-query")
+	}
+	return args
+}
+
+// skimFinder - skim (sk): флаги как у fzf, но нет --listen, встроенного
+// обходчика и действий reload и transform
+type skimFinder struct{}
+
+func (skimFinder) defaultCommand() string { return "sk --ansi --prompt='Select file> ' --no-multi" }
+func (skimFinder) sourceEnv() string      { return "SKIM_DEFAULT_COMMAND" }
+func (skimFinder) fzfFlags() bool         { return true }
+
+func (skimFinder) adapt(o pickerOptions) pickerOptions {
+	o.Listen, o.Walker, o.Binds = "", "", nil
+	// Высота skim - только проценты или строки, без "~"
+	o.Height = strings.TrimPrefix(o.Height, "~")
+	return o
+}
+
+func (skimFinder) args(o pickerOptions) []string { return o.args() }
+
+func (skimFinder) menuArgv(prompt string, allowQuery bool) []string {
+	args := []string{finderSkim, "--prompt", prompt, "--no-multi", "--height", "40%", "--layout", "reverse"}
+	if allowQuery {
+		args = append(args, "--print-query")
+	}
+	return args
+}
+
+// fzyFinder - fzy: только приглашение и запрос; список читается со
+// стандартного ввода, Enter без совпадений возвращает сам запрос
+type fzyFinder struct{}
+
+func (fzyFinder) defaultCommand() string { return "fzy --prompt='Select file> '" }
+func (fzyFinder) sourceEnv() string      { return "" }
+func (fzyFinder) fzfFlags() bool         { return false }
+
+func (fzyFinder) adapt(o pickerOptions) pickerOptions {
+	return pickerOptions{Prompt: o.Prompt, Query: o.Query}
+}
+
+func (fzyFinder) args(o pickerOptions) []string {
+	var args []string
+	if o.Prompt != "" {
+		args = append(args, "--prompt="+o.Prompt)
+	}
+	if o.Query != "" {
+		args = append(args, "--query="+o.Query)
+	}
+	return args
+}
+
+func (fzyFinder) menuArgv(prompt string, _ bool) []string {
+	return []string{finderFzy, "--prompt", prompt, "--lines", "10"}
+}
+
+// pecoFinder - peco: приглашение, запрос и --print-query; несколько строк
+// отмечаются всегда, а список читается со стандартного ввода
+type pecoFinder struct{}
+
+func (pecoFinder) defaultCommand() string { return "peco --prompt='Select file>'" }
+func (pecoFinder) sourceEnv() string      { return "" }
+func (pecoFinder) fzfFlags() bool         { return false }
+
+func (pecoFinder) adapt(o pickerOptions) pickerOptions {
+	return pickerOptions{Prompt: o.Prompt, Query: o.Query, PrintQuery: o.PrintQuery}
+}
+
+func (pecoFinder) args(o pickerOptions) []string {
+	var args []string
+	if o.Prompt != "" {
+		args = append(args, "--prompt="+o.Prompt)
+	}
+	if o.Query != "" {
+		args = append(args, "--query="+o.Query)
+	}
+	if o.PrintQuery {
+		args = append(args, "--print-query")
+	}
+	return args
+}
+
+func (pecoFinder) menuArgv(prompt string, allowQuery bool) []string {
+	args := []string{finderPeco, "--prompt", prompt}
+	if allowQuery {
+		args = append(args, "--print-query")
+	}
+	return args
+}
+
+// builtinFinder - встроенный выбор: подкоманда select того же исполняемого
+// файла. Понимает приглашение, запрос, заголовок, --expect, --print-query и
+// --multi; список читается со стандартного ввода.
+type builtinFinder struct{}
+
+func (builtinFinder) defaultCommand() string {
+	return quoteCommand(builtinArgv()) + " --prompt='Select file> '"
+}
+
+func (builtinFinder) sourceEnv() string { return "" }
+func (builtinFinder) fzfFlags() bool    { return false }
+
+func (builtinFinder) adapt(o pickerOptions) pickerOptions {
+	return pickerOptions{Prompt: o.Prompt, Query: o.Query, Header: o.Header,
+		Expect: o.Expect, PrintQuery: o.PrintQuery, Multi: o.Multi}
+}
+
+func (builtinFinder) args(o pickerOptions) []string { return o.args() }
+
+func (builtinFinder) menuArgv(prompt string, allowQuery bool) []string {
+	args := append(builtinArgv(), "--prompt", prompt)
+	if allowQuery {
+		args = append(args, "--print-query")
+	}
+	return args
+}
+
+// builtinArgv возвращает команду подкоманды select
+func builtinArgv() []string {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "fzf-open"
+	}
+	if accessibleMode {
+		return []string{exe, "select", "--plain"}
+	}
+	return []string{exe, "select"}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// Пункты меню для файла журнала
+const (
+	menuFollowLog = "Open live (follow new lines)"
+	menuOpenLog   = "Open"
+)
+
+// followCommand следит за журналом, если log_viewer не задан: less +F
+// показывает новые строки по мере записи, как tail -f, а Ctrl-C возвращает к
+// обычному просмотру с поиском
+const followCommand = "less +F"
+
+// offerFollowLog предлагает открыть журнал (*.log) в режиме слежения рядом с
+// обычным открытием. Возвращает true, если файл обработан.
+func offerFollowLog(ctx context.Context, filePath string, ext string) (bool, error) {
+	if ext != "log" || !isInteractive() {
+		return false, nil
+	}
+
+	choice, err := fzfMenu(filepath.Base(filePath)+" is a log> ", false, menuFollowLog, menuOpenLog, menuCancel)
+	if err != nil || choice == "" || choice == menuCancel {
+		return true, nil
+	}
+	if choice != menuFollowLog {
+		return false, nil
+	}
+
+	if err := launchFirst(ctx, filePath, followSpecs()...); err != nil {
+		return true, fmt.Errorf("could not follow %q: %w", filePath, err)
+	}
+	return true, nil
+}
+
+// followSpecs возвращает кандидатов для слежения за журналом: log_viewer, а
+// если он не задан - less +F в терминале
+func followSpecs() []launchSpec {
+	if len(appAssociations.LogViewer) > 0 {
+		return associationSpecs(assocLogViewer)
+	}
+	return []launchSpec{{Key: assocLogViewer, Command: followCommand, TUI: true}}
+}
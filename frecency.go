@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frecencyFileName - база частоты и давности открытий в stateDir: строки
+// "путь<TAB>число открытий<TAB>время последнего открытия (Unix)"
+const frecencyFileName = "frecency"
+
+// frecencyLimit - сколько файлов хранит база; лишние с наименьшим весом удаляются
+const frecencyLimit = 1000
+
+// frecencyEntry - запись базы о файле
+type frecencyEntry struct {
+	path  string
+	count int
+	last  int64
+}
+
+// score возвращает вес файла: число открытий с поправкой на давность
+// последнего, как в z и zoxide
+func (e frecencyEntry) score(now time.Time) float64 {
+	age := now.Sub(time.Unix(e.last, 0))
+	weight := 0.25
+	switch {
+	case age < time.Hour:
+		weight = 4
+	case age < 24*time.Hour:
+		weight = 2
+	case age < 7*24*time.Hour:
+		weight = 0.5
+	}
+	return float64(e.count) * weight
+}
+
+// frecencyFilePath возвращает путь к базе
+func frecencyFilePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, frecencyFileName), nil
+}
+
+// readFrecency читает базу; отсутствующий файл - пустая база
+func readFrecency(path string) ([]frecencyEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []frecencyEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		count, err1 := strconv.Atoi(fields[1])
+		last, err2 := strconv.ParseInt(fields[2], 10, 64)
+		if err1 != nil || err2 != nil || !filepath.IsAbs(fields[0]) {
+			continue
+		}
+		entries = append(entries, frecencyEntry{path: fields[0], count: count, last: last})
+	}
+	return entries, scanner.Err()
+}
+
+// recordFrecency учитывает открытие файла в базе (ключ frecency). Ошибки
+// только записываются в лог: открытию файла они не мешают.
+func recordFrecency(filePath string) {
+	if !defaultConfig.Frecency || kioskMode || strings.ContainsAny(filePath, "\t\n") {
+		return
+	}
+	if err := addFrecency(filePath, time.Now()); err != nil {
+		fmt.Fprintf(logOut, "Warning: could not update the frecency database: %v\n", err)
+	}
+}
+
+// addFrecency увеличивает счётчик файла и сохраняет базу
+func addFrecency(filePath string, now time.Time) error {
+	path, err := frecencyFilePath()
+	if err != nil {
+		return err
+	}
+	if err := ensurePrivateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	return withFileLock(path, func() error {
+		entries, err := readFrecency(path)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for i := range entries {
+			if entries[i].path == filePath {
+				entries[i].count++
+				entries[i].last = now.Unix()
+				found = true
+			}
+		}
+		if !found {
+			entries = append(entries, frecencyEntry{path: filePath, count: 1, last: now.Unix()})
+		}
+
+		sortFrecency(entries, now)
+		if len(entries) > frecencyLimit {
+			entries = entries[:frecencyLimit]
+		}
+
+		var b strings.Builder
+		for _, e := range entries {
+			fmt.Fprintf(&b, "%s\t%d\t%d\n", e.path, e.count, e.last)
+		}
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(b.String()), 0o600); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return nil
+	})
+}
+
+// sortFrecency упорядочивает записи по убыванию веса
+func sortFrecency(entries []frecencyEntry, now time.Time) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].score(now) > entries[j].score(now)
+	})
+}
+
+// frecencyRanked сообщает, что список начинается с файлов из базы: база
+// хранит только файлы, поэтому в режимах -D и --grep порядок не меняется
+func frecencyRanked() bool {
+	return defaultConfig.Frecency && !dirsMode && !grepMode
+}
+
+// rankedSourceCommand пропускает список файлов через подкоманду rank, если
+// включён ключ frecency. fzf с --tiebreak=index показывает одинаково
+// подходящие файлы в порядке списка, то есть часто открываемые - первыми.
+// Конвейер выполняет sh, какой бы ни была оболочка пользователя.
+func rankedSourceCommand(source string) string {
+	if !frecencyRanked() || source == "" {
+		return source
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return source
+	}
+	rank := shellQuote(exe) + " rank"
+	if nulSeparated() {
+		rank += " -0"
+	}
+	return shCommand("(" + source + ") | " + rank)
+}
+
+// addFrecencyTiebreak упорядочивает одинаково подходящие файлы по входному
+// списку, если его упорядочила подкоманда rank или, без шелла, writeDirectList,
+// или если он отсортирован флагом --sort
+func addFrecencyTiebreak(opts *pickerOptions) {
+	if !frecencyRanked() && !sortedListing() {
+		return
+	}
+	if _, ok := fzfOptions["tiebreak"]; ok {
+		return
+	}
+	opts.Tiebreak = "index"
+}
+
+// runRank реализует подкоманду rank: сначала печатает файлы текущего каталога
+// из базы по убыванию веса, затем - строки стандартного ввода, которых среди
+// них не было. Список передаётся fzf по мере чтения; с -0 строки разделяет NUL.
+func runRank(args []string) int {
+	sep := byte('\n')
+	switch {
+	case len(args) == 1 && args[0] == "-0":
+		sep = 0
+	case len(args) != 0:
+		fmt.Fprintln(os.Stderr, "usage: fzf-open rank [-0] < paths")
+		return 2
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	printed := map[string]bool{}
+	if cwd, err := os.Getwd(); err == nil {
+		for _, rel := range frecentFilesIn(cwd) {
+			out.WriteString(rel)
+			out.WriteByte(sep)
+			printed[rel] = true
+		}
+		out.Flush()
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	for {
+		line, err := in.ReadString(sep)
+		if line != "" {
+			rel := strings.TrimPrefix(strings.TrimSuffix(line, string(sep)), "./")
+			if !printed[rel] {
+				out.WriteString(rel)
+				out.WriteByte(sep)
+			}
+		}
+		if err == io.EOF {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+}
+
+// frecentFilesIn возвращает существующие файлы из базы внутри dir путями
+// относительно dir, по убыванию веса
+func frecentFilesIn(dir string) []string {
+	path, err := frecencyFilePath()
+	if err != nil {
+		return nil
+	}
+	entries, err := readFrecency(path)
+	if err != nil {
+		return nil
+	}
+	sortFrecency(entries, time.Now())
+
+	var files []string
+	for _, e := range entries {
+		rel, err := filepath.Rel(dir, e.path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if fi, err := os.Stat(e.path); err == nil && !fi.IsDir() {
+			files = append(files, filepath.ToSlash(rel))
+		}
+	}
+	return files
+}
+
+// runJump реализует подкоманду jump: без fzf открывает файл из базы, который
+// подходит под запрос и открывался чаще и позже других, как z для каталогов
+func runJump(args []string) int {
+	cfg := &Config{
+		Terminal:      defaultConfig.Terminal,
+		StartingDir:   defaultConfig.StartingDir,
+		UseShellIC:    true,
+		explicitFlags: map[string]bool{},
+	}
+	fset := flag.NewFlagSet("jump", flag.ExitOnError)
+	fset.StringVar(&cfg.Profile, "p", "", "Configuration profile to use")
+	fset.BoolVar(&cfg.Wait, "w", false, "Wait for the launched application to exit")
+	fset.Parse(args)
+	if fset.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: fzf-open jump [-p profile] [-w] <query>")
+		return 2
+	}
+
+	openLog()
+	if err := setupConfig(cfg); err != nil {
+		fmt.Fprintf(logOut, "Error: %v\n", err)
+		return 1
+	}
+	if kioskMode {
+		fmt.Fprintln(logOut, "Error: jump is not available in kiosk mode")
+		return 1
+	}
+
+	path, err := frecencyFilePath()
+	if err != nil {
+		fmt.Fprintf(logOut, "Error: %v\n", err)
+		return 1
+	}
+	entries, err := readFrecency(path)
+	if err != nil {
+		fmt.Fprintf(logOut, "Error: %v\n", err)
+		return 1
+	}
+	query := strings.Join(fset.Args(), " ")
+	matches := frecencyMatches(entries, strings.Fields(query))
+	if len(matches) == 0 {
+		fmt.Fprintf(logOut, "Error: no file in the frecency database matches %q\n", query)
+		return 1
+	}
+
+	ctx := context.Background()
+	exitCode := 0
+	if err := openFileWithConfiguredApp(ctx, matches[0]); err != nil {
+		if !runFailureMenu(ctx, matches[0]) {
+			exitCode = 1
+		}
+	}
+	if cfg.Wait {
+		waitLaunched()
+	}
+	return exitCode
+}
+
+// frecencyMatches возвращает существующие файлы базы, подходящие под слова
+// запроса, по убыванию веса. Слова ищутся в пути по порядку, последнее - в
+// имени файла; заглавная буква в запросе включает учёт регистра, как в fzf.
+func frecencyMatches(entries []frecencyEntry, terms []string) []string {
+	caseSensitive := strings.ToLower(strings.Join(terms, "")) != strings.Join(terms, "")
+	sortFrecency(entries, time.Now())
+
+	var files []string
+	for _, e := range entries {
+		path := e.path
+		if !caseSensitive {
+			path = strings.ToLower(path)
+		}
+		if !matchTerms(path, terms, caseSensitive) {
+			continue
+		}
+		if fi, err := os.Stat(e.path); err == nil && !fi.IsDir() {
+			files = append(files, e.path)
+		}
+	}
+	return files
+}
+
+// matchTerms сообщает, встречаются ли слова в пути по порядку, причём
+// последнее - в имени файла
+func matchTerms(path string, terms []string, caseSensitive bool) bool {
+	pos := 0
+	for i, term := range terms {
+		if !caseSensitive {
+			term = strings.ToLower(term)
+		}
+		if i == len(terms)-1 {
+			base := strings.LastIndexAny(path, `/\`) + 1
+			if base < pos {
+				base = pos
+			}
+			return strings.Contains(path[base:], term)
+		}
+		n := strings.Index(path[pos:], term)
+		if n < 0 {
+			return false
+		}
+		pos += n + len(term)
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runListCommand выполняет команду списка так, как её выполняет fzf - через
+// $SHELL -c, - для каждой установленной оболочки из shells и возвращает вывод
+func runListCommand(t *testing.T, dir, command string, shells ...string) map[string]string {
+	t.Helper()
+	out := map[string]string{}
+	for _, shell := range shells {
+		path, err := exec.LookPath(shell)
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, "-c", command)
+		cmd.Dir = dir
+		data, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%s -c %s: %v", shell, command, err)
+		}
+		out[shell] = string(data)
+	}
+	return out
+}
+
+func TestRankedSourceCommandUnderFish(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "files")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	useConfig(t, writeConfig(t, root, "config.toml", "frecency = true\nsource = \"echo b.txt; echo a.txt\"\n"))
+	if err := loadConfig(""); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL", "/usr/bin/fish")
+	t.Setenv("FZF_OPEN_TEST_MAIN", "1")
+	t.Setenv("XDG_STATE_HOME", filepath.Join(root, "state"))
+	t.Setenv("FZF_OPEN_PER_HOST", "0")
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := addFrecency(file, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Скобки и конвейер в fish значат другое: снаружи остаётся только вызов sh
+	command := rankedSourceCommand(defaultConfig.Source)
+	if !strings.HasPrefix(command, quoteCommand([]string{"sh", "-c"})+" ") {
+		t.Fatalf("rankedSourceCommand() = %q, want a sh -c command", command)
+	}
+	for shell, got := range runListCommand(t, dir, command, "sh", "fish") {
+		if want := "a.txt\nb.txt\n"; got != want {
+			t.Errorf("%s: list = %q, want %q", shell, got, want)
+		}
+	}
+}
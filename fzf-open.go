@@ -9,10 +9,11 @@ import (
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 )
 
@@ -22,26 +23,184 @@ import (
 
 // DefaultConfig содержит конфигурационные константы
 type DefaultConfig struct {
-	Terminal     string
-	StartingDir  string
-	WinTitleFlag string
-	WinTitle     string
-	FzfCommand   string
-	ShellToUse   string
+	Terminal     string `toml:"terminal"`
+	StartingDir  string `toml:"starting_dir"`
+	WinTitleFlag string `toml:"win_title_flag"`
+	WinTitle     string `toml:"win_title"`
+	FzfCommand   string `toml:"fzf_command"`
+	ShellToUse   string `toml:"-"`
+
+	// Roots - корни, между которыми в режиме --loop переключает NextRootKey
+	Roots       []string `toml:"roots,omitempty"`
+	NextRootKey string   `toml:"next_root_key"`
+
+	// UseMimeapps - брать приложения по умолчанию из mimeapps.list рабочего стола
+	UseMimeapps bool `toml:"use_mimeapps"`
+	// UseMailcap - брать команды из ~/.mailcap и /etc/mailcap
+	UseMailcap bool `toml:"use_mailcap"`
+
+	// WindowWait - сколько терминал, открытый флагом -n, ждёт окна запущенного приложения
+	WindowWait time.Duration `toml:"window_wait"`
+
+	// Preview - показывать в fzf окно превью (подкоманда preview)
+	Preview bool `toml:"preview"`
+
+	// RecentFiles - добавлять открытые файлы в recently-used.xbel
+	RecentFiles bool `toml:"recent_files"`
+
+	// Source - источник списка файлов: fd, rg, find, walk, auto или команда
+	// шелла; пусто - список строит fzf. Hidden и NoIgnore - показывать скрытые
+	// файлы и не учитывать .gitignore во встроенных источниках.
+	Source   string `toml:"source,omitempty"`
+	Hidden   bool   `toml:"hidden,omitempty"`
+	NoIgnore bool   `toml:"no_ignore,omitempty"`
+	// Sort - порядок списка: name, mtime, size или none, как флаг --sort
+	Sort string `toml:"sort,omitempty"`
+	// IgnoreToggleKey - клавиша fzf, которая включает и выключает .gitignore
+	IgnoreToggleKey string `toml:"ignore_toggle_key"`
+	// EnterDirKey и ParentDirKey - клавиши fzf, которые делают корнем списка
+	// каталог под курсором и родительский каталог
+	EnterDirKey  string `toml:"enter_dir_key"`
+	ParentDirKey string `toml:"parent_dir_key"`
+	// QueryRoots - первое слово запроса вида "~/Downloads/" или "@docs" с
+	// пробелом после него делает корнем списка этот каталог или корень из Roots
+	QueryRoots bool `toml:"query_roots,omitempty"`
+
+	// Kiosk включает режим киоска, как флаг --kiosk; KioskRoots - каталоги,
+	// которые в нём можно просматривать
+	Kiosk      bool     `toml:"kiosk,omitempty"`
+	KioskRoots []string `toml:"kiosk_roots,omitempty"`
+
+	// Finder - программа выбора файла: fzf, sk, fzy или peco
+	Finder string `toml:"finder,omitempty"`
+
+	// PowerSaver - режим энергосбережения: auto (от батареи), on или off
+	PowerSaver string `toml:"power_saver,omitempty"`
+
+	// FinderLayout - вид fzf в текущем терминале: fullscreen или inline (под
+	// строкой приглашения, высотой InlineHeight)
+	FinderLayout string `toml:"layout"`
+	InlineHeight string `toml:"inline_height"`
+	// Mouse - мышь в fzf: on (как в fzf), click (открывать щелчком) или off
+	Mouse string `toml:"mouse,omitempty"`
+
+	// Frecency - запоминать, как часто и как давно открывались файлы, и
+	// показывать часто открываемые первыми
+	Frecency bool `toml:"frecency,omitempty"`
+	// QueryHistory - сохранять запросы fzf между запусками (Ctrl-P, Ctrl-N)
+	QueryHistory bool `toml:"query_history,omitempty"`
+	// ResumeQuery - запоминать и запрос последнего выбора для -r
+	ResumeQuery bool `toml:"resume_query,omitempty"`
+	// Accessible - режим для экранных чтецов, как флаг --accessible
+	Accessible bool `toml:"accessible,omitempty"`
+	// Colors - раскрашивать файлы в списке по категориям (LS_COLORS или
+	// встроенная палитра)
+	Colors bool `toml:"colors,omitempty"`
+	// Icons - ставить перед файлами в списке значки Nerd Fonts
+	Icons bool `toml:"icons,omitempty"`
+
+	// SystemFiles - как открывать в текстовом редакторе системный файл, который
+	// нельзя записать: read-only, sudoedit, ask или off
+	SystemFiles string `toml:"system_files,omitempty"`
+
+	// BackupDir - каталог резервных копий правил с backup = true; пусто -
+	// $XDG_STATE_HOME/fzf-open/backups
+	BackupDir string `toml:"backup_dir,omitempty"`
+
+	// TrustedProjects - каталоги, файлам .fzf-open.toml в которых разрешено
+	// задавать запускаемые команды (fzf_command, source, actions и другие)
+	TrustedProjects []string `toml:"trusted_projects,omitempty"`
 }
 
 // AppAssociations содержит ассоциации приложений с типами файлов
 type AppAssociations struct {
-	TextEditor        string
-	PDFViewer         string
-	ImageViewer       string
-	VideoPlayer       string
-	SpreadsheetEditor string
-	WebBrowser        string
-	DocxViewer        string
-	FallbackOpener    string
+	TextEditor        CommandList `toml:"text_editor"`
+	PDFViewer         CommandList `toml:"pdf_viewer"`
+	ImageViewer       CommandList `toml:"image_viewer"`
+	VideoPlayer       CommandList `toml:"video_player"`
+	SpreadsheetEditor CommandList `toml:"spreadsheet_editor"`
+	WebBrowser        CommandList `toml:"web_browser"`
+	DocxViewer        CommandList `toml:"docx_viewer"`
+	EbookReader       CommandList `toml:"ebook_reader"`
+	FontViewer        CommandList `toml:"font_viewer"`
+	ArchiveManager    CommandList `toml:"archive_manager"`
+	TorrentClient     CommandList `toml:"torrent_client"`
+	DirectoryOpener   CommandList `toml:"directory_opener"`
+	LogViewer         CommandList `toml:"log_viewer"`
+	FallbackOpener    CommandList `toml:"fallback_opener"`
 }
 
+// CommandList - команда ассоциации или цепочка запасных команд. В файле
+// конфигурации задаётся строкой или массивом строк.
+type CommandList []string
+
+// UnmarshalTOML принимает как одну команду, так и массив команд
+func (l *CommandList) UnmarshalTOML(v any) error {
+	var items []any
+	switch v := v.(type) {
+	case string:
+		items = []any{v}
+	case []any:
+		items = v
+	default:
+		return fmt.Errorf("expected a command or a list of commands, got %T", v)
+	}
+
+	list := make(CommandList, 0, len(items))
+	for _, item := range items {
+		command, ok := item.(string)
+		if !ok {
+			return fmt.Errorf("expected a command string, got %T", item)
+		}
+		if strings.TrimSpace(command) != "" {
+			list = append(list, command)
+		}
+	}
+	*l = list
+	return nil
+}
+
+func (l CommandList) String() string {
+	return strings.Join(l, ", ")
+}
+
+// available возвращает первую команду цепочки, найденную в PATH, или первую
+// команду, если не найдена ни одна
+func (l CommandList) available() string {
+	for _, command := range l {
+		parts := strings.Fields(command)
+		if len(parts) == 0 {
+			continue
+		}
+		if _, err := cachedLookPath(parts[0]); err == nil {
+			return command
+		}
+	}
+	if len(l) == 0 {
+		return ""
+	}
+	return l[0]
+}
+
+// Ключи ассоциаций - совпадают с toml-тегами AppAssociations и именами
+// в таблицах [associations] и [timeouts] файла конфигурации
+const (
+	assocTextEditor        = "text_editor"
+	assocPDFViewer         = "pdf_viewer"
+	assocImageViewer       = "image_viewer"
+	assocVideoPlayer       = "video_player"
+	assocSpreadsheetEditor = "spreadsheet_editor"
+	assocWebBrowser        = "web_browser"
+	assocDocxViewer        = "docx_viewer"
+	assocEbookReader       = "ebook_reader"
+	assocFontViewer        = "font_viewer"
+	assocArchiveManager    = "archive_manager"
+	assocTorrentClient     = "torrent_client"
+	assocDirectoryOpener   = "directory_opener"
+	assocLogViewer         = "log_viewer"
+	assocFallbackOpener    = "fallback_opener"
+)
+
 // MIME типы
 const (
 	mimeTextPrefix        = "text/"
@@ -60,31 +219,59 @@ const (
 	mimeODS               = "application/vnd.oasis.opendocument.spreadsheet"
 	mimeExcel             = "application/vnd.ms-excel"
 	mimeExcelX            = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	mimeEpub              = "application/epub+zip"
+	mimeDjvu              = "image/vnd.djvu"
+	mimeMobi              = "application/x-mobipocket-ebook"
+	mimeFontPrefix        = "font/"
+	mimeFontTTF           = "application/x-font-ttf"
+	mimeFontOTF           = "application/x-font-otf"
+	mimeBitTorrent        = "application/x-bittorrent"
 )
 
+// archiveMIMETypes - MIME типы архивов
+var archiveMIMETypes = map[string]bool{
+	"application/zip":              true,
+	"application/x-tar":            true,
+	"application/gzip":             true,
+	"application/x-compressed-tar": true,
+	"application/x-bzip2":          true,
+	"application/x-xz":             true,
+	"application/zstd":             true,
+	"application/x-7z-compressed":  true,
+	"application/vnd.rar":          true,
+	"application/x-rar":            true,
+}
+
 var (
 	defaultConfig = DefaultConfig{
-		Terminal:     "alacritty",
+		Terminal:     defaultTerminal(),
 		StartingDir:  "~",
 		WinTitleFlag: "--title",
 		WinTitle:     "fzf-open-run",
-		FzfCommand:   "fzf --ansi --prompt='Select file> ' --no-multi",
+		FzfCommand:   defaultFzfCommand,
 		ShellToUse:   "",
+		NextRootKey:  "ctrl-t",
+
+		IgnoreToggleKey: "alt-i",
+		EnterDirKey:     "ctrl-l",
+		ParentDirKey:    "ctrl-h",
+
+		Finder:       finderFzf,
+		FinderLayout: finderFullscreen,
+		InlineHeight: "40%",
 	}
 
 	appAssociations = AppAssociations{
-		TextEditor:        "zeditor",
-		PDFViewer:         "zathura",
-		ImageViewer:       "eog",
-		VideoPlayer:       "vlc",
-		SpreadsheetEditor: "wps",
-		WebBrowser:        "thorium-browser",
-		DocxViewer:        "wps",
-		FallbackOpener:    "xdg-open",
+		TextEditor:        defaultTextEditors(),
+		PDFViewer:         CommandList{"zathura"},
+		ImageViewer:       CommandList{"eog"},
+		VideoPlayer:       CommandList{"vlc"},
+		SpreadsheetEditor: CommandList{"wps"},
+		WebBrowser:        defaultWebBrowsers(),
+		DocxViewer:        CommandList{"wps"},
+		FallbackOpener:    defaultFallbackOpeners,
 	}
 
-	tmpFzfOutput = "/tmp/fzf-open"
-
 	pathCache     = make(map[string]string, 32)
 	pathCacheLock sync.RWMutex
 
@@ -96,6 +283,9 @@ var (
 	shFlags           = []string{"-ic"}
 	defaultShellFlags = []string{"-c"}
 
+	// validShells - оболочки, которые выполнят команду запуска fzf. csh и tcsh
+	// (оболочка root во FreeBSD) не понимают синтаксис POSIX-шелла, вместо них
+	// ищется другая.
 	validShells = map[string]bool{
 		"bash": true,
 		"zsh":  true,
@@ -103,21 +293,22 @@ var (
 		"dash": true,
 		"sh":   true,
 		"ksh":  true,
-		"csh":  true,
-		"tcsh": true,
+		"mksh": true,
+		"oksh": true,
 	}
 
 	shellDetectOnce sync.Once
 	pathCacheOnce   sync.Once
+
+	// version подставляется при сборке: go build -ldflags "-X main.version=v1.2.3"
+	version = "dev"
 )
 
 func init() {
 	numCPU := runtime.NumCPU()
 	runtime.GOMAXPROCS(numCPU)
 
-	if u, err := user.Current(); err == nil {
-		userHomeDir = u.HomeDir
-	}
+	userHomeDir, _ = resolveHomeDir()
 
 	go func() {
 		shellDetectOnce.Do(func() {
@@ -127,8 +318,11 @@ func init() {
 
 	go func() {
 		pathCacheOnce.Do(func() {
-			commonCommands := []string{"xdg-mime", "sh", "bash", "zsh", "fish", "fzf",
-				"zeditor", "zathura", "eog", "vlc", "wps", "thorium-browser", "xdg-open"}
+			commonCommands := []string{mimeQueryTool, "sh", "bash", "zsh", "fish", "fzf",
+				"zeditor", "zathura", "eog", "vlc", "wps", "thorium-browser"}
+			for _, opener := range defaultFallbackOpeners {
+				commonCommands = append(commonCommands, strings.Fields(opener)[0])
+			}
 
 			var wg sync.WaitGroup
 			resultChan := make(chan struct {
@@ -166,6 +360,10 @@ func init() {
 // detectUserShell определяет текущую оболочку пользователя и устанавливает ShellToUse
 func detectUserShell() {
 	shellPath := os.Getenv("SHELL")
+	// В cron и после su без -l $SHELL не задан - берётся оболочка входа
+	if shellPath == "" {
+		shellPath, _ = shellFromPasswd(os.Getuid())
+	}
 	if shellPath != "" {
 		shellName := filepath.Base(shellPath)
 		if validShells[shellName] {
@@ -174,7 +372,7 @@ func detectUserShell() {
 		}
 	}
 
-	possibleShells := []string{"zsh", "bash", "fish", "dash", "sh"}
+	possibleShells := fallbackShells()
 
 	resultChan := make(chan string, 1)
 	done := make(chan struct{})
@@ -201,7 +399,7 @@ func detectUserShell() {
 		defaultConfig.ShellToUse = shell
 	case <-time.After(200 * time.Millisecond):
 		defaultConfig.ShellToUse = "sh"
-		fmt.Fprintf(os.Stderr, "Warning: Could not detect user shell, falling back to /bin/sh\n")
+		fmt.Fprintf(logOut, "Warning: Could not detect user shell, falling back to /bin/sh\n")
 	}
 }
 
@@ -210,7 +408,7 @@ func getShellInteractiveFlag(shellName string) []string {
 	switch shellName {
 	case "fish":
 		return fishFlags
-	case "zsh", "bash", "sh", "dash", "ksh":
+	case "zsh", "bash", "sh", "dash", "ksh", "mksh", "oksh":
 		return shFlags
 	default:
 		return defaultShellFlags
@@ -228,38 +426,320 @@ type Config struct {
 	SpawnTerm   bool
 	NoAutoClose bool
 	UseShellIC  bool
+	Profile     string
+	Wait        bool
+	Loop        bool
+	With        string
+	Safe        bool
+	FakeExec    string
+	Output      string
+	Sort        string
+	Multi       bool
+	Kiosk       bool
+	Query       string
+	Recent      bool
+	Grep        bool
+	Snapshots   bool
+	Resume      bool
+	Accessible  bool
+	Details     bool
+	NoIgnore    bool
+	Ignore      bool
+	Media       bool
+	Dirs        bool
+	Server      bool
+	PowerSaver  bool
+	Verbose     bool
+
+	// explicitFlags - флаги, явно заданные в командной строке; их не перекрывает файл конфигурации
+	explicitFlags map[string]bool
+}
+
+// subcommands - подкоманды, которые выполняются вместо запуска выбора файла
+var subcommands = map[string]func(args []string) int{
+	"config":   runConfig,
+	"doctor":   runDoctor,
+	"report":   runReport,
+	"preview":  runPreview,
+	"recent":   runRecent,
+	"files":    runFiles,
+	"rank":     runRank,
+	"colorize": runColorize,
+	"nav":      runNav,
+	"jump":     runJump,
+	"select":   runSelect,
+	"bench":    runBench,
 }
 
 func main() {
+	inheritFakeExec()
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
+	openLog()
 	cfg := initializeAndParseFlags()
 
+	if cfg.FakeExec != "" {
+		if err := setupFakeExec(cfg.FakeExec); err != nil {
+			fmt.Fprintf(logOut, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		// Интерактивный шелл пользователя прочитал бы настоящие rc-файлы
+		cfg.UseShellIC = false
+	}
+
+	if err := setupConfig(cfg); err != nil {
+		fmt.Fprintf(logOut, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.Media && !pickMedia(cfg) {
+		os.Exit(1)
+	}
+	if serverCompatible(cfg) && sendToServer(cfg) {
+		os.Exit(0)
+	}
+	if serverMode {
+		if err := setupServer(); err != nil {
+			fmt.Fprintf(logOut, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var watcher *configWatcher
+	if cfg.Loop {
+		var err error
+		if watcher, err = startConfigWatcher(); err != nil {
+			fmt.Fprintf(logOut, "Warning: configuration hot-reload is unavailable: %v\n", err)
+		} else {
+			defer watcher.Close()
+		}
+	}
+
+	var tabs *rootTabs
+	if cfg.Loop {
+		tabs = newRootTabs(cfg)
+	}
+
+	exitCode := 0
+	for {
+		if watcher != nil {
+			watcher.reloadIfChanged(cfg)
+		}
+
+		picked, code := pickAndOpen(cfg, tabs)
+		if code != 0 {
+			exitCode = code
+		}
+		// Esc в fzf сервера только сбрасывает список, сервер останавливает serverQuitKey
+		if (!picked && (!serverMode || code != 0)) || !cfg.Loop {
+			break
+		}
+	}
+
+	if cfg.Server {
+		stopServer()
+	}
+	offerGVFSUnmount(cfg.Wait)
+	offerEject(cfg.Wait)
+	waitForUserIfNoAutoClose(cfg)
+	os.Exit(exitCode)
+}
+
+// setupConfig применяет файл конфигурации, раскрывает начальный каталог и
+// накладывает настройки проекта
+func setupConfig(cfg *Config) error {
+	powerSaverFlag = cfg.PowerSaver
+	if err := applyFileConfig(cfg); err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+
+	accessibleMode = cfg.Accessible || defaultConfig.Accessible
+	sortOrder = cfg.Sort
+	if sortOrder == "" {
+		sortOrder = defaultConfig.Sort
+	}
+	if source := defaultConfig.Source; sortedListing() && source != "" && source != sourceAuto && source != sourceWalk {
+		fmt.Fprintf(logOut, "Warning: --sort lists files with the built-in walker instead of source = %q\n", source)
+	}
+
 	startingDir, err := expandPath(cfg.StartingDir)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error expanding Starting Directory path '%s': %v\n", cfg.StartingDir, err)
-		os.Exit(1)
+		return fmt.Errorf("expanding Starting Directory path '%s': %w", cfg.StartingDir, err)
 	}
 	cfg.StartingDir = startingDir
+	if cfg.Resume {
+		applyResume(cfg)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	// Режим киоска не выключается перечитанной конфигурацией, а файл проекта
+	// мог бы расширить kiosk_roots
+	if cfg.Kiosk || defaultConfig.Kiosk {
+		kioskMode = true
+	}
+	if kioskMode {
+		if err := setupKiosk(cfg); err != nil {
+			return err
+		}
+	} else if err := applyProjectConfig(cfg); err != nil {
+		return fmt.Errorf("loading project configuration: %w", err)
+	}
+	updatePowerSaver()
+
+	// Терминал из -t нужен и для запуска терминальных приложений
+	defaultConfig.Terminal = cfg.Terminal
+
+	// --ignore и --no-ignore перекрывают no_ignore; списку fzf по умолчанию
+	// .gitignore неизвестен, поэтому источник выбирается автоматически
+	if cfg.explicitFlags["no-ignore"] || cfg.explicitFlags["ignore"] {
+		if cfg.explicitFlags["no-ignore"] {
+			defaultConfig.NoIgnore = cfg.NoIgnore
+		}
+		if cfg.explicitFlags["ignore"] {
+			defaultConfig.NoIgnore = !cfg.Ignore
+		}
+		if defaultConfig.Source == "" {
+			defaultConfig.Source = sourceAuto
+		}
+	}
+	return nil
+}
+
+// pickerTimeout ограничивает одну сессию выбора файла в fzf
+const pickerTimeout = 3 * time.Minute
+
+// pickAndOpen показывает fzf и открывает выбранный файл. Возвращает false,
+// если ничего не выбрано (fzf отменён), и код возврата для программы.
+// tabs может быть nil, если переключение корней не используется.
+func pickAndOpen(cfg *Config, tabs *rootTabs) (bool, int) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if serverMode {
+		// fzf сервера ждёт выбора сколько угодно
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithTimeout(context.Background(), pickerTimeout)
+	}
 	defer cancel()
 
-	selectedPath, err := getPathViaFZF(ctx, cfg)
+	var opts pickerOptions
+	if tabs != nil {
+		cfg.StartingDir = tabs.dir()
+		opts = tabs.pickerOptions()
+	}
+	// Запрос из -q задаёт только первый показ fzf, дальше в --loop
+	// запоминается введённый пользователем
+	if cfg.Query != "" {
+		if opts.Query == "" {
+			opts.Query = cfg.Query
+		}
+		cfg.Query = ""
+	}
+	addActionKeys(&opts)
+	addIgnoreToggle(&opts)
+	addNavKeys(&opts)
+	addDirsMode(&opts)
+	addServerListen(&opts, cfg.StartingDir)
+	addInlineLayout(&opts, cfg)
+	addMouse(&opts)
+	addFrecencyTiebreak(&opts)
+	addGrepMode(&opts)
+	addQueryHistory(&opts)
+	addResumeQuery(&opts)
+	addListDecoration(&opts)
+	opts.Null = nulSeparated()
+	opts.Preview = previewCommand(cfg.Profile)
+	if cfg.Multi {
+		opts.Multi = true
+	}
+
+	result, err := getPathViaFZF(ctx, cfg, opts)
+	defer result.releaseTerminal()
 	if err != nil {
-		waitForUserIfNoAutoClose(cfg)
-		os.Exit(0)
+		fmt.Fprintf(logOut, "Error: %v\n", err)
+		return false, 1
 	}
+	saveResume(result)
 
-	if selectedPath == "" {
-		waitForUserIfNoAutoClose(cfg)
-		os.Exit(0)
+	if tabs != nil {
+		tabs.saveQuery(result.Query)
+		if result.Key == tabs.key {
+			tabs.next()
+			return true, 0
+		}
 	}
 
-	if err := openFileWithConfiguredApp(selectedPath); err != nil {
-		waitForUserIfNoAutoClose(cfg)
-		os.Exit(1)
+	if serverMode && result.Key == serverQuitKey {
+		serverMode = false
+		return false, 0
+	}
+	if len(result.Paths) == 0 {
+		announce("Nothing selected")
+		return false, 0
+	}
+	if result.Paths = kioskPaths(result.Paths); len(result.Paths) == 0 {
+		return true, 1
+	}
+	if snapshotMode {
+		if result.Paths = snapshotPaths(result.Paths); len(result.Paths) == 0 {
+			return true, 1
+		}
 	}
 
-	waitForUserIfNoAutoClose(cfg)
+	switch cfg.Output {
+	case outputShell:
+		if snippet := shellSnippet(result.Paths); snippet != "" {
+			fmt.Println(snippet)
+		}
+		return true, 0
+	case outputPaths:
+		for _, path := range result.Paths {
+			fmt.Println(path)
+		}
+		return true, 0
+	}
+
+	if name, ok := actionForKey(result.Key); ok {
+		if err := runAction(ctx, name, result.Paths); err != nil {
+			fmt.Fprintf(logOut, "Error: %v\n", err)
+			return true, 1
+		}
+		return true, 0
+	}
+
+	before := launchedCount()
+	exitCode := 0
+	if len(result.Paths) > 1 {
+		openBatch = newLaunchBatch()
+		defer func() { openBatch = nil }()
+	}
+	for _, selectedPath := range result.Paths {
+		open := openFileWithConfiguredApp
+		if cfg.With != "" {
+			open = func(ctx context.Context, filePath string) error {
+				return openWith(ctx, filePath, cfg.With)
+			}
+		}
+		if err := open(ctx, selectedPath); err != nil {
+			if !runFailureMenu(ctx, selectedPath) {
+				exitCode = 1
+			}
+		}
+	}
+	if openBatch != nil && openBatch.flush() > 0 {
+		exitCode = 1
+	}
+
+	if result.hold != nil {
+		waitForWindow(launchedSince(before), defaultConfig.WindowWait)
+	}
+
+	if cfg.Wait {
+		waitLaunched()
+	}
+	return true, exitCode
 }
 
 // waitForUserIfNoAutoClose ожидает ввода пользователя если установлен флаг NoAutoClose
@@ -285,50 +765,385 @@ func initializeAndParseFlags() *Config {
 	flag.StringVar(&cfg.Terminal, "t", cfg.Terminal, "Terminal emulator command")
 	flag.BoolVar(&cfg.NoAutoClose, "k", cfg.NoAutoClose, "Keep window open (don't auto-close)")
 	flag.BoolVar(&cfg.UseShellIC, "i", cfg.UseShellIC, "Use interactive shell mode (-ic flags)")
+	flag.StringVar(&cfg.Profile, "p", cfg.Profile, "Configuration profile to use")
+	flag.BoolVar(&cfg.Wait, "w", cfg.Wait, "Wait for the launched application to exit")
+	flag.BoolVar(&cfg.Loop, "loop", cfg.Loop, "Return to the picker after each opened file")
+	flag.BoolVar(&cfg.Multi, "m", cfg.Multi, "Select several files with Tab and open them all")
+	flag.StringVar(&cfg.Query, "q", cfg.Query, "Start fzf with this query")
+	flag.StringVar(&cfg.Query, "query", cfg.Query, "Start fzf with this query (same as -q)")
+	flag.StringVar(&cfg.With, "with", cfg.With, "Open the selection with this command instead of the configured application")
+	flag.BoolVar(&cfg.Safe, "safe", cfg.Safe, "Do not run shells, xdg-mime, previews or other helper programs")
+	flag.StringVar(&cfg.Sort, "sort", cfg.Sort, "Order of the file list: name, mtime (newest first), size (largest first) or none")
+	flag.StringVar(&cfg.Output, "output", cfg.Output, "Print a command for the calling shell or the selected paths instead of opening the selection (shell, path)")
+	flag.BoolVar(&cfg.NoIgnore, "no-ignore", cfg.NoIgnore, "List files ignored by .gitignore and .ignore too")
+	flag.BoolVar(&cfg.Ignore, "ignore", cfg.Ignore, "Hide files ignored by .gitignore and .ignore")
+	flag.BoolVar(&cfg.PowerSaver, "power-saver", cfg.PowerSaver, "Save battery: single-threaded listing, no preview generation")
+	flag.BoolVar(&cfg.Dirs, "D", cfg.Dirs, "Pick a directory instead of a file")
+	flag.BoolVar(&cfg.Dirs, "dirs", cfg.Dirs, "Pick a directory instead of a file (same as -D)")
+	flag.BoolVar(&cfg.Server, "server", cfg.Server, "Keep fzf running and let later invocations reuse it")
+	flag.BoolVar(&cfg.Media, "media", cfg.Media, "Pick a mounted removable drive and browse it")
+	flag.BoolVar(&cfg.Recent, "recent", cfg.Recent, "Pick from recently used files (recently-used.xbel)")
+	flag.BoolVar(&cfg.Grep, "g", cfg.Grep, "Search file contents with ripgrep and open the file at the selected line")
+	flag.BoolVar(&cfg.Grep, "grep", cfg.Grep, "Search file contents with ripgrep and open the file at the selected line (same as -g)")
+	flag.BoolVar(&cfg.Snapshots, "snapshots", cfg.Snapshots, "Open an earlier version of the selected file from btrfs or ZFS snapshots, read-only")
+	flag.BoolVar(&cfg.Resume, "r", cfg.Resume, "Start in the directory (and with the query) where the last pick ended")
+	flag.BoolVar(&cfg.Resume, "resume", cfg.Resume, "Start in the directory (and with the query) where the last pick ended (same as -r)")
+	flag.BoolVar(&cfg.Accessible, "accessible", cfg.Accessible, "Screen-reader friendly: numbered list and line prompts instead of fzf, no colors, every launch announced")
+	flag.BoolVar(&cfg.Details, "details", cfg.Details, "Show size and modification time columns before each file in the list")
+	flag.BoolVar(&cfg.Kiosk, "kiosk", cfg.Kiosk, "Restrict browsing to kiosk_roots and disable actions")
+	flag.BoolVar(&cfg.Verbose, "v", cfg.Verbose, "Print how the application for each opened file is chosen")
+	flag.StringVar(&cfg.FakeExec, "fake-exec", cfg.FakeExec, "Internal: run stubs from this directory instead of real programs and journal the launches")
 
 	flag.Parse()
+
+	// Без шелла нечем запустить fzf в новом терминале с перенаправлением вывода
+	safeMode = cfg.Safe
+	if safeMode && cfg.SpawnTerm {
+		fmt.Fprintln(logOut, "Warning: -n is not supported with --safe, running fzf in the current terminal")
+		cfg.SpawnTerm = false
+	}
+	verboseMode = cfg.Verbose
+	// Список недавних файлов fzf получает через FZF_DEFAULT_COMMAND, которому нужен шелл
+	recentMode = cfg.Recent
+	dirsMode = cfg.Dirs
+	serverMode = cfg.Server
+	if serverMode {
+		if cfg.SpawnTerm || cfg.Output != "" || safeMode {
+			fmt.Fprintln(logOut, "Error: --server runs fzf in the current terminal and cannot be combined with -n, --output or --safe")
+			os.Exit(2)
+		}
+		cfg.Loop = true
+	}
+	grepMode = cfg.Grep
+	if grepMode && dirsMode {
+		fmt.Fprintln(logOut, "Warning: --grep searches file contents and is ignored with -D")
+		grepMode = false
+	}
+	if grepMode && recentMode {
+		fmt.Fprintln(logOut, "Warning: --recent is ignored with --grep")
+		recentMode = false
+	}
+	detailsMode = cfg.Details
+	if detailsMode && grepMode {
+		fmt.Fprintln(logOut, "Warning: --details is ignored with --grep")
+		detailsMode = false
+	}
+	if grepMode {
+		if err := checkGrepMode(); err != nil {
+			fmt.Fprintf(logOut, "Error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	snapshotMode = cfg.Snapshots
+	if snapshotMode && dirsMode {
+		fmt.Fprintln(logOut, "Warning: --snapshots opens file versions and is ignored with -D")
+		snapshotMode = false
+	}
+	if recentMode && dirsMode {
+		fmt.Fprintln(logOut, "Warning: --recent lists files and is ignored with -D")
+		recentMode = false
+	}
+	if recentMode && (safeMode || !hasPosixShell()) {
+		fmt.Fprintln(logOut, "Warning: --recent needs a shell and is not available here, listing the starting directory")
+		recentMode = false
+	}
+	if err := checkSort(cfg.Sort); err != nil {
+		fmt.Fprintf(logOut, "Error: --%v\n", err)
+		os.Exit(2)
+	}
+	switch cfg.Output {
+	case "":
+	case outputShell, outputPaths:
+		if cfg.Loop {
+			fmt.Fprintln(logOut, "Warning: --loop is ignored with --output")
+			cfg.Loop = false
+		}
+	default:
+		fmt.Fprintf(logOut, "Error: unknown --output %q (supported: %s, %s)\n", cfg.Output, outputShell, outputPaths)
+		os.Exit(2)
+	}
+	if cfg.SpawnTerm && !hasPosixShell() {
+		fmt.Fprintln(logOut, "Warning: -n needs sh (Git for Windows or MSYS2) in PATH, running fzf in the current console")
+		cfg.SpawnTerm = false
+	}
+	if cfg.SpawnTerm && isTermux() {
+		fmt.Fprintln(logOut, "Warning: -n is not available in Termux, running fzf in the current terminal")
+		cfg.SpawnTerm = false
+	}
+
+	cfg.explicitFlags = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		cfg.explicitFlags[f.Name] = true
+	})
 	return cfg
 }
 
-// expandPath обрабатывает ~ и $VARS в пути
+// lookupUser ищет учётную запись для ~user; тесты подменяют её
+var lookupUser = user.Lookup
+
+// expandPath обрабатывает ~, ~user, $VARS (и %VARS% в Windows) в пути
+// и убирает повторяющиеся разделители. ~name неизвестного пользователя
+// остаётся как есть, как в шелле.
 func expandPath(path string) (string, error) {
 	if path == "" {
 		return "", nil
 	}
 
+	if runtime.GOOS == "windows" && strings.Contains(path, "%") {
+		path = expandWindowsEnv(path)
+	}
+
 	if path[0] == '~' {
-		if userHomeDir != "" {
-			if len(path) == 1 {
-				return userHomeDir, nil
+		separators := "/"
+		if runtime.GOOS == "windows" {
+			separators = `/\`
+		}
+
+		name, rest := path[1:], ""
+		if i := strings.IndexAny(name, separators); i >= 0 {
+			name, rest = name[:i], name[i:]
+		}
+
+		var home string
+		if name == "" {
+			if userHomeDir == "" {
+				var err error
+				if userHomeDir, err = resolveHomeDir(); err != nil {
+					return "", fmt.Errorf("could not expand %q: %w", path, err)
+				}
 			}
-			var sb strings.Builder
-			sb.Grow(len(userHomeDir) + len(path) - 1)
-			sb.WriteString(userHomeDir)
-			sb.WriteString(path[1:])
-			return sb.String(), nil
-		} else {
-			currentUser, err := user.Current()
-			if err != nil {
-				return "", fmt.Errorf("could not get current user for ~ expansion: %w", err)
+			home = userHomeDir
+		} else if runtime.GOOS != "windows" {
+			if u, err := lookupUser(name); err == nil {
+				home = u.HomeDir
 			}
-			userHomeDir = currentUser.HomeDir
-			if len(path) == 1 {
-				return userHomeDir, nil
+		}
+
+		if home != "" {
+			if rest == "" {
+				return home, nil
 			}
-			return filepath.Join(userHomeDir, path[1:]), nil
+			var sb strings.Builder
+			sb.Grow(len(home) + len(rest))
+			sb.WriteString(home)
+			sb.WriteString(rest)
+			return normalizePath(sb.String()), nil
 		}
 	}
 
-	if !strings.Contains(path, "$") {
-		return path, nil
+	if strings.Contains(path, "$") {
+		path = os.ExpandEnv(path)
 	}
 
-	return os.ExpandEnv(path), nil
+	return normalizePath(path), nil
+}
+
+// expandWindowsEnv подставляет переменные окружения в стиле %NAME%.
+// Неизвестные переменные остаются как есть, как и в cmd.exe.
+func expandWindowsEnv(path string) string {
+	var sb strings.Builder
+	sb.Grow(len(path))
+
+	for {
+		start := strings.IndexByte(path, '%')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(path[start+1:], '%')
+		if end < 0 {
+			break
+		}
+		end += start + 1
+
+		name := path[start+1 : end]
+		if value, ok := os.LookupEnv(name); ok && name != "" {
+			sb.WriteString(path[:start])
+			sb.WriteString(value)
+			path = path[end+1:]
+			continue
+		}
+
+		sb.WriteString(path[:end])
+		path = path[end:]
+	}
+
+	sb.WriteString(path)
+	return sb.String()
+}
+
+// normalizePath убирает повторяющиеся разделители и "." в пути. В Windows буква
+// диска приводится к верхнему регистру, "C:" превращается в корень диска "C:\",
+// а UNC-пути (\\server\share) сохраняют начальный двойной разделитель.
+func normalizePath(path string) string {
+	if runtime.GOOS == "windows" {
+		if len(path) >= 2 && path[1] == ':' && isASCIILetter(path[0]) {
+			path = strings.ToUpper(path[:1]) + path[1:]
+			if len(path) == 2 {
+				path += `\`
+			}
+		}
+	}
+	return filepath.Clean(path)
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// pickerOptions - дополнительные параметры fzf для одной сессии выбора
+type pickerOptions struct {
+	Query      string
+	Header     string
+	Expect     []string
+	PrintQuery bool
+	Multi      bool
+	Preview    string
+	Binds      []string
+	Prompt     string // заменяет приглашение из fzf_command
+	Listen     string // сокет fzf --listen (режим --server)
+	Walker     string // --walker встроенного обходчика fzf
+	Height     string // --height и --layout=reverse: fzf под строкой приглашения
+	Tiebreak   string
+	Null       bool   // --read0 и --print0: строки списка и вывода разделяет NUL
+	History    string // файл --history: Ctrl-P и Ctrl-N листают прошлые запросы
+	Delimiter  string // --delimiter: разделитель полей строки
+	Nth        string // --nth: поля, с которыми сравнивается запрос
+	Ansi       bool   // --ansi: строки списка раскрашены
+	NoMouse    bool   // --no-mouse: мышь не используется
+	Icons      bool   // строки начинаются со значка: он убирается из выбора
+	Details    bool   // строки начинаются со столбцов --details: они убираются из выбора
+}
+
+// args возвращает флаги fzf для этих параметров
+func (o pickerOptions) args() []string {
+	var args []string
+	if o.Prompt != "" {
+		args = append(args, "--prompt="+o.Prompt)
+	}
+	if o.Walker != "" {
+		args = append(args, "--walker="+o.Walker)
+	}
+	if o.Listen != "" {
+		args = append(args, "--listen="+o.Listen)
+	}
+	if o.Height != "" {
+		args = append(args, "--height="+o.Height, "--layout=reverse")
+	}
+	if o.Tiebreak != "" {
+		args = append(args, "--tiebreak="+o.Tiebreak)
+	}
+	if o.History != "" {
+		args = append(args, "--history="+o.History)
+	}
+	if o.Delimiter != "" {
+		args = append(args, "--delimiter="+o.Delimiter)
+	}
+	if o.Nth != "" {
+		args = append(args, "--nth="+o.Nth)
+	}
+	if o.Query != "" {
+		args = append(args, "--query="+o.Query)
+	}
+	if o.Header != "" {
+		args = append(args, "--header="+o.Header)
+	}
+	if len(o.Expect) > 0 {
+		args = append(args, "--expect="+strings.Join(o.Expect, ","))
+	}
+	if o.PrintQuery {
+		args = append(args, "--print-query")
+	}
+	if o.Multi {
+		args = append(args, "--multi")
+	}
+	if o.Ansi {
+		args = append(args, "--ansi")
+	}
+	if o.NoMouse {
+		args = append(args, "--no-mouse")
+	}
+	if o.Null {
+		args = append(args, "--read0", "--print0")
+	}
+	if o.Preview != "" {
+		args = append(args, "--preview="+o.Preview)
+	}
+	for _, bind := range o.Binds {
+		args = append(args, "--bind="+bind)
+	}
+	return args
+}
+
+// pickResult - результат сессии fzf
+type pickResult struct {
+	Path  string   // выбранный абсолютный путь; пусто, если ничего не выбрано
+	Paths []string // все выбранные пути (при Multi), первый совпадает с Path
+	Query string   // введённый запрос (при PrintQuery)
+	Key   string   // нажатая клавиша из Expect; пусто для Enter
+	Dir   string   // каталог списка при выходе из fzf: корень навигации или начальный
+
+	hold *terminalHold // удерживаемый терминал (-n с window_wait)
+}
+
+// releaseTerminal позволяет удерживаемому терминалу закрыться
+func (r pickResult) releaseTerminal() {
+	if r.hold != nil {
+		r.hold.release()
+	}
+}
+
+// parseFzfOutput разбирает вывод fzf: строку запроса (--print-query),
+// строку нажатой клавиши (--expect) и выбранные строки
+func parseFzfOutput(content string, opts pickerOptions) (query string, key string, selections []string) {
+	sep := "\n"
+	if opts.Null {
+		sep = "\x00"
+	}
+	lines := strings.Split(strings.TrimSuffix(content, sep), sep)
+	line := func(i int) string {
+		if i < len(lines) {
+			return lines[i]
+		}
+		return ""
+	}
+
+	i := 0
+	if opts.PrintQuery {
+		query = line(i)
+		i++
+	}
+	if len(opts.Expect) > 0 {
+		key = line(i)
+		i++
+	}
+	for ; i < len(lines); i++ {
+		selection := lines[i]
+		// Имя, разделённое NUL, передаётся как есть: пробелы на концах - его часть
+		if !opts.Null {
+			selection = strings.TrimSpace(selection)
+		}
+		if opts.Details {
+			selection = stripDetails(selection)
+		}
+		if opts.Icons {
+			selection = stripIcon(selection)
+		}
+		if selection != "" {
+			selections = append(selections, selection)
+		}
+	}
+	return query, key, selections
 }
 
 // getPathViaFZF запускает fzf и возвращает выбранный абсолютный путь
-func getPathViaFZF(ctx context.Context, cfg *Config) (string, error) {
+func getPathViaFZF(ctx context.Context, cfg *Config, opts pickerOptions) (pickResult, error) {
+	// Вывод разбирается по параметрам, которые программа выбора поняла
+	opts = currentFinder().adapt(opts)
+
 	info, err := os.Stat(cfg.StartingDir)
+	if (err != nil || !info.IsDir()) && kioskMode {
+		return pickResult{}, fmt.Errorf("STARTING_DIR %q is invalid", cfg.StartingDir)
+	}
 	if err != nil || !info.IsDir() {
 		originalDir := cfg.StartingDir
 
@@ -337,12 +1152,12 @@ func getPathViaFZF(ctx context.Context, cfg *Config) (string, error) {
 			var err error
 			fallbackDir, err = expandPath("~")
 			if err != nil {
-				return "", fmt.Errorf("failed to determine fallback directory: %w", err)
+				return pickResult{}, fmt.Errorf("failed to determine fallback directory: %w", err)
 			}
 		}
 
 		cfg.StartingDir = fallbackDir
-		fmt.Fprintf(os.Stderr, "Warning: STARTING_DIR %q is invalid, falling back to %q\n", originalDir, cfg.StartingDir)
+		fmt.Fprintf(logOut, "Warning: STARTING_DIR %q is invalid, falling back to %q\n", originalDir, cfg.StartingDir)
 
 		fallbackValid := make(chan bool, 1)
 		go func() {
@@ -353,121 +1168,254 @@ func getPathViaFZF(ctx context.Context, cfg *Config) (string, error) {
 		select {
 		case valid := <-fallbackValid:
 			if !valid {
-				return "", fmt.Errorf("fallback STARTING_DIR %q is also invalid", cfg.StartingDir)
+				return pickResult{}, fmt.Errorf("fallback STARTING_DIR %q is also invalid", cfg.StartingDir)
 			}
 		case <-time.After(100 * time.Millisecond):
-			return "", fmt.Errorf("timeout checking fallback STARTING_DIR %q", cfg.StartingDir)
+			return pickResult{}, fmt.Errorf("timeout checking fallback STARTING_DIR %q", cfg.StartingDir)
 		}
 	}
 
+	statePath, err := pickerStatePath()
+	if err != nil {
+		return pickResult{}, fmt.Errorf("failed to prepare fzf state files: %w", err)
+	}
+	defer os.Remove(statePath + ignoreStateSuffix)
+	navPath := ""
+	if navigationEnabled() {
+		navPath = statePath + navStateSuffix
+		defer os.Remove(navPath)
+		if err := writeNavState(navPath, cfg.StartingDir); err != nil {
+			fmt.Fprintf(logOut, "Warning: directory navigation is unavailable: %v\n", err)
+		}
+	}
+
+	fzfArgs, err := fzfOptionArgs()
+	if err != nil {
+		return pickResult{}, err
+	}
+	fzfArgs = append(fzfArgs, currentFinder().args(opts)...)
+
 	var sb strings.Builder
 	sb.Grow(128)
 	sb.WriteString("cd ")
 	sb.WriteString(shellQuote(cfg.StartingDir))
 	sb.WriteString(" && ")
-	sb.WriteString(defaultConfig.FzfCommand)
-	sb.WriteString(" > ")
-	sb.WriteString(shellQuote(tmpFzfOutput))
-	fzfCommand := sb.String()
-
-	var cmd *exec.Cmd
+	// fzy и peco читают список со стандартного ввода
+	if currentFinder().sourceEnv() == "" {
+		if source := listSourceCommand(); source != "" {
+			sb.WriteString("(" + source + ") | ")
+		}
+	}
+	sb.WriteString(finderCommand())
+	for _, arg := range fzfArgs {
+		sb.WriteByte(' ')
+		sb.WriteString(shellQuote(arg))
+	}
 
-	if cfg.SpawnTerm {
-		args := make([]string, 0, 8)
+	var hold *terminalHold
+	var content []byte
+	fzfDir := cfg.StartingDir
 
-		if cfg.UseShellIC {
-			if defaultConfig.ShellToUse == "" {
-				shellDetectOnce.Do(detectUserShell)
+	if safeMode || !hasPosixShell() {
+		content, err = runFzfDirect(ctx, cfg.StartingDir, fzfArgs)
+	} else {
+		// Выбор и каталог fzf шелл пишет в каналы, унаследованные дескрипторами
+		// 3 и 4, а из терминала, открытого флагом -n, - во временные файлы
+		var output, cwd *outputCapture
+		output, cwd, err = newPickerCaptures(cfg.SpawnTerm || !inheritsExtraFiles)
+		if err != nil {
+			return pickResult{}, fmt.Errorf("failed to prepare fzf output: %w", err)
+		}
+		defer output.close()
+		defer cwd.close()
+		sb.WriteString(" " + output.redirect() + " && pwd " + cwd.redirect())
+		fzfCommand := sb.String()
+
+		if cfg.SpawnTerm {
+			// Terminal.app и iTerm2 открывают окно и сразу возвращают управление:
+			// конец выбора отмечает файл hold, а окружение передаётся явно
+			detached := terminalDetached(cfg.Terminal)
+			if defaultConfig.WindowWait > 0 || detached {
+				if hold, err = newTerminalHold(statePath, detached); err != nil {
+					if detached {
+						return pickResult{}, fmt.Errorf("failed to prepare %s window: %w", cfg.Terminal, err)
+					}
+					fmt.Fprintf(logOut, "Warning: cannot keep the terminal open: %v\n", err)
+				} else {
+					fzfCommand += hold.script()
+				}
 			}
-
-			shellInteractiveFlags := getShellInteractiveFlag(defaultConfig.ShellToUse)
-
-			args = append(args, defaultConfig.WinTitleFlag, defaultConfig.WinTitle, "-e", defaultConfig.ShellToUse)
-			args = append(args, shellInteractiveFlags...)
-			args = append(args, fzfCommand)
+			err = runPickerTerminal(ctx, cfg, fzfCommand, hold, detached)
 		} else {
-			args = append(args, defaultConfig.WinTitleFlag, defaultConfig.WinTitle, "-e", "/bin/sh", "-c", fzfCommand)
+			err = runPickerShell(ctx, cfg, fzfCommand, pickerExtraFiles(output, cwd))
 		}
-
-		cmd = exec.CommandContext(ctx, cfg.Terminal, args...)
-		err = cmd.Run()
-	} else {
-		var shell string
-		var shellArgs []string
-
-		if cfg.UseShellIC && defaultConfig.ShellToUse != "" && defaultConfig.ShellToUse != "sh" {
-			shell = defaultConfig.ShellToUse
-			shellArgs = getShellInteractiveFlag(defaultConfig.ShellToUse)
-			shellArgs = append(shellArgs, fzfCommand)
-		} else {
-			shell = "/bin/sh"
-			shellArgs = []string{"-c", fzfCommand}
+		deadline := time.Now().Add(pipeDrainTimeout)
+		content = output.bytes(deadline)
+		if dir := strings.TrimSpace(string(cwd.bytes(deadline))); filepath.IsAbs(dir) {
+			fzfDir = dir
 		}
+	}
 
-		cmd = exec.CommandContext(ctx, shell, shellArgs...)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err = cmd.Run()
+	// Терминал отпускает вызывающий, когда окно приложения появится
+	result := pickResult{hold: hold, Dir: fzfDir}
+	if navPath != "" {
+		if state, err := readNavState(navPath); err == nil {
+			result.Dir = state.root
+		}
 	}
 
 	if err != nil {
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) && exitErr.ExitCode() == 130 {
-			return "", nil
+			return result, nil
 		}
 		if !errors.As(err, &exitErr) {
-			fmt.Fprintf(os.Stderr, "Error executing fzf command: %v\n", err)
+			fmt.Fprintf(logOut, "Error executing fzf command: %v\n", err)
+			return result, nil
+		}
+		// Код 1 - нет совпадений; запрос и нажатая клавиша всё равно нужны
+		if exitErr.ExitCode() != 1 || !opts.PrintQuery {
+			return result, nil
 		}
-		return "", nil
 	}
 
-	content, err := os.ReadFile(tmpFzfOutput)
-	if _, statErr := os.Stat(tmpFzfOutput); statErr == nil {
-		os.Remove(tmpFzfOutput)
-	}
+	query, key, selections := parseFzfOutput(string(content), opts)
+	result.Query, result.Key = query, key
+	selections = filterSelections(ctx, selections, opts.Null)
 
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return "", nil
+	for _, selectedRelativePath := range selections {
+		if isGVFSURI(selectedRelativePath) {
+			localPath, err := gvfsLocalPath(ctx, selectedRelativePath)
+			if err != nil {
+				fmt.Fprintf(logOut, "Error: %v\n", err)
+				continue
+			}
+			result.Paths = append(result.Paths, localPath)
+			continue
 		}
-		fmt.Fprintf(os.Stderr, "Error reading fzf output file %q: %v\n", tmpFzfOutput, err)
-		return "", nil
+
+		absolutePath, err := resolveSelection(selectedRelativePath, fzfDir)
+		if err != nil {
+			fmt.Fprintf(logOut, "Error resolving path %q: %v\n", selectedRelativePath, err)
+			continue
+		}
+
+		if _, err := os.Stat(absolutePath); err != nil {
+			// Строка вида "путь:строка:текст" из grep -n или rg --vimgrep
+			if _, _, ok := splitLineSuffix(absolutePath); !ok {
+				fmt.Fprintf(logOut, "Warning: Constructed path does not exist or is inaccessible: %q (%v)\n", absolutePath, err)
+				continue
+			}
+		}
+
+		result.Paths = append(result.Paths, absolutePath)
 	}
 
-	selectedRelativePath := strings.TrimSpace(string(content))
-	if selectedRelativePath == "" {
-		return "", nil
+	if len(result.Paths) > 0 {
+		result.Path = result.Paths[0]
 	}
+	return result, nil
+}
 
-	absolutePath := filepath.Join(cfg.StartingDir, selectedRelativePath)
-	if !filepath.IsAbs(absolutePath) {
-		absolutePath, err = filepath.Abs(absolutePath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error resolving path %q: %v\n", absolutePath, err)
-			return "", nil
+// runPickerTerminal выполняет команду fzf в новом окне терминала (флаг -n)
+func runPickerTerminal(ctx context.Context, cfg *Config, fzfCommand string, hold *terminalHold, detached bool) error {
+	pickerArgv := make([]string, 0, 8)
+	if detached {
+		pickerArgv = append(pickerArgv, "env")
+		pickerArgv = append(pickerArgv, fzfEnvOverrides()...)
+	}
+
+	if cfg.UseShellIC {
+		if defaultConfig.ShellToUse == "" {
+			shellDetectOnce.Do(detectUserShell)
 		}
+
+		pickerArgv = append(pickerArgv, defaultConfig.ShellToUse)
+		pickerArgv = append(pickerArgv, getShellInteractiveFlag(defaultConfig.ShellToUse)...)
+		pickerArgv = append(pickerArgv, fzfCommand)
+	} else {
+		pickerArgv = append(pickerArgv, shellArgv(fzfCommand)...)
 	}
 
-	if _, err := os.Stat(absolutePath); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Constructed path does not exist or is inaccessible: %q (%v)\n", absolutePath, err)
-		return "", nil
+	argv := terminalArgv(cfg.Terminal, defaultConfig.WinTitle, pickerArgv)
+	cmd := execCommand(ctx, "picker", argv[0], argv[1:]...)
+	cmd.Env = fzfEnv()
+	if hold != nil {
+		return hold.run(ctx, cmd)
+	}
+	return cmd.Run()
+}
+
+// runPickerShell выполняет команду fzf в шелле в текущем терминале.
+// extraFiles шелл получает дескрипторами 3, 4, …
+func runPickerShell(ctx context.Context, cfg *Config, fzfCommand string, extraFiles []*os.File) error {
+	var shell string
+	var shellArgs []string
+
+	if cfg.UseShellIC && defaultConfig.ShellToUse != "" && defaultConfig.ShellToUse != "sh" {
+		shell = defaultConfig.ShellToUse
+		shellArgs = getShellInteractiveFlag(defaultConfig.ShellToUse)
+		shellArgs = append(shellArgs, fzfCommand)
+	} else {
+		argv := shellArgv(fzfCommand)
+		shell, shellArgs = argv[0], argv[1:]
+	}
+	cmd := execCommand(ctx, "picker", shell, shellArgs...)
+	cmd.Env = fzfEnv()
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	// Стандартный вывод принадлежит вызывающему шеллу (eval); то, что
+	// печатают rc-файлы интерактивного шелла, туда попасть не должно
+	if cfg.Output != "" {
+		cmd.Stdout = os.Stderr
+	}
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = extraFiles
+	return cmd.Run()
+}
+
+// resolveSelection превращает строку, выбранную в fzf, в абсолютный путь.
+// Абсолютные пути возвращаются как есть, относительные (в том числе с префиксом
+// "./") разрешаются относительно каталога, в котором работал fzf: команда fzf
+// из конфигурации может сама сменить каталог.
+func resolveSelection(selection string, fzfDir string) (string, error) {
+	if filepath.IsAbs(selection) {
+		return filepath.Clean(selection), nil
+	}
+
+	// Путь от корня текущего диска Windows (\dir\file) получает букву диска fzfDir
+	if volume := filepath.VolumeName(fzfDir); volume != "" && strings.ContainsAny(selection[:1], `/\`) {
+		return filepath.Clean(volume + selection), nil
 	}
 
+	for strings.HasPrefix(selection, "./") || strings.HasPrefix(selection, "."+string(filepath.Separator)) {
+		selection = strings.TrimLeft(selection[2:], "/"+string(filepath.Separator))
+	}
+
+	absolutePath := filepath.Join(fzfDir, selection)
+	if !filepath.IsAbs(absolutePath) {
+		return filepath.Abs(absolutePath)
+	}
 	return absolutePath, nil
 }
 
-// shellQuote обрамляет строку кавычками
+// shellQuoteReplacer - замены shellQuote для кавычки и обратной косой черты
+var shellQuoteReplacer = strings.NewReplacer(`'`, `'\''`, `\`, `'\\'`)
+
+// shellQuote обрамляет строку одинарными кавычками, чтобы шелл не раскрывал в ней
+// $ и `. Кавычка и обратная косая черта закрывают строку, экранируются (\' и
+// \\) и открывают её заново: fish, в отличие от POSIX-шеллов, раскрывает \' и
+// \\ и внутри одинарных кавычек, а вне кавычек их одинаково понимают все.
 func shellQuote(s string) string {
-	if !strings.Contains(s, "\"") {
-		return "\"" + s + "\""
+	if !strings.ContainsAny(s, `'\`) {
+		return "'" + s + "'"
 	}
 
 	var sb strings.Builder
-	sb.Grow(len(s) + 4)
-	sb.WriteByte('"')
-	sb.WriteString(strings.Replace(s, "\"", "\\\"", -1))
-	sb.WriteByte('"')
+	sb.Grow(len(s) + 8)
+	sb.WriteByte('\'')
+	sb.WriteString(shellQuoteReplacer.Replace(s))
+	sb.WriteByte('\'')
 	return sb.String()
 }
 
@@ -491,10 +1439,20 @@ var (
 		"flv": {}, "avi": {}, "mov": {}, "mp4": {}, "mkv": {}, "webm": {},
 		"wmv": {}, "mpeg": {}, "mpg": {}, "mp3": {}, "ogg": {}, "oga": {},
 		"wav": {}, "flac": {}, "opus": {}, "aac": {}, "m4a": {},
+		"m3u": {}, "m3u8": {}, "pls": {},
 	}
 	extToSpreadsheet = map[string]struct{}{"csv": {}, "tsv": {}, "ods": {}, "xlsx": {}}
 	extToWebBrowser  = map[string]struct{}{"htm": {}, "html": {}, "xhtml": {}}
-	extToTextEditor  = map[string]struct{}{
+	extToEbookReader = map[string]struct{}{
+		"epub": {}, "mobi": {}, "azw": {}, "azw3": {}, "fb2": {}, "djvu": {}, "cbz": {}, "cbr": {},
+	}
+	extToFontViewer     = map[string]struct{}{"ttf": {}, "otf": {}, "ttc": {}, "woff": {}, "woff2": {}}
+	extToArchiveManager = map[string]struct{}{
+		"zip": {}, "tar": {}, "gz": {}, "tgz": {}, "bz2": {}, "tbz2": {}, "xz": {}, "txz": {},
+		"zst": {}, "7z": {}, "rar": {},
+	}
+	extToTorrentClient = map[string]struct{}{"torrent": {}}
+	extToTextEditor    = map[string]struct{}{
 		"txt": {}, "md": {}, "markdown": {}, "sh": {}, "bash": {}, "zsh": {},
 		"fish": {}, "py": {}, "rb": {}, "js": {}, "jsx": {}, "ts": {}, "tsx": {},
 		"c": {}, "cpp": {}, "h": {}, "hpp": {}, "java": {}, "go": {}, "rs": {},
@@ -506,36 +1464,70 @@ var (
 )
 
 // openFileWithConfiguredApp - основная логика выбора приложения
-func openFileWithConfiguredApp(filePath string) error {
+func openFileWithConfiguredApp(ctx context.Context, filePath string) error {
+	return openPath(ctx, filePath, true)
+}
+
+// openWith открывает файл командой из флага --with, минуя правила и ассоциации
+func openWith(ctx context.Context, filePath string, command string) error {
+	line := 0
+	if _, err := os.Stat(filePath); err != nil {
+		if file, n, ok := splitLineSuffix(filePath); ok {
+			filePath, line = file, n
+		}
+	}
+	if err := launchFirst(ctx, filePath, launchSpec{Command: command, Line: line}); err != nil {
+		return fmt.Errorf("could not open %q with %q: %w", filePath, command, err)
+	}
+	return nil
+}
+
+// openPath открывает файл или каталог. Если expandLists, файлы-списки путей
+// предлагается открыть целиком (записи самих списков так не раскрываются).
+func openPath(ctx context.Context, filePath string, expandLists bool) (err error) {
+	line := 0
 	fi, err := os.Stat(filePath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: File or directory not found: %q (%v)\n", filePath, err)
+		if file, n, ok := splitLineSuffix(filePath); ok {
+			filePath, line = file, n
+			fi, err = os.Stat(filePath)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(logOut, "Error: File or directory not found: %q (%v)\n", filePath, err)
 		return err
 	}
 
+	if err := checkKioskPath(filePath, fi); err != nil {
+		fmt.Fprintf(logOut, "Error: %v\n", err)
+		return err
+	}
+
+	release, claimed := claimLaunch(filePath)
+	if !claimed {
+		fmt.Fprintf(logOut, "Info: %q was just opened, ignoring the repeated request\n", filePath)
+		return nil
+	}
+	// Неудавшийся запуск не должен отбрасывать повторную попытку
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
 	fileInfo := FileTypeInfo{
 		FileName: filepath.Base(filePath),
 	}
 
 	if fi.IsDir() {
-		success := make(chan bool, 2)
-		go func() { success <- launchApp(appAssociations.TextEditor, filePath) }()
-
-		select {
-		case result := <-success:
-			if result {
-				return nil
-			}
-			if launchApp(appAssociations.FallbackOpener, filePath) {
-				return nil
-			}
-		case <-time.After(200 * time.Millisecond):
-			if launchApp(appAssociations.FallbackOpener, filePath) {
-				return nil
-			}
+		specs := directorySpecs()
+		if isHeadless() {
+			specs = append(specs, consoleSpecs(assocDirectoryOpener)...)
 		}
-
-		return fmt.Errorf("could not open directory %q with any available application", filePath)
+		if err := launchFirst(ctx, filePath, specs...); err != nil {
+			return fmt.Errorf("could not open directory %q with any available application: %w", filePath, err)
+		}
+		return nil
 	}
 
 	extWithDot := filepath.Ext(fileInfo.FileName)
@@ -545,66 +1537,187 @@ func openFileWithConfiguredApp(filePath string) error {
 		fileInfo.Ext = ""
 	}
 
-	var appToLaunch string
+	if expandLists {
+		if handled, err := offerOpenList(ctx, filePath, fileInfo.Ext); handled {
+			return err
+		}
+	}
+	if handled, err := offerFollowLog(ctx, filePath, fileInfo.Ext); handled {
+		return err
+	}
+	if handled, err := offerSystemdUnit(ctx, filePath, fileInfo.Ext); handled {
+		return err
+	}
+	if handled, err := offerPackaging(ctx, filePath, fileInfo.Ext); handled {
+		return err
+	}
 
-	if _, ok := extToPDFViewer[fileInfo.Ext]; ok {
-		appToLaunch = appAssociations.PDFViewer
+	var appKey string
+	var rule []launchSpec
+	var trace resolutionTrace
+
+	if r, ok := filenameRuleFor(filePath); ok {
+		rule = filenameRuleSpecs(r)
+		trace.rule = describeFilenameRule(r)
+		if r.Backup {
+			// Без копии файл не открывается: её и просили на случай, если
+			// редактор испортит файл
+			backup, err := backupFile(filePath)
+			if err != nil {
+				return fmt.Errorf("could not back up %q before opening: %w", filePath, err)
+			}
+			fmt.Fprintf(logOut, "Info: backed up %q to %q\n", filePath, backup)
+		}
+	} else if target, ok := extensionRules[fileInfo.Ext]; ok && fileInfo.Ext != "" {
+		rule = ruleSpecs(target)
+		trace.extension = "[extensions]"
+		trace.rule = fmt.Sprintf("[extensions] %s = %q", fileInfo.Ext, target)
+	} else if spec, ok := mimeappsSpec(filePath, &fileInfo); ok {
+		rule = []launchSpec{spec}
+		trace.rule = "mimeapps.list"
+	} else if spec, ok := mailcapSpec(filePath, &fileInfo); ok {
+		rule = []launchSpec{spec}
+		trace.rule = "mailcap"
+	} else if _, ok := extensionMIMETypes[fileInfo.Ext]; ok && fileInfo.Ext != "" {
+		// Тип из [mime_types] важнее встроенных таблиц расширений: файл
+		// классифицируется по MIME-типу ниже
+	} else if _, ok := extToPDFViewer[fileInfo.Ext]; ok {
+		appKey = assocPDFViewer
 	} else if _, ok := extToDocxViewer[fileInfo.Ext]; ok {
-		appToLaunch = appAssociations.DocxViewer
+		appKey = assocDocxViewer
 	} else if _, ok := extToImageViewer[fileInfo.Ext]; ok {
-		appToLaunch = appAssociations.ImageViewer
+		appKey = assocImageViewer
 	} else if _, ok := extToVideoPlayer[fileInfo.Ext]; ok {
-		appToLaunch = appAssociations.VideoPlayer
+		appKey = assocVideoPlayer
 	} else if _, ok := extToSpreadsheet[fileInfo.Ext]; ok {
-		appToLaunch = appAssociations.SpreadsheetEditor
+		appKey = assocSpreadsheetEditor
 	} else if _, ok := extToWebBrowser[fileInfo.Ext]; ok {
-		appToLaunch = appAssociations.WebBrowser
+		appKey = assocWebBrowser
+	} else if _, ok := extToEbookReader[fileInfo.Ext]; ok {
+		appKey = assocEbookReader
+	} else if _, ok := extToFontViewer[fileInfo.Ext]; ok {
+		appKey = assocFontViewer
+	} else if _, ok := extToArchiveManager[fileInfo.Ext]; ok {
+		appKey = assocArchiveManager
+	} else if _, ok := extToTorrentClient[fileInfo.Ext]; ok {
+		appKey = assocTorrentClient
 	} else if _, ok := extToTextEditor[fileInfo.Ext]; ok {
 		if fileInfo.Ext == "" {
 			fileInfo.MIMEType = getMimeType(filePath)
 
-			if fileInfo.MIMEType == "" ||
+			if target := mimeRule(fileInfo.MIMEType); target != "" {
+				rule = ruleSpecs(target)
+				trace.rule = fmt.Sprintf("[mime] %s = %q", fileInfo.MIMEType, target)
+			} else if fileInfo.MIMEType == "" ||
 				strings.HasPrefix(fileInfo.MIMEType, mimeTextPrefix) ||
 				fileInfo.MIMEType == mimeApplicationScript ||
 				fileInfo.MIMEType == mimeApplicationJS ||
 				fileInfo.MIMEType == mimeApplicationJSON ||
 				fileInfo.MIMEType == mimeApplicationXML ||
 				fileInfo.MIMEType == mimeInodeEmpty {
-				appToLaunch = appAssociations.TextEditor
+				appKey = assocTextEditor
 			}
 		} else {
-			appToLaunch = appAssociations.TextEditor
+			appKey = assocTextEditor
 		}
 	}
+	if appKey != "" && fileInfo.Ext != "" {
+		trace.extension = "built-in table"
+	}
 
-	if appToLaunch == "" {
+	if appKey == "" && len(rule) == 0 {
 		if fileInfo.MIMEType == "" {
 			fileInfo.MIMEType = getMimeType(filePath)
 		}
 
-		if fileInfo.MIMEType != "" {
-			appToLaunch = getAppByMIME(fileInfo.MIMEType)
+		if target := mimeRule(fileInfo.MIMEType); target != "" {
+			rule = ruleSpecs(target)
+			trace.rule = fmt.Sprintf("[mime] %s = %q", fileInfo.MIMEType, target)
+		} else if fileInfo.MIMEType != "" {
+			appKey = getAssociationByMIME(fileInfo.MIMEType)
 		}
 	}
 
-	if appToLaunch != "" {
-		if launchApp(appToLaunch, filePath) {
-			return nil
+	if conv, ok := converterFor(fileInfo.Ext); ok && !hasViewer(rule, appKey) {
+		if handled, err := offerConversion(ctx, filePath, conv); handled {
+			return err
 		}
 	}
 
-	fmt.Fprintf(os.Stderr, "Info: No specific rule matched for %q (MIME: %q). Falling back to %q...\n",
-		fileInfo.FileName, fileInfo.MIMEType, appAssociations.FallbackOpener)
+	specs := make([]launchSpec, 0, 4)
+	if len(rule) > 0 {
+		specs = append(specs, rule...)
+	} else if appKey != "" && len(associationChain(appKey)) > 0 {
+		specs = append(specs, associationSpecs(appKey)...)
+	} else if appKey != "" {
+		fmt.Fprintf(logOut, "Info: %s is not configured for %q. Falling back to %q...\n",
+			appKey, fileInfo.FileName, appAssociations.FallbackOpener.String())
+	} else {
+		fmt.Fprintf(logOut, "Info: No specific rule matched for %q (MIME: %q). Falling back to %q...\n",
+			fileInfo.FileName, fileInfo.MIMEType, appAssociations.FallbackOpener.String())
+	}
+	specs = append(specs, associationSpecs(assocFallbackOpener)...)
+	// Без дисплея графические приложения отказываются запускаться, и файл
+	// открывается в консоли
+	if isHeadless() {
+		consoleKey := appKey
+		if _, ok := extToTextEditor[fileInfo.Ext]; (ok && fileInfo.Ext != "") || strings.HasPrefix(fileInfo.MIMEType, mimeTextPrefix) {
+			consoleKey = assocTextEditor
+		}
+		specs = append(specs, consoleSpecs(consoleKey)...)
+	}
+	specs, ok := guardSystemFile(filePath, specs)
+	if !ok {
+		fmt.Fprintf(logOut, "Info: opening %q cancelled\n", filePath)
+		return nil
+	}
+	for i := range specs {
+		specs[i].Line = line
+	}
+	if verboseMode {
+		printResolution(filePath, fileInfo, trace, appKey, specs)
+	}
 
-	if !launchApp(appAssociations.FallbackOpener, filePath) {
-		return fmt.Errorf("fallback opener %q failed to launch for %q", appAssociations.FallbackOpener, filePath)
+	if err := launchFirst(ctx, filePath, specs...); err != nil {
+		return fmt.Errorf("could not open %q (fallback opener %q): %w", filePath, appAssociations.FallbackOpener.String(), err)
 	}
 
+	recordRecent(filePath)
+	recordFrecency(filePath)
 	return nil
 }
 
-// getAppByMIME определяет приложение по MIME типу
-func getAppByMIME(mimeType string) string {
+// directorySpecs возвращает кандидатов для открытия каталога: directory_opener,
+// а если он не задан - text_editor, как до появления отдельной ассоциации
+func directorySpecs() []launchSpec {
+	opener := assocDirectoryOpener
+	if len(appAssociations.DirectoryOpener) == 0 {
+		opener = assocTextEditor
+	}
+	return associationSpecs(opener, assocFallbackOpener)
+}
+
+// hasViewer сообщает, найдено ли для файла приложение помимо fallback_opener
+func hasViewer(rule []launchSpec, appKey string) bool {
+	specs := rule
+	if len(specs) == 0 && appKey != "" {
+		specs = associationSpecs(appKey)
+	}
+
+	for _, spec := range specs {
+		argv := spec.argv()
+		if len(argv) == 0 {
+			continue
+		}
+		if _, err := cachedLookPath(argv[0]); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// getAssociationByMIME определяет ключ ассоциации по MIME типу
+func getAssociationByMIME(mimeType string) string {
 	switch {
 	case strings.HasPrefix(mimeType, mimeTextPrefix),
 		mimeType == mimeApplicationScript,
@@ -612,21 +1725,34 @@ func getAppByMIME(mimeType string) string {
 		mimeType == mimeApplicationJSON,
 		mimeType == mimeApplicationXML,
 		mimeType == mimeInodeEmpty:
-		return appAssociations.TextEditor
+		return assocTextEditor
+	// Проверяется до image/*: djvu - тоже image/vnd.djvu
+	case mimeType == mimeEpub,
+		mimeType == mimeDjvu,
+		mimeType == mimeMobi:
+		return assocEbookReader
 	case strings.HasPrefix(mimeType, mimeImagePrefix):
-		return appAssociations.ImageViewer
+		return assocImageViewer
 	case strings.HasPrefix(mimeType, mimeVideoPrefix), strings.HasPrefix(mimeType, mimeAudioPrefix):
-		return appAssociations.VideoPlayer
+		return assocVideoPlayer
 	case mimeType == mimePDF:
-		return appAssociations.PDFViewer
+		return assocPDFViewer
 	case mimeType == mimeWordDocx,
 		mimeType == mimeWordDoc,
 		mimeType == mimeODT:
-		return appAssociations.DocxViewer
+		return assocDocxViewer
 	case mimeType == mimeODS,
 		mimeType == mimeExcel,
 		mimeType == mimeExcelX:
-		return appAssociations.SpreadsheetEditor
+		return assocSpreadsheetEditor
+	case strings.HasPrefix(mimeType, mimeFontPrefix),
+		mimeType == mimeFontTTF,
+		mimeType == mimeFontOTF:
+		return assocFontViewer
+	case archiveMIMETypes[mimeType]:
+		return assocArchiveManager
+	case mimeType == mimeBitTorrent:
+		return assocTorrentClient
 	}
 	return ""
 }
@@ -648,70 +1774,36 @@ func getMimeType(filePath string) string {
 	}
 
 	ext := filepath.Ext(filePath)
-	if ext != "" {
-		lowerExt := strings.ToLower(ext)
-		switch lowerExt {
-		case ".txt", ".md", ".log", ".conf", ".cfg":
-			mimeCacheLock.Lock()
-			mimeCache[filePath] = mimeTextPrefix + "plain"
-			mimeCacheLock.Unlock()
-			return mimeTextPrefix + "plain"
-		case ".json":
-			mimeCacheLock.Lock()
-			mimeCache[filePath] = mimeApplicationJSON
-			mimeCacheLock.Unlock()
-			return mimeApplicationJSON
-		case ".xml":
-			mimeCacheLock.Lock()
-			mimeCache[filePath] = mimeApplicationXML
-			mimeCacheLock.Unlock()
-			return mimeApplicationXML
-		case ".pdf":
-			mimeCacheLock.Lock()
-			mimeCache[filePath] = mimePDF
-			mimeCacheLock.Unlock()
-			return mimePDF
-		case ".png", ".jpg", ".jpeg", ".gif", ".bmp", ".webp", ".svg":
-			mimeType := mimeImagePrefix + lowerExt[1:]
-			mimeCacheLock.Lock()
-			mimeCache[filePath] = mimeType
-			mimeCacheLock.Unlock()
-			return mimeType
-		case ".mp4", ".avi", ".mkv", ".mov":
-			mimeType := mimeVideoPrefix + lowerExt[1:]
-			mimeCacheLock.Lock()
-			mimeCache[filePath] = mimeType
-			mimeCacheLock.Unlock()
-			return mimeType
-		}
-	}
-
-	xdgMimePath, err := cachedLookPath("xdg-mime")
-	if err != nil {
-		return ""
+	if mimeType, ok := extensionMIMETypes[normalizeExtension(ext)]; ok && ext != "" {
+		mimeCacheLock.Lock()
+		mimeCache[filePath] = mimeType
+		mimeCacheLock.Unlock()
+		return mimeType
+	}
+	if mimeType := builtinExtensionMIME(ext); mimeType != "" {
+		mimeCacheLock.Lock()
+		mimeCache[filePath] = mimeType
+		mimeCacheLock.Unlock()
+		return mimeType
+	}
+
+	// Распознанный по содержимому формат не требует запуска xdg-mime (в macOS -
+	// mdls); в режиме --safe они не запускаются вовсе
+	if mimeType := sniffMimeType(filePath); mimeType != "" || safeMode {
+		mimeCacheLock.Lock()
+		mimeCache[filePath] = mimeType
+		mimeCacheLock.Unlock()
+		return mimeType
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, xdgMimePath, "query", "filetype", filePath)
-	output, err := cmd.Output()
-	if err != nil {
+	mimeType := queryMimeType(ctx, filePath)
+	if mimeType == "" {
 		return ""
 	}
 
-	end := len(output)
-	for end > 0 && (output[end-1] == '\n' || output[end-1] == '\r' || output[end-1] == ' ' || output[end-1] == '\t') {
-		end--
-	}
-
-	start := 0
-	for start < end && (output[start] == ' ' || output[start] == '\t') {
-		start++
-	}
-
-	mimeType := string(output[start:end])
-
 	mimeCacheLock.Lock()
 	mimeCache[filePath] = mimeType
 	mimeCacheLock.Unlock()
@@ -719,8 +1811,36 @@ func getMimeType(filePath string) string {
 	return mimeType
 }
 
+// builtinExtensionMIME возвращает MIME-тип распространённого расширения ext
+// (с точкой) без обращения к файлу; пусто - расширение не из их числа
+func builtinExtensionMIME(ext string) string {
+	lowerExt := strings.ToLower(ext)
+	switch lowerExt {
+	case ".txt", ".md", ".log", ".conf", ".cfg":
+		return mimeTextPrefix + "plain"
+	case ".json":
+		return mimeApplicationJSON
+	case ".xml":
+		return mimeApplicationXML
+	case ".pdf":
+		return mimePDF
+	case ".png", ".jpg", ".jpeg", ".gif", ".bmp", ".webp", ".svg":
+		return mimeImagePrefix + lowerExt[1:]
+	case ".mp4", ".avi", ".mkv", ".mov":
+		return mimeVideoPrefix + lowerExt[1:]
+	}
+	return ""
+}
+
 // cachedLookPath кэширует результаты exec.LookPath
 func cachedLookPath(name string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	return cachedLookPathContext(ctx, name)
+}
+
+// cachedLookPathContext - cachedLookPath с дедлайном, заданным через ctx
+func cachedLookPathContext(ctx context.Context, name string) (string, error) {
 	pathCacheLock.RLock()
 	path, ok := pathCache[name]
 	pathCacheLock.RUnlock()
@@ -729,6 +1849,16 @@ func cachedLookPath(name string) (string, error) {
 		return path, nil
 	}
 
+	if fakeExecDir != "" {
+		path, err := fakeLookPath(name)
+		if err == nil {
+			pathCacheLock.Lock()
+			pathCache[name] = path
+			pathCacheLock.Unlock()
+		}
+		return path, err
+	}
+
 	if name == "cd" || name == "echo" || name == "exit" {
 		pathCacheLock.Lock()
 		pathCache[name] = name
@@ -766,51 +1896,221 @@ func cachedLookPath(name string) (string, error) {
 		return path, nil
 	case err := <-errChan:
 		return "", err
-	case <-time.After(100 * time.Millisecond):
+	case <-ctx.Done():
 		return "", fmt.Errorf("timeout looking up path for %s", name)
 	}
 }
 
-// launchApp запускает приложение для открытия файла
-func launchApp(appCommand string, filePath string) bool {
-	if appCommand == "" {
+// startApp запускает уже найденное в PATH приложение отдельной группой процессов
+func startApp(spec launchSpec, appPath string, appArgs []string, filePath string) bool {
+	profile, hasProfile := editorProfileFor(spec, appPath, appArgs)
+	tui := spec.TUI || tuiAssociations[spec.Key] || profile.tui
+	if isHeadless() && !tui {
+		fmt.Fprintf(logOut, "Error: %q is a GUI application, but there is no display (DISPLAY and WAYLAND_DISPLAY are unset)\n", filepath.Base(appPath))
 		return false
 	}
-
-	parts := strings.Fields(appCommand)
-	if len(parts) == 0 {
-		fmt.Fprintf(os.Stderr, "Error: Invalid empty application command.\n")
+	if wslSkipNative(appPath, tui) {
+		fmt.Fprintf(logOut, "Info: %q is a Linux GUI application and WSL has no display, trying the next candidate\n", filepath.Base(appPath))
 		return false
 	}
+	argPath := wslHostPath(appPath, filePath)
 
-	appName := parts[0]
-	appArgs := parts[1:]
+	// plain - путь просто добавляется в конец команды
+	plain := false
+	finalArgs := make([]string, 0, len(appArgs)+1)
+	if spec.Argv != nil {
+		finalArgs = append(finalArgs, appArgs...)
+	} else if hasProfile {
+		finalArgs = append(finalArgs, profile.args(appPath, argPath, spec.Line, tui)...)
+	} else if args, ok := expandCommandTemplate(appArgs, argPath, spec.Line); ok {
+		finalArgs = append(finalArgs, args...)
+	} else if args, ok := editorLineArgs(appPath, argPath, spec.Line); ok && spec.Line > 0 {
+		// Строка из grep -n, rg или --grep без шаблона {line} в команде
+		finalArgs = append(finalArgs, appArgs...)
+		finalArgs = append(finalArgs, args...)
+	} else {
+		plain = true
+		finalArgs = append(finalArgs, appArgs...)
+		finalArgs = append(finalArgs, argPath)
+	}
 
-	appPath, err := cachedLookPath(appName)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: Application command not found in PATH: %q\n", appName)
-		return false
+	if spec.ReadOnly && spec.Argv == nil {
+		if flags := readOnlyFlags(appPath); flags != nil {
+			finalArgs = append(flags, finalArgs...)
+			plain = false
+		} else {
+			fmt.Fprintf(logOut, "Info: %q has no read-only mode, saving %q will fail\n", filepath.Base(appPath), filePath)
+		}
 	}
 
-	finalArgs := make([]string, 0, len(appArgs)+1)
-	finalArgs = append(finalArgs, appArgs...)
-	finalArgs = append(finalArgs, filePath)
+	// Запрос восстановления vim в только что открытом окне застаёт врасплох
+	if spec.Argv == nil && isVimEditor(appPath) {
+		if swap := vimSwapFile(filePath); swap != "" {
+			flags, ok := vimSwapFlags(filePath, swap)
+			if !ok {
+				fmt.Fprintf(logOut, "Info: opening %q cancelled\n", filePath)
+				return true
+			}
+			finalArgs = append(flags, finalArgs...)
+			plain = false
+		}
+	}
 
-	cmd := exec.Command(appPath, finalArgs...)
+	if plain && openBatch != nil && multiAssociations[spec.Key] {
+		openBatch.add(spec, appPath, appArgs, argPath, tui)
+		return true
+	}
+	return runApp(spec, appPath, finalArgs, filePath, tui)
+}
 
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
-		Pgid:    0,
+// runApp запускает приложение с готовыми аргументами: терминальное - в
+// текущем терминале или новом окне, остальные - отдельной группой процессов
+func runApp(spec launchSpec, appPath string, finalArgs []string, filePath string, tui bool) bool {
+	// Терминальное приложение занимает текущий терминал, а без него - новое окно
+	announceLaunch(appPath, filePath)
+	if tui {
+		if isInteractive() {
+			return runAttached(spec, appPath, finalArgs, filePath)
+		}
+		if isTermux() {
+			fmt.Fprintf(logOut, "Error: %q needs a terminal, and Termux cannot open a new one\n", spec.Command)
+			return false
+		}
+		argv := inTerminal(append([]string{appPath}, finalArgs...))
+		appPath, finalArgs = argv[0], argv[1:]
 	}
 
+	cmd := execCommand(context.Background(), "launch", appPath, finalArgs...)
+
+	cmd.SysProcAttr = newProcessGroup()
+
 	cmd.Stdin = nil
 	cmd.Stdout = nil
 	cmd.Stderr = nil
 
 	if err := cmd.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error starting application %q for file %q: %v\n", appCommand, filePath, err)
+		fmt.Fprintf(logOut, "Error starting application %q for file %q: %v\n", spec.Command, filePath, err)
 		return false
 	}
 
+	superviseProcess(cmd, spec.Key)
 	return true
 }
+
+// launchSpec - команда для открытия файла и ключ ассоциации, из которой она взята
+type launchSpec struct {
+	Key     string
+	Command string
+
+	// Argv - готовая командная строка с уже подставленным путём к файлу
+	// (например, из Exec .desktop-файла); если задана, Command только для сообщений
+	Argv []string
+
+	// Line - номер строки для {line} в шаблоне команды, 0 - неизвестен
+	Line int
+
+	// TUI - приложение работает в терминале, даже если ключ не отмечен в [tui]
+	TUI bool
+
+	// ReadOnly - редактор открывает файл только для чтения (system_files)
+	ReadOnly bool
+}
+
+// argv возвращает команду и её аргументы
+func (s launchSpec) argv() []string {
+	if s.Argv != nil {
+		return s.Argv
+	}
+	return strings.Fields(s.Command)
+}
+
+// associationSpecs возвращает launchSpec для каждой команды цепочек ассоциаций
+// с ключами keys, сохраняя порядок
+func associationSpecs(keys ...string) []launchSpec {
+	var specs []launchSpec
+	for _, key := range keys {
+		// Ключ из [tui] важнее догадки по $EDITOR
+		_, explicitTUI := tuiAssociations[key]
+		for _, command := range associationChain(key) {
+			specs = append(specs, launchSpec{Key: key, Command: command,
+				TUI: key == assocTextEditor && envEditors[command] && !explicitTUI})
+		}
+	}
+	return specs
+}
+
+// associationChain возвращает цепочку команд из appAssociations по ключу ассоциации
+func associationChain(key string) CommandList {
+	rv := reflect.ValueOf(appAssociations)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		if rt.Field(i).Tag.Get("toml") == key {
+			return rv.Field(i).Interface().(CommandList)
+		}
+	}
+	return nil
+}
+
+// launchAttemptTimeout ограничивает поиск в PATH для одного кандидата в launchFirst
+const launchAttemptTimeout = 200 * time.Millisecond
+
+// launchFirst открывает файл первым запустившимся приложением из apps.
+// Кандидаты ищутся в PATH параллельно, каждый со своим дедлайном, но запускаются
+// строго по порядку, так что результат не зависит от того, какой поиск завершился первым.
+func launchFirst(ctx context.Context, filePath string, specs ...launchSpec) error {
+	specs = slices.Clone(specs)
+	paths := make([]string, len(specs))
+	lookupErrs := make([]error, len(specs))
+
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		parts := spec.argv()
+		if len(parts) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attemptCtx, cancel := context.WithTimeout(ctx, launchAttemptTimeout)
+			defer cancel()
+			paths[i], lookupErrs[i] = cachedLookPathContext(attemptCtx, parts[0])
+			// Приложение macOS, указанное по имени, открывается через open -a
+			if lookupErrs[i] != nil && spec.Argv == nil {
+				if argv, ok := appBundleArgv(spec.Command, filePath); ok {
+					specs[i].Argv = argv
+					paths[i], lookupErrs[i] = cachedLookPathContext(attemptCtx, argv[0])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tried := 0
+	for i, spec := range specs {
+		parts := spec.argv()
+		if len(parts) == 0 {
+			continue
+		}
+		tried++
+		if lookupErrs[i] != nil {
+			// Пропуск кандидата внутри цепочки ассоциации - не ошибка
+			if spec.Key != "" && i+1 < len(specs) && specs[i+1].Key == spec.Key {
+				fmt.Fprintf(logOut, "Info: %q is not in PATH, trying the next %s candidate\n", parts[0], spec.Key)
+			} else {
+				fmt.Fprintf(logOut, "Error: Application command not found in PATH: %q\n", parts[0])
+			}
+			continue
+		}
+		if startApp(spec, paths[i], parts[1:], filePath) {
+			return nil
+		}
+	}
+
+	if tried == 0 {
+		return errors.New("no application configured")
+	}
+	return fmt.Errorf("none of %d candidate application(s) could be started", tried)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"testing"
+)
+
+// stubUsers подменяет домашний каталог и базу пользователей для ~ и ~user
+func stubUsers(t *testing.T, home string, users map[string]string) {
+	t.Helper()
+	oldHome, oldLookup := userHomeDir, lookupUser
+	userHomeDir = home
+	lookupUser = func(name string) (*user.User, error) {
+		if dir, ok := users[name]; ok {
+			return &user.User{Username: name, HomeDir: dir}, nil
+		}
+		return nil, user.UnknownUserError(name)
+	}
+	t.Cleanup(func() { userHomeDir, lookupUser = oldHome, oldLookup })
+}
+
+func TestExpandPath(t *testing.T) {
+	tests := []struct {
+		goos string // пусто - любая система, "unix" - все, кроме Windows
+		path string
+		want string
+	}{
+		{"", "", ""},
+
+		{"unix", "~", "/home/me"},
+		{"unix", "~/docs//notes/", "/home/me/docs/notes"},
+		{"unix", "~alice", "/home/alice"},
+		{"unix", "~alice/src/./app", "/home/alice/src/app"},
+		{"unix", "~ghost/x", "~ghost/x"},
+		{"unix", "$FZF_OPEN_TEST_DIR/a", "/data/a"},
+		{"unix", "/a//b/./c/", "/a/b/c"},
+		{"unix", "%FZF_OPEN_TEST_DIR%/a", "%FZF_OPEN_TEST_DIR%/a"},
+
+		{"windows", `~`, `C:\Users\me`},
+		{"windows", `~\docs\\notes`, `C:\Users\me\docs\notes`},
+		{"windows", `~/docs`, `C:\Users\me\docs`},
+		{"windows", `~alice\x`, `~alice\x`},
+		{"windows", `%FZF_OPEN_TEST_DIR%\a`, `D:\data\a`},
+		{"windows", `%FZF_OPEN_TEST_UNSET%\a`, `%FZF_OPEN_TEST_UNSET%\a`},
+		{"windows", `c:`, `C:\`},
+		{"windows", `c:\Users\\me\`, `C:\Users\me`},
+		{"windows", `d:/data//x`, `D:\data\x`},
+		{"windows", `\\server\share\\dir`, `\\server\share\dir`},
+		{"windows", `//server/share/dir`, `\\server\share\dir`},
+	}
+
+	home, dataDir := "/home/me", "/data"
+	if runtime.GOOS == "windows" {
+		home, dataDir = `C:\Users\me`, `D:\data`
+	}
+	stubUsers(t, home, map[string]string{"alice": "/home/alice"})
+	t.Setenv("FZF_OPEN_TEST_DIR", dataDir)
+
+	for _, tt := range tests {
+		switch {
+		case tt.goos == "windows" && runtime.GOOS != "windows",
+			tt.goos == "unix" && runtime.GOOS == "windows":
+			continue
+		}
+		got, err := expandPath(tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("expandPath(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestExpandPathUnknownHome(t *testing.T) {
+	stubUsers(t, "", nil)
+	stubHomeSources(t, "", errors.New("$HOME is not defined"))
+	writePasswd(t, "")
+
+	if got, err := expandPath("~/x"); err == nil {
+		t.Fatalf("expandPath(~/x) = %q, want an error", got)
+	}
+}
+
+func TestExpandWindowsEnv(t *testing.T) {
+	t.Setenv("FZF_OPEN_TEST_DIR", `D:\data`)
+	t.Setenv("FZF_OPEN_TEST_EMPTY", "")
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`%FZF_OPEN_TEST_DIR%\a`, `D:\data\a`},
+		{`%FZF_OPEN_TEST_DIR%%FZF_OPEN_TEST_DIR%`, `D:\dataD:\data`},
+		{`x%FZF_OPEN_TEST_EMPTY%y`, `xy`},
+		{`%FZF_OPEN_TEST_UNSET%\a`, `%FZF_OPEN_TEST_UNSET%\a`},
+		{`100% %FZF_OPEN_TEST_DIR%`, `100% D:\data`},
+		{`%%\a`, `%%\a`},
+		{`a%FZF_OPEN_TEST_DIR`, `a%FZF_OPEN_TEST_DIR`},
+	}
+	for _, tt := range tests {
+		if got := expandWindowsEnv(tt.path); got != tt.want {
+			t.Errorf("expandWindowsEnv(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"", `''`},
+		{"plain", `'plain'`},
+		{"a b", `'a b'`},
+		{`$HOME "x" \n`, `'$HOME "x" '\\'n'`},
+		{`a\'b`, `'a'\\''\''b'`},
+		{"it's", `'it'\''s'`},
+		{"''", `''\'''\'''`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.s); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuoteRoundTrip(t *testing.T) {
+	// fish раскрывает \\ и \' и в одинарных кавычках; без fish в PATH
+	// проверяется только sh
+	for _, shell := range []string{"sh", "fish"} {
+		path, err := exec.LookPath(shell)
+		if err != nil {
+			continue
+		}
+		for _, s := range []string{"", "a b", "it's", `"$HOME"`, "`id` $(id) \\ !", "tab\tand\nnewline", "*?[a]",
+			`C:\\Users`, `a\'b`, `\`} {
+			out, err := exec.Command(path, "-c", "printf '%s' "+shellQuote(s)).Output()
+			if err != nil || string(out) != s {
+				t.Errorf("%s printed %q, %v for %q", shell, out, err, s)
+			}
+		}
+	}
+}
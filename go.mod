@@ -1,3 +1,10 @@
 module github.com/abshka/fzf-open-go
 
 go 1.24.0
+
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/fsnotify/fsnotify v1.9.0
+)
+
+require golang.org/x/sys v0.13.0 // indirect
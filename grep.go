@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// grepMode - флаг -g (--grep): fzf ищет по содержимому файлов. Список -
+// строки вида "путь:строка:текст" из rg --line-number, запрос сравнивается
+// только с текстом, а выбранный файл открывается на этой строке.
+var grepMode bool
+
+// grepSourceCommand возвращает команду списка для --grep: все непустые
+// строки текстовых файлов. rg, как и источник rg, учитывает .gitignore,
+// hidden, no_ignore и ignore и пропускает двоичные файлы.
+func grepSourceCommand() string {
+	args := append([]string{"rg", "--line-number", "--no-heading", "--color", "never"}, sourceThreadArgs()...)
+	if defaultConfig.Hidden {
+		args = append(args, "--hidden")
+	}
+	if defaultConfig.NoIgnore {
+		args = append(args, "--no-ignore")
+	}
+	for _, glob := range ignoreGlobs(ignorePatterns) {
+		args = append(args, "--glob", "!"+glob)
+	}
+	return quoteCommand(append(args, "--", "."))
+}
+
+// checkGrepMode проверяет, что для --grep есть rg и шелл, который запустит его
+// как источник списка
+func checkGrepMode() error {
+	if safeMode || !hasPosixShell() {
+		return fmt.Errorf("--grep needs a shell and is not available with --safe")
+	}
+	if _, err := cachedLookPath("rg"); err != nil {
+		return fmt.Errorf("--grep needs ripgrep (rg) in PATH")
+	}
+	return nil
+}
+
+// addGrepMode настраивает fzf на поиск по тексту строк: поля разделены
+// двоеточием, и запрос не совпадает с путём и номером строки
+func addGrepMode(opts *pickerOptions) {
+	if !grepMode {
+		return
+	}
+	opts.Delimiter = ":"
+	opts.Nth = "3.."
+	if opts.Prompt == "" {
+		opts.Prompt = "Grep> "
+	}
+}
+
+// previewLines показывает в превью строки файла вокруг line, выделяя её:
+// через bat, если он есть, иначе с номерами строк и отметкой
+func previewLines(path string, line int) {
+	start := max(1, line-previewTextLines/4)
+	end := start + previewTextLines - 1
+	for _, name := range []string{"bat", "batcat"} {
+		bat, err := cachedLookPath(name)
+		if err != nil {
+			continue
+		}
+		cmd := execCommand(context.Background(), "preview", bat, "--color=always", "--style=numbers", "--paging=never",
+			"--highlight-line="+strconv.Itoa(line), fmt.Sprintf("--line-range=%d:%d", start, end), path)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stdout
+		if cmd.Run() == nil {
+			return
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n := 1; n <= end && scanner.Scan(); n++ {
+		switch {
+		case n < start:
+		case n == line:
+			fmt.Printf("\033[7m%5d %s\033[0m\n", n, scanner.Text())
+		default:
+			fmt.Printf("%5d %s\n", n, scanner.Text())
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// gvfsSchemes - схемы сетевых и внешних расположений, которые GVFS монтирует
+// в FUSE-каталог ($XDG_RUNTIME_DIR/gvfs)
+var gvfsSchemes = map[string]bool{
+	"smb": true, "sftp": true, "ftp": true, "ftps": true, "dav": true, "davs": true,
+	"afp": true, "nfs": true, "mtp": true, "gphoto2": true, "afc": true,
+}
+
+// gvfsMounted - расположения, смонтированные fzf-open за этот запуск; их
+// предлагается отмонтировать перед выходом
+var gvfsMounted []string
+
+// Пункты меню отмонтирования
+const (
+	menuKeepMounted = "Keep mounted"
+	menuUnmount     = "Unmount"
+	menuUnmountWait = "Unmount after the application exits"
+)
+
+// isGVFSURI сообщает, является ли выбор адресом расположения GVFS (smb://, mtp://...)
+func isGVFSURI(s string) bool {
+	scheme, _, ok := strings.Cut(s, "://")
+	return ok && isURL(s) && gvfsSchemes[strings.ToLower(scheme)]
+}
+
+// gvfsLocalPath возвращает путь к адресу GVFS в FUSE-каталоге, при
+// необходимости смонтировав расположение через gio mount. gio может спросить
+// пароль, поэтому запускается в текущем терминале.
+func gvfsLocalPath(ctx context.Context, uri string) (string, error) {
+	if safeMode || kioskMode {
+		return "", fmt.Errorf("%s: network locations are not mounted in --safe and kiosk modes", uri)
+	}
+	gio, err := cachedLookPath("gio")
+	if err != nil {
+		return "", fmt.Errorf("gio is needed to open %s: %w", uri, err)
+	}
+
+	if path, ok := gioLocalPath(ctx, gio, uri); ok {
+		return path, nil
+	}
+
+	fmt.Fprintf(logOut, "Info: mounting %s\n", uri)
+	cmd := execCommand(ctx, "gvfs", gio, "mount", uri)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("gio mount %s: %w", uri, err)
+	}
+	gvfsMounted = append(gvfsMounted, uri)
+
+	path, ok := gioLocalPath(ctx, gio, uri)
+	if !ok {
+		return "", fmt.Errorf("%s is mounted, but has no local path (is gvfs-fuse running?)", uri)
+	}
+	return path, nil
+}
+
+// gioLocalPath читает путь в FUSE-каталоге из вывода gio info. Неудача -
+// расположение не смонтировано.
+func gioLocalPath(ctx context.Context, gio, uri string) (string, bool) {
+	out, err := execCommand(ctx, "gvfs", gio, "info", uri).Output()
+	if err != nil {
+		return "", false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "local path: "); ok && path != "" {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// offerGVFSUnmount предлагает отмонтировать расположения, смонтированные за
+// запуск. Пока приложение открывает файл, отмонтировать его нельзя, поэтому
+// по умолчанию отмонтирование ждёт завершения запущенных приложений; waited -
+// они уже завершились (флаг -w). Без терминала расположения остаются
+// смонтированными.
+func offerGVFSUnmount(waited bool) {
+	if len(gvfsMounted) == 0 {
+		return
+	}
+	if !isInteractive() {
+		for _, uri := range gvfsMounted {
+			fmt.Fprintf(logOut, "Info: %s stays mounted (unmount with gio mount -u)\n", uri)
+		}
+		return
+	}
+
+	unmount := menuUnmountWait
+	if waited {
+		unmount = menuUnmount
+	}
+	prompt := fmt.Sprintf("Unmount %s> ", strings.Join(gvfsMounted, ", "))
+	choice, err := fzfMenu(prompt, false, unmount, menuKeepMounted)
+	if err != nil || choice != unmount {
+		return
+	}
+	if !waited {
+		waitLaunched()
+	}
+
+	gio, err := cachedLookPath("gio")
+	if err != nil {
+		return
+	}
+	for _, uri := range gvfsMounted {
+		if out, err := execCommand(context.Background(), "gvfs", gio, "mount", "-u", uri).CombinedOutput(); err != nil {
+			fmt.Fprintf(logOut, "Error: could not unmount %s: %v %s\n", uri, err, strings.TrimSpace(string(out)))
+		}
+	}
+	gvfsMounted = nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+)
+
+// isHeadless сообщает, что графических приложений запустить негде: нет ни
+// X11, ни Wayland (например, сеанс SSH на сервере). В macOS, Windows, Termux
+// и WSL файлы открываются системой, а не через дисплей.
+func isHeadless() bool {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" || isTermux() || isWSL() {
+		return false
+	}
+	return os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == ""
+}
+
+// consoleSpecs возвращает консольные программы для файла без дисплея: текст и
+// каталоги открываются в $VISUAL или $EDITOR (а если они не заданы - в vi),
+// остальные файлы - в $PAGER или less
+func consoleSpecs(key string) []launchSpec {
+	group, envVars, last := "$PAGER", []string{"PAGER"}, "less"
+	if key == assocTextEditor || key == assocDirectoryOpener {
+		group, envVars, last = "$EDITOR", []string{"VISUAL", "EDITOR"}, "vi"
+	}
+
+	var specs []launchSpec
+	for _, envVar := range envVars {
+		if command := strings.TrimSpace(os.Getenv(envVar)); command != "" {
+			specs = append(specs, launchSpec{Key: group, Command: command, TUI: true})
+		}
+	}
+	return append(specs, launchSpec{Key: group, Command: last, TUI: true})
+}
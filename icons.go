@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Значки Nerd Fonts для каталогов и файлов без своего значка
+const (
+	iconDir  = "\uf07b"
+	iconFile = "\uf15b"
+)
+
+// extIcons - значки Nerd Fonts для расширений языков и форматов
+var extIcons = map[string]string{
+	"go": "\ue627", "py": "\ue606", "js": "\ue60c", "jsx": "\ue60c", "ts": "\ue628", "tsx": "\ue628",
+	"rs": "\ue7a8", "c": "\ue61e", "h": "\ue61e", "cpp": "\ue61d", "hpp": "\ue61d", "java": "\ue738",
+	"rb": "\ue739", "php": "\ue608", "lua": "\ue620", "sql": "\uf1c0",
+	"sh": "\uf489", "bash": "\uf489", "zsh": "\uf489", "fish": "\uf489",
+	"md": "\ue609", "markdown": "\ue609", "json": "\ue60b", "html": "\ue736", "htm": "\ue736", "css": "\ue749",
+	"yaml": "\ue615", "yml": "\ue615", "toml": "\ue615", "ini": "\ue615", "conf": "\ue615", "cfg": "\ue615",
+	"txt": "\uf15c", "log": "\uf15c",
+	"mp3": "\uf1c7", "ogg": "\uf1c7", "oga": "\uf1c7", "wav": "\uf1c7", "flac": "\uf1c7", "opus": "\uf1c7",
+	"aac": "\uf1c7", "m4a": "\uf1c7", "m3u": "\uf1c7", "m3u8": "\uf1c7", "pls": "\uf1c7",
+}
+
+// categoryIcons - значки категорий файлов по ключу ассоциации расширения
+var categoryIcons = map[string]string{
+	assocImageViewer:       "\uf1c5",
+	assocVideoPlayer:       "\uf1c8",
+	assocPDFViewer:         "\uf1c1",
+	assocDocxViewer:        "\uf1c2",
+	assocSpreadsheetEditor: "\uf1c3",
+	assocEbookReader:       "\uf02d",
+	assocFontViewer:        "\uf031",
+	assocArchiveManager:    "\uf1c6",
+	assocTorrentClient:     "\uf0ed",
+	assocWebBrowser:        "\ue736",
+	assocTextEditor:        "\uf15c",
+}
+
+// candidateIcons сообщает, что перед строками списка стоят значки (ключ
+// icons). Значок убирается из выбора при разборе вывода fzf и skim; строки
+// --grep и список сервера остаются без значков.
+func candidateIcons() bool {
+	return defaultConfig.Icons && currentFinder().fzfFlags() && !grepMode && !serverMode && !serverClient
+}
+
+// fileIcon возвращает значок для строки списка: каталога, если dir, иначе по
+// расширению или категории файла
+func fileIcon(line string, dir bool) string {
+	if dir || strings.HasSuffix(line, "/") {
+		return iconDir
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(line), "."))
+	if icon, ok := extIcons[ext]; ok {
+		return icon
+	}
+	if key := associationForExt(ext); key != "" && ext != "" {
+		return categoryIcons[key]
+	}
+	return iconFile
+}
+
+// stripIcon убирает значок и пробел после него из начала строки. Значки
+// Nerd Fonts лежат в области частного использования Unicode, поэтому имя
+// файла так начинаться почти не может.
+func stripIcon(line string) string {
+	r, size := utf8.DecodeRuneInString(line)
+	if unicode.Is(unicode.Co, r) && strings.HasPrefix(line[size:], " ") {
+		return line[size+1:]
+	}
+	return line
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keyBindings - таблица [keys]: клавиша fzf -> действие. Действие выполняется
+// для выбранных файлов вместо открытия; это может быть действие из [actions]
+// или одна из встроенных команд keyCommands. Пустое действие отключает клавишу.
+var keyBindings = defaultKeyBindings()
+
+// defaultKeyBindings возвращает встроенную таблицу [keys]: Enter открывает
+// файл как обычно, а эти клавиши - в редакторе и в просмотрщике
+func defaultKeyBindings() map[string]string {
+	return map[string]string{
+		"ctrl-o": "open-in-editor",
+		"ctrl-v": "open-in-viewer",
+	}
+}
+
+// keyCommands - встроенные команды для [keys]. Шелл им не нужен, поэтому они
+// работают и в режиме --safe.
+var keyCommands = map[string]func(context.Context, []string) error{
+	"open":           openCommand,
+	"copy-path":      copyPathsCommand,
+	"print-path":     printPathsCommand,
+	"open-in-editor": openInEditorCommand,
+	"open-in-viewer": openInViewerCommand,
+	"open-dir":       openDirCommand,
+}
+
+// openCommand открывает выбранные файлы настроенными приложениями, как Enter;
+// нужна, если Enter назначен другой команде
+func openCommand(ctx context.Context, files []string) error {
+	var errs []error
+	for _, file := range files {
+		errs = append(errs, openFileWithConfiguredApp(ctx, file))
+	}
+	return errors.Join(errs...)
+}
+
+// copyPathsCommand копирует пути выбранных файлов в буфер обмена, по одному в строке
+func copyPathsCommand(ctx context.Context, files []string) error {
+	return copyToClipboard(strings.Join(files, "\n"))
+}
+
+// printPathsCommand печатает пути выбранных файлов по одному в строке
+func printPathsCommand(ctx context.Context, files []string) error {
+	for _, file := range files {
+		fmt.Println(file)
+	}
+	return nil
+}
+
+// openInEditorCommand открывает выбранные файлы в text_editor, минуя правила
+func openInEditorCommand(ctx context.Context, files []string) error {
+	var errs []error
+	for _, file := range files {
+		errs = append(errs, launchFirst(ctx, file, associationSpecs(assocTextEditor)...))
+	}
+	return errors.Join(errs...)
+}
+
+// openInViewerCommand показывает выбранные файлы в $PAGER или less в терминале
+func openInViewerCommand(ctx context.Context, files []string) error {
+	var errs []error
+	for _, file := range files {
+		errs = append(errs, launchFirst(ctx, file, consoleSpecs("")...))
+	}
+	return errors.Join(errs...)
+}
+
+// openDirCommand открывает каталоги выбранных файлов (каталог - сам себя)
+func openDirCommand(ctx context.Context, files []string) error {
+	seen := map[string]bool{}
+	var errs []error
+	for _, file := range files {
+		dir := file
+		if fi, err := os.Stat(file); err != nil || !fi.IsDir() {
+			dir = filepath.Dir(file)
+		}
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		errs = append(errs, launchFirst(ctx, dir, directorySpecs()...))
+	}
+	return errors.Join(errs...)
+}
+
+// normalizeKey приводит имя клавиши fzf к виду ключей keyBindings
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.TrimSpace(key))
+}
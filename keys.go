@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// unknownKey - неизвестный ключ файла конфигурации и ближайший допустимый
+type unknownKey struct {
+	path       []string
+	suggestion string
+}
+
+func (k unknownKey) String() string {
+	msg := fmt.Sprintf("unknown key %q", strings.Join(k.path, "."))
+	if k.suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", k.suggestion)
+	}
+	return msg
+}
+
+// decodeConfigFile декодирует файл конфигурации в v и проверяет, что в нём нет
+// неизвестных ключей. Если в файле задано fuzzy_keys = true, опечатки с
+// подсказкой исправляются с предупреждением, а не считаются ошибкой; fuzzy
+// сообщает значение этого ключа после декодирования. Возвращает ключи,
+// заданные в файле (после исправления опечаток).
+func decodeConfigFile(path string, v any, fuzzy func() bool) (definedKeys, error) {
+	md, err := toml.DecodeFile(path, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	unknown := unknownKeys(md, v)
+	if len(unknown) == 0 {
+		return keysOf(md), nil
+	}
+
+	if fuzzy() {
+		if md, unknown, err = fixUnknownKeys(path, v, unknown); err != nil {
+			return nil, err
+		}
+		if len(unknown) == 0 {
+			return keysOf(md), nil
+		}
+	}
+
+	msgs := make([]string, len(unknown))
+	for i, k := range unknown {
+		msgs[i] = k.String()
+	}
+	return nil, fmt.Errorf("%s: %s", path, strings.Join(msgs, "; "))
+}
+
+// associationTables - таблицы, ключами которых служат ключи ассоциаций
+var associationTables = map[string]bool{"timeouts": true, "tui": true, "multi": true}
+
+// unknownKeys возвращает ключи, которые не соответствуют ни одному полю v,
+// а также ключи таблиц associationTables, не являющиеся ключами ассоциаций
+func unknownKeys(md toml.MetaData, v any) []unknownKey {
+	var unknown []unknownKey
+	for _, key := range md.Undecoded() {
+		path := []string(key)
+		if underUnknownTable(unknown, path) {
+			continue
+		}
+		candidates := keysAt(reflect.TypeOf(v), path[:len(path)-1])
+		unknown = append(unknown, unknownKey{path: path, suggestion: suggestKey(path[len(path)-1], candidates)})
+	}
+
+	for _, key := range md.Keys() {
+		path := []string(key)
+		if len(path) < 2 || !associationTables[path[len(path)-2]] {
+			continue
+		}
+		name := path[len(path)-1]
+		if isAssociationKey(name) {
+			continue
+		}
+		unknown = append(unknown, unknownKey{path: path, suggestion: suggestKey(name, associationKeys())})
+	}
+	return unknown
+}
+
+// underUnknownTable сообщает, лежит ли ключ внутри уже найденной неизвестной таблицы
+func underUnknownTable(unknown []unknownKey, path []string) bool {
+	for _, k := range unknown {
+		if len(k.path) < len(path) && slices.Equal(k.path, path[:len(k.path)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// fixUnknownKeys переименовывает ключи с подсказкой и декодирует файл заново.
+// Возвращает метаданные нового декодирования и ключи, которые исправить не удалось.
+func fixUnknownKeys(path string, v any, unknown []unknownKey) (toml.MetaData, []unknownKey, error) {
+	var raw map[string]any
+	if _, err := toml.DecodeFile(path, &raw); err != nil {
+		return toml.MetaData{}, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var rest []unknownKey
+	for _, k := range unknown {
+		if k.suggestion == "" {
+			rest = append(rest, k)
+			continue
+		}
+		renameKey(raw, k.path, k.suggestion)
+		fmt.Fprintf(logOut, "Warning: %s: unknown key %q, using %q\n", path, strings.Join(k.path, "."), k.suggestion)
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(raw); err != nil {
+		return toml.MetaData{}, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+	md, err := toml.Decode(buf.String(), v)
+	if err != nil {
+		return toml.MetaData{}, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return md, rest, nil
+}
+
+// renameKey переименовывает последний элемент path в name. Массивы таблиц
+// обходятся целиком, так как путь ключа не содержит индекса.
+func renameKey(node any, path []string, name string) {
+	switch n := node.(type) {
+	case map[string]any:
+		if len(path) == 1 {
+			if value, ok := n[path[0]]; ok {
+				delete(n, path[0])
+				n[name] = value
+			}
+			return
+		}
+		renameKey(n[path[0]], path[1:], name)
+	case []map[string]any:
+		for _, item := range n {
+			renameKey(item, path, name)
+		}
+	case []any:
+		for _, item := range n {
+			renameKey(item, path, name)
+		}
+	}
+}
+
+// keysAt возвращает допустимые ключи таблицы, расположенной по пути path внутри типа t
+func keysAt(t reflect.Type, path []string) []string {
+	for _, key := range path {
+		t = indirectType(t)
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := tomlFields(t)[key]
+			if !ok {
+				return nil
+			}
+			t = field
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil
+		}
+	}
+
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	keys := make([]string, 0, t.NumField())
+	for key := range tomlFields(t) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// indirectType снимает указатели и срезы (массивы таблиц)
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t
+}
+
+// tomlFields возвращает поля структуры по их toml-ключам, включая поля встроенных структур
+func tomlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for key, ft := range tomlFields(f.Type) {
+				fields[key] = ft
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// associationKeys возвращает ключи таблицы [associations]
+func associationKeys() []string {
+	return keysAt(reflect.TypeOf(AppAssociations{}), nil)
+}
+
+// isAssociationKey сообщает, является ли key ключом ассоциации
+func isAssociationKey(key string) bool {
+	_, ok := tomlFields(reflect.TypeOf(AppAssociations{}))[key]
+	return ok
+}
+
+// checkRuleTarget не даёт опечатке в ключе ассоциации (open = "imageviwer")
+// превратиться в команду, которой нет в PATH
+func checkRuleTarget(target string) error {
+	if strings.ContainsAny(target, " \t") || isAssociationKey(target) {
+		return nil
+	}
+	suggestion := suggestKey(target, associationKeys())
+	if suggestion == "" {
+		return nil
+	}
+	if _, err := cachedLookPath(target); err == nil {
+		return nil
+	}
+	return fmt.Errorf("unknown association %q (did you mean %q?)", target, suggestion)
+}
+
+// suggestKey возвращает ближайший к key допустимый ключ или пустую строку,
+// если ни один не похож на опечатку
+func suggestKey(key string, candidates []string) string {
+	key = strings.ToLower(key)
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		d := editDistance(key, c)
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+
+	if bestDist < 0 || bestDist > 3 || bestDist*2 >= len(best) {
+		return ""
+	}
+	return best
+}
+
+// editDistance - расстояние Левенштейна между строками
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// kioskMode - режим киоска (флаг --kiosk или kiosk = true) для общих терминалов
+// и демонстрационных машин: открываются только файлы из каталогов kiosk_roots,
+// каталоги не передаются файловому менеджеру, а действия, --with, «Open with…»
+// и меню, которые запускают команды (systemd, сборка пакетов), отключены.
+// Файл проекта .fzf-open.toml в этом режиме не читается.
+var kioskMode bool
+
+// kioskRoots - разрешённые каталоги режима киоска: абсолютные пути без
+// символических ссылок
+var kioskRoots []string
+
+// kioskKeyCommands - встроенные команды [keys], которые остаются в режиме киоска
+var kioskKeyCommands = map[string]bool{
+	"open": true, "copy-path": true, "print-path": true, "open-in-editor": true, "open-in-viewer": true,
+}
+
+// setupKiosk вычисляет разрешённые каталоги и приводит к ним начальный
+// каталог. Без kiosk_roots разрешён только начальный каталог.
+func setupKiosk(cfg *Config) error {
+	roots := defaultConfig.KioskRoots
+	if len(roots) == 0 {
+		roots = []string{cfg.StartingDir}
+	}
+
+	kioskRoots = nil
+	for _, root := range roots {
+		dir, err := expandPath(root)
+		if err == nil {
+			dir, err = resolvedPath(dir)
+		}
+		if err == nil {
+			if fi, statErr := os.Stat(dir); statErr != nil || !fi.IsDir() {
+				err = errors.New("not a directory")
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(logOut, "Warning: skipping kiosk root %q: %v\n", root, err)
+			continue
+		}
+		kioskRoots = append(kioskRoots, dir)
+	}
+	if len(kioskRoots) == 0 {
+		return errors.New("kiosk mode: none of kiosk_roots is accessible")
+	}
+
+	if !kioskAllowed(cfg.StartingDir) {
+		fmt.Fprintf(logOut, "Warning: %q is outside kiosk_roots, starting in %q\n", cfg.StartingDir, kioskRoots[0])
+		cfg.StartingDir = kioskRoots[0]
+	}
+	if cfg.With != "" {
+		fmt.Fprintln(logOut, "Warning: --with is disabled in kiosk mode")
+		cfg.With = ""
+	}
+	return nil
+}
+
+// resolvedPath возвращает абсолютный путь без символических ссылок
+func resolvedPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// kioskAllowed сообщает, лежит ли путь внутри одного из kioskRoots. Ссылки
+// раскрываются, чтобы нельзя было выйти за корень по символической ссылке.
+// Вне режима киоска разрешено всё.
+func kioskAllowed(path string) bool {
+	if !kioskMode {
+		return true
+	}
+	// Строка вида "путь:строка:текст" из --grep
+	if _, err := os.Stat(path); err != nil {
+		if file, _, ok := splitLineSuffix(path); ok {
+			path = file
+		}
+	}
+	resolved, err := resolvedPath(path)
+	if err != nil {
+		return false
+	}
+	for _, root := range kioskRoots {
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkKioskPath не даёт открыть в режиме киоска файл вне kioskRoots или каталог
+func checkKioskPath(path string, fi os.FileInfo) error {
+	if !kioskMode {
+		return nil
+	}
+	if !kioskAllowed(path) {
+		return fmt.Errorf("%q is outside kiosk_roots", path)
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("opening directories is disabled in kiosk mode: %q", path)
+	}
+	return nil
+}
+
+// kioskPaths отбрасывает выбранные пути вне kioskRoots
+func kioskPaths(paths []string) []string {
+	if !kioskMode {
+		return paths
+	}
+	allowed := paths[:0:0]
+	for _, path := range paths {
+		if kioskAllowed(path) {
+			allowed = append(allowed, path)
+		} else {
+			fmt.Fprintf(logOut, "Error: %q is outside kiosk_roots\n", path)
+		}
+	}
+	return allowed
+}
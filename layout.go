@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LayoutConfig - набор окон из таблицы [layouts.<имя>], которые открываются
+// рядом друг с другом в тайловом композиторе
+type LayoutConfig struct {
+	// Tiles - MIME-типы или шаблоны вида "text/*" по порядку окон: первый
+	// выбранный файл подходящего типа открывается в первом окне и так далее.
+	// Файлы, не подошедшие ни к одному окну, открываются следом.
+	Tiles []string `toml:"tiles"`
+	// Direction - куда ставится следующее окно: "right" (по умолчанию) или "down"
+	Direction string `toml:"direction,omitempty"`
+	// Split - команда шелла, которая готовит место для следующего окна вместо
+	// встроенной для Hyprland, sway и i3; в режиме --safe не используется
+	Split string `toml:"split,omitempty"`
+}
+
+// layouts - наборы окон по именам
+var layouts = map[string]LayoutConfig{}
+
+// layoutActionPrefix - префикс действия в [keys], которое открывает выбранные
+// файлы набором окон: "layout:<имя>"
+const layoutActionPrefix = "layout:"
+
+// layoutWindowWait - сколько ждать окна файла перед следующим, если
+// window_wait не задан
+const layoutWindowWait = 3 * time.Second
+
+// Направления из LayoutConfig.Direction
+const (
+	layoutRight = "right"
+	layoutDown  = "down"
+)
+
+// isLayoutAction сообщает, открывает ли действие набор окон
+func isLayoutAction(name string) bool {
+	return strings.HasPrefix(name, layoutActionPrefix)
+}
+
+// openLayout открывает файлы набором окон name: файлы раскладываются по окнам
+// набора, перед каждым следующим окном композитору сообщается, где его
+// поставить, и fzf-open ждёт, пока окно появится
+func openLayout(ctx context.Context, name string, files []string) error {
+	layout, ok := layouts[name]
+	if !ok {
+		return fmt.Errorf("unknown layout %q", name)
+	}
+	switch layout.Direction {
+	case "", layoutRight, layoutDown:
+	default:
+		return fmt.Errorf("layout %q: direction must be %q or %q", name, layoutRight, layoutDown)
+	}
+
+	split := layoutSplitCommand(layout)
+	if split == nil && len(files) > 1 {
+		fmt.Fprintln(logOut, "Warning: no supported compositor found, opening files without a layout")
+	}
+
+	wait := defaultConfig.WindowWait
+	if wait <= 0 {
+		wait = layoutWindowWait
+	}
+
+	var errs []error
+	for i, file := range arrangeTiles(layout.Tiles, files) {
+		if i > 0 && split != nil {
+			if err := split(); err != nil {
+				fmt.Fprintf(logOut, "Warning: layout %q: %v\n", name, err)
+			}
+		}
+		before := launchedCount()
+		if err := openFileWithConfiguredApp(ctx, file); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		waitForWindow(launchedSince(before), wait)
+	}
+	return errors.Join(errs...)
+}
+
+// arrangeTiles упорядочивает файлы по окнам набора: каждому окну достаётся
+// первый ещё не занятый файл подходящего MIME-типа, остальные файлы идут
+// следом в порядке выбора
+func arrangeTiles(tiles []string, files []string) []string {
+	mimeTypes := make([]string, len(files))
+	for i, file := range files {
+		mimeTypes[i] = getMimeType(file)
+	}
+
+	used := make([]bool, len(files))
+	ordered := make([]string, 0, len(files))
+	for _, tile := range tiles {
+		for i, file := range files {
+			if !used[i] && mimeMatches(tile, mimeTypes[i]) {
+				used[i] = true
+				ordered = append(ordered, file)
+				break
+			}
+		}
+	}
+	for i, file := range files {
+		if !used[i] {
+			ordered = append(ordered, file)
+		}
+	}
+	return ordered
+}
+
+// layoutSplitCommand возвращает функцию, которая готовит место для следующего
+// окна: команду split из набора или встроенную для композитора. nil - сделать
+// это нечем.
+func layoutSplitCommand(layout LayoutConfig) func() error {
+	if layout.Split != "" && !safeMode {
+		return func() error {
+			argv := shellArgv(layout.Split)
+			return execCommand(context.Background(), "layout", argv[0], argv[1:]...).Run()
+		}
+	}
+
+	argv := compositorSplitArgv(layout.Direction == layoutDown)
+	if argv == nil {
+		return nil
+	}
+	return func() error {
+		path, err := cachedLookPath(argv[0])
+		if err != nil {
+			return err
+		}
+		return execCommand(context.Background(), "layout", path, argv[1:]...).Run()
+	}
+}
+
+// compositorSplitArgv возвращает команду композитора, после которой новое
+// окно откроется справа от текущего (или под ним при down): в Hyprland -
+// preselect раскладки dwindle, в sway и i3 - splith/splitv
+func compositorSplitArgv(down bool) []string {
+	switch {
+	case compositorAvailable("HYPRLAND_INSTANCE_SIGNATURE", "hyprctl"):
+		dir := "r"
+		if down {
+			dir = "d"
+		}
+		return []string{"hyprctl", "dispatch", "layoutmsg", "preselect", dir}
+	case compositorAvailable("SWAYSOCK", "swaymsg"):
+		return []string{"swaymsg", splitCommand(down)}
+	case compositorAvailable("I3SOCK", "i3-msg"):
+		return []string{"i3-msg", splitCommand(down)}
+	}
+	return nil
+}
+
+// splitCommand - команда sway и i3 для направления набора
+func splitCommand(down bool) string {
+	if down {
+		return "splitv"
+	}
+	return "splith"
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// listMaxSize - файлы больше этого размера не рассматриваются как списки путей
+	listMaxSize = 64 * 1024
+	// listMinEntries - минимум записей, чтобы текстовый файл считался списком
+	listMinEntries = 2
+)
+
+// extToListFile - расширения, для которых проверяется, не является ли файл списком путей
+var extToListFile = map[string]struct{}{"lst": {}, "list": {}, "txt": {}, "": {}}
+
+// readPathList возвращает записи файла, если каждая непустая строка (кроме
+// комментариев #) - URL или существующий путь. Относительные пути разрешаются
+// от каталога самого файла.
+func readPathList(filePath string) ([]string, bool) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	baseDir := filepath.Dir(filePath)
+	var entries []string
+
+	scanner := bufio.NewScanner(io.LimitReader(f, listMaxSize+1))
+	read := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		read += len(line) + 1
+		if read > listMaxSize {
+			return nil, false
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if isURL(line) {
+			entries = append(entries, line)
+			continue
+		}
+
+		entry, err := expandPath(line)
+		if err != nil {
+			return nil, false
+		}
+		if !filepath.IsAbs(entry) {
+			entry = filepath.Join(baseDir, entry)
+		}
+		if _, err := os.Stat(entry); err != nil {
+			return nil, false
+		}
+		entries = append(entries, entry)
+	}
+	if scanner.Err() != nil || len(entries) < listMinEntries {
+		return nil, false
+	}
+	return entries, true
+}
+
+// isURL сообщает, похожа ли строка на URL со схемой (https://, file://, smb://...)
+func isURL(s string) bool {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok || scheme == "" || rest == "" {
+		return false
+	}
+	for _, c := range scheme {
+		if !isASCIILetter(byte(c)) && !strings.ContainsRune("+-.", c) && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// offerOpenList предлагает открыть все записи файла-списка. Возвращает true,
+// если пользователь сделал выбор (открыть все или отменить), и false, если файл
+// нужно открыть обычным образом.
+func offerOpenList(ctx context.Context, filePath string, ext string) (bool, error) {
+	if _, ok := extToListFile[ext]; !ok || !isInteractive() {
+		return false, nil
+	}
+
+	entries, ok := readPathList(filePath)
+	if !ok {
+		return false, nil
+	}
+
+	openAll := fmt.Sprintf("Open all %d entries", len(entries))
+	choice, err := fzfMenu(filepath.Base(filePath)+" is a list> ", false, openAll, "Open as text", menuCancel)
+	if err != nil || choice == "" || choice == menuCancel {
+		return true, nil
+	}
+	if choice != openAll {
+		return false, nil
+	}
+
+	var failed int
+	for _, entry := range entries {
+		var err error
+		if isGVFSURI(entry) {
+			var localPath string
+			if localPath, err = gvfsLocalPath(ctx, entry); err == nil {
+				err = openPath(ctx, localPath, false)
+			}
+		} else if isURL(entry) {
+			err = launchFirst(ctx, entry, associationSpecs(assocWebBrowser, assocFallbackOpener)...)
+		} else {
+			err = openPath(ctx, entry, false)
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(logOut, "Error opening list entry %q: %v\n", entry, err)
+		}
+	}
+
+	if failed > 0 {
+		return true, fmt.Errorf("%d of %d list entries could not be opened", failed, len(entries))
+	}
+	return true, nil
+}
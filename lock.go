@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// lockTimeout - сколько ждать блокировку, занятую другим процессом fzf-open
+	lockTimeout = 5 * time.Second
+	// lockRetryInterval - период повторных попыток взять блокировку
+	lockRetryInterval = 20 * time.Millisecond
+)
+
+// errLocked - блокировка занята другим процессом
+var errLocked = errors.New("locked by another process")
+
+// withFileLock выполняет fn под рекомендательной блокировкой path+".lock".
+// Общее состояние (кэши, журналы) меняют параллельные вызовы fzf-open - при
+// запуске по горячей клавише их бывает несколько сразу, - поэтому запись в
+// такие файлы идёт только под блокировкой.
+func withFileLock(path string, fn func() error) error {
+	unlock, err := lockFile(path+".lock", lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+// lockFile берёт блокировку, повторяя попытки до истечения timeout. Нулевой
+// timeout - одна попытка.
+func lockFile(lockPath string, timeout time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		unlock, err := tryLockFile(lockPath)
+		if !errors.Is(err, errLocked) {
+			return unlock, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("waiting for %s: %w", lockPath, err)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// pruneOrphanLocks удаляет в dir файлы блокировок <запись>.lock, записи кэша
+// которых уже нет и которые никто не держит
+func pruneOrphanLocks(dir string) {
+	locks, err := filepath.Glob(filepath.Join(dir, "*.lock"))
+	if err != nil {
+		return
+	}
+	for _, lockPath := range locks {
+		if _, err := os.Lstat(strings.TrimSuffix(lockPath, ".lock")); err == nil {
+			continue
+		}
+		unlock, err := tryLockFile(lockPath)
+		if err != nil {
+			continue
+		}
+		os.Remove(lockPath)
+		unlock()
+	}
+}
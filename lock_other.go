@@ -0,0 +1,30 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// lockStaleAge - файл блокировки старше этого считается брошенным. Не меньше
+// самого долгого владельца - конвертации, которую ждут convertLockTimeout.
+const lockStaleAge = convertLockTimeout
+
+// tryLockFile - без flock блокировкой служит сам файл, созданный через O_EXCL
+func tryLockFile(lockPath string) (func(), error) {
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err == nil {
+		f.Close()
+		return func() { os.Remove(lockPath) }, nil
+	}
+	if !errors.Is(err, fs.ErrExist) {
+		return nil, err
+	}
+	if fi, err := os.Stat(lockPath); err == nil && time.Since(fi.ModTime()) > lockStaleAge {
+		os.Remove(lockPath)
+	}
+	return nil, errLocked
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile берёт flock на файл блокировки без ожидания. Блокировка
+// снимается и при аварийном завершении процесса, так что брошенных
+// блокировок не бывает; сам файл удаляется только вместе с записью кэша.
+func tryLockFile(lockPath string) (func(), error) {
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	// Пока мы ждали, прежний владелец мог удалить файл вместе с записью кэша:
+	// блокировка удалённого файла никого не исключает, нужна новая попытка
+	held, err1 := f.Stat()
+	current, err2 := os.Stat(lockPath)
+	if err1 != nil || err2 != nil || !os.SameFile(held, current) {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+		return nil, errLocked
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,53 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPruneOrphanLocks(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"kept.txt", "kept.txt.lock", "orphan.txt.lock", "held.txt.lock"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unlock, err := tryLockFile(filepath.Join(dir, "held.txt.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	pruneOrphanLocks(dir)
+
+	for name, want := range map[string]bool{"kept.txt.lock": true, "orphan.txt.lock": false, "held.txt.lock": true} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if got := err == nil; got != want {
+			t.Errorf("%s exists = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestConvertFileFailureRemovesLock(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	t.Setenv("FZF_OPEN_PER_HOST", "0")
+	src := filepath.Join(t.TempDir(), "doc.odt")
+	if err := os.WriteFile(src, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := convertFile(src, ConverterConfig{From: []string{"odt"}, To: "pdf", Command: "exit 1"}); err == nil {
+		t.Fatal("convertFile() succeeded with a failing converter")
+	}
+	entries, err := os.ReadDir(filepath.Join(cache, appDirName, "converted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("left behind after a failed conversion: %s", e.Name())
+	}
+}
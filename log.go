@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	logFileName = "fzf-open.log"
+	// logMaxSize - при превышении размера лог начинается заново
+	logMaxSize = 512 * 1024
+)
+
+// logOut - куда пишутся предупреждения и ошибки. По умолчанию stderr,
+// после openLog - stderr плюс лог-файл в каталоге состояния.
+var logOut io.Writer = os.Stderr
+
+// timestampWriter дописывает в файл каждую запись с меткой времени
+type timestampWriter struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func (w *timestampWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Одна запись на строку: с O_APPEND она не перемешивается со строками
+	// параллельных процессов
+	line := append([]byte(time.Now().Format(time.RFC3339)+" "), p...)
+	if _, err := w.f.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// logPath возвращает путь к лог-файлу
+func logPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, logFileName), nil
+}
+
+// openLog подключает лог-файл к logOut. Ошибки не фатальны: без лога утилита
+// продолжает писать только в stderr.
+func openLog() {
+	path, err := logPath()
+	if err != nil {
+		return
+	}
+	if err := ensurePrivateDir(filepath.Dir(path)); err != nil {
+		return
+	}
+
+	// Под блокировкой лог обрезает только один из одновременно запущенных процессов
+	var f *os.File
+	err = withFileLock(path, func() error {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		if fi, err := os.Stat(path); err == nil && fi.Size() > logMaxSize {
+			flags |= os.O_TRUNC
+		}
+		f, err = os.OpenFile(path, flags, 0o600)
+		return err
+	})
+	if err != nil {
+		return
+	}
+	// Лог содержит имена открываемых файлов; файл мог остаться от старых версий
+	f.Chmod(0o600)
+
+	logOut = io.MultiWriter(os.Stderr, &timestampWriter{f: f})
+}
+
+// tailLog возвращает последние n строк лог-файла
+func tailLog(n int) ([]string, error) {
+	path, err := logPath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := make([]string, 0, n)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(lines) == n {
+			lines = lines[1:]
+		}
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"maps"
+	"os"
+	"path"
+	"strings"
+)
+
+// dircolorsDatabases - базы dircolors, из которых шелл обычно берёт LS_COLORS
+// (eval "$(dircolors ~/.dircolors)"); используются, если LS_COLORS не задана
+var dircolorsDatabases = []string{"~/.dircolors", "~/.dir_colors", "/etc/DIR_COLORS"}
+
+// dircolorsKeys - ключевые слова базы dircolors и соответствующие им ключи LS_COLORS
+var dircolorsKeys = map[string]string{
+	"NORMAL": "no", "NORM": "no", "FILE": "fi", "RESET": "rs", "DIR": "di",
+	"LINK": "ln", "LNK": "ln", "SYMLINK": "ln", "ORPHAN": "or", "MISSING": "mi",
+	"MULTIHARDLINK": "mh", "FIFO": "pi", "PIPE": "pi", "SOCK": "so", "DOOR": "do",
+	"BLK": "bd", "BLOCK": "bd", "CHR": "cd", "CHAR": "cd", "EXEC": "ex",
+	"SETUID": "su", "SETGID": "sg", "CAPABILITY": "ca", "STICKY": "st",
+	"OTHER_WRITABLE": "ow", "OWR": "ow", "STICKY_OTHER_WRITABLE": "tw", "OWT": "tw",
+}
+
+// lsDefaultColors - цвета, которые ls из coreutils использует для видов файлов,
+// не заданных в LS_COLORS
+var lsDefaultColors = map[string]string{
+	"di": "01;34", "ln": "01;36", "pi": "33", "so": "01;35", "do": "01;35",
+	"bd": "01;33", "cd": "01;33", "ex": "01;32", "su": "37;41", "sg": "30;43",
+	"st": "37;44", "ow": "34;42", "tw": "30;42",
+}
+
+// lsColors - палитра списка: цвета ls из LS_COLORS или базы dircolors, а без
+// них - встроенные цвета категорий
+type lsColors struct {
+	keys     map[string]string // цвета видов файлов: di, ln, ex, fi и другие
+	suffixes []lsSuffix        // цвета окончаний имён (*.tar.gz=...) в порядке LS_COLORS
+	fromLS   bool              // палитра ls; иначе имена красятся по категориям
+}
+
+// lsSuffix - цвет имён с окончанием suffix (записи *suffix=код)
+type lsSuffix struct {
+	suffix, code string
+}
+
+// readLSColors возвращает палитру из LS_COLORS или базы dircolors; без них -
+// встроенную палитру категорий
+func readLSColors() lsColors {
+	value := lsColorsValue()
+	if value == "" {
+		return lsColors{keys: map[string]string{"di": colorDir}}
+	}
+	return parseLSColors(value)
+}
+
+// lsColorsValue возвращает значение LS_COLORS, а если переменная не задана -
+// значение, которое dircolors построил бы из базы пользователя или системы
+func lsColorsValue() string {
+	if value := os.Getenv("LS_COLORS"); value != "" {
+		return value
+	}
+	for _, database := range dircolorsDatabases {
+		file, err := expandPath(database)
+		if err != nil {
+			continue
+		}
+		if value, err := readDircolors(file); err == nil && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseLSColors разбирает значение LS_COLORS
+func parseLSColors(value string) lsColors {
+	colors := lsColors{keys: maps.Clone(lsDefaultColors), fromLS: true}
+	for _, entry := range strings.Split(value, ":") {
+		pattern, code, ok := strings.Cut(entry, "=")
+		switch {
+		case !ok:
+		case strings.HasPrefix(pattern, "*") && len(pattern) > 1:
+			colors.suffixes = append(colors.suffixes, lsSuffix{pattern[1:], code})
+		case len(pattern) == 2:
+			colors.keys[pattern] = code
+		}
+	}
+	return colors
+}
+
+// readDircolors переводит базу dircolors в значение LS_COLORS. Записи после
+// строк TERM и COLORTERM действуют, только если терминал подходит под одну
+// из них, как в dircolors.
+func readDircolors(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var entries []string
+	term, colorTerm := os.Getenv("TERM"), os.Getenv("COLORTERM")
+	matched, inTerms := true, false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		keyword, arg := fields[0], fields[1]
+		switch strings.ToUpper(keyword) {
+		case "TERM", "COLORTERM":
+			if !inTerms {
+				matched, inTerms = false, true
+			}
+			value := term
+			if strings.ToUpper(keyword) == "COLORTERM" {
+				value = colorTerm
+			}
+			if ok, _ := path.Match(arg, value); ok {
+				matched = true
+			}
+			continue
+		}
+		inTerms = false
+		if !matched {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(keyword, "."):
+			entries = append(entries, "*"+keyword+"="+arg)
+		case strings.HasPrefix(keyword, "*"):
+			entries = append(entries, keyword+"="+arg)
+		case dircolorsKeys[strings.ToUpper(keyword)] != "":
+			entries = append(entries, dircolorsKeys[strings.ToUpper(keyword)]+"="+arg)
+		}
+	}
+	return strings.Join(entries, ":"), scanner.Err()
+}
+
+// suffixColor возвращает цвет самого длинного окончания имени из LS_COLORS:
+// сначала с учётом регистра, затем без него, как в ls из coreutils 9
+func (c lsColors) suffixColor(name string) (string, bool) {
+	best, code := -1, ""
+	for _, fold := range []bool{false, true} {
+		for _, s := range c.suffixes {
+			match := strings.HasSuffix(name, s.suffix)
+			if fold {
+				match = len(name) >= len(s.suffix) && strings.EqualFold(name[len(name)-len(s.suffix):], s.suffix)
+			}
+			if match && len(s.suffix) > best {
+				best, code = len(s.suffix), s.code
+			}
+		}
+		if best >= 0 {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// pathColor возвращает цвет файла file с именем name так, как его покажет ls:
+// по виду файла, правам и окончанию имени. Пустой цвет особых прав (ex=,
+// su=, ow= и других) отключает их, как в ls.
+func (c lsColors) pathColor(file, name string) string {
+	fi, err := os.Lstat(file)
+	if err != nil {
+		if code, ok := c.suffixColor(name); ok {
+			return code
+		}
+		return c.keys["fi"]
+	}
+	mode := fi.Mode()
+	if mode&os.ModeSymlink != 0 {
+		// ln=target - ссылка окрашивается как файл, на который указывает
+		target, err := os.Stat(file)
+		switch {
+		case err != nil && c.keys["or"] != "":
+			return c.keys["or"]
+		case err != nil && c.keys["ln"] == "target":
+			return ""
+		case err == nil && c.keys["ln"] == "target":
+			mode = target.Mode()
+		default:
+			return c.keys["ln"]
+		}
+	}
+
+	var key string
+	switch {
+	case mode.IsDir():
+		key = "di"
+		switch {
+		case mode&os.ModeSticky != 0 && mode&0o002 != 0 && c.keys["tw"] != "":
+			key = "tw"
+		case mode&os.ModeSticky != 0 && c.keys["st"] != "":
+			key = "st"
+		case mode&0o002 != 0 && c.keys["ow"] != "":
+			key = "ow"
+		}
+	case mode&os.ModeNamedPipe != 0:
+		key = "pi"
+	case mode&os.ModeSocket != 0:
+		key = "so"
+	case mode&os.ModeCharDevice != 0:
+		key = "cd"
+	case mode&os.ModeDevice != 0:
+		key = "bd"
+	case mode&os.ModeSetuid != 0 && c.keys["su"] != "":
+		key = "su"
+	case mode&os.ModeSetgid != 0 && c.keys["sg"] != "":
+		key = "sg"
+	case mode&0o111 != 0 && c.keys["ex"] != "":
+		key = "ex"
+	default:
+		if code, ok := c.suffixColor(name); ok {
+			return code
+		}
+		key = "fi"
+	}
+	return c.keys[key]
+}
+
+// lsColorsEnv возвращает окружение для ls в превью каталога: с LS_COLORS из
+// базы dircolors, если переменная не задана
+func lsColorsEnv() []string {
+	env := os.Environ()
+	if os.Getenv("LS_COLORS") == "" {
+		if value := lsColorsValue(); value != "" {
+			env = append(env, "LS_COLORS="+value)
+		}
+	}
+	return env
+}
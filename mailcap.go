@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// mailcapTestTimeout ограничивает выполнение команды test= из записи mailcap
+const mailcapTestTimeout = 500 * time.Millisecond
+
+// mailcapEntry - запись mailcap (RFC 1524)
+type mailcapEntry struct {
+	mimeType      string
+	command       string
+	test          string
+	needsTerminal bool
+	copiousOutput bool
+}
+
+// mailcapSpec возвращает команду из mailcap для файла, если включено
+// use_mailcap. MIME-тип определяется при необходимости и сохраняется в info.
+func mailcapSpec(filePath string, info *FileTypeInfo) (launchSpec, bool) {
+	if !defaultConfig.UseMailcap || safeMode {
+		return launchSpec{}, false
+	}
+	if info.MIMEType == "" {
+		info.MIMEType = getMimeType(filePath)
+	}
+	if info.MIMEType == "" {
+		return launchSpec{}, false
+	}
+
+	for _, path := range mailcapFiles() {
+		for _, entry := range readMailcap(path) {
+			if !entry.matches(info.MIMEType) || entry.copiousOutput {
+				continue
+			}
+			if entry.test != "" {
+				test, _ := expandMailcap(entry.test, filePath, info.MIMEType)
+				if !runMailcapTest(test) {
+					continue
+				}
+			}
+
+			// Без %s файл передаётся на стандартный ввод, как требует RFC 1524
+			command, hasFile := expandMailcap(entry.command, filePath, info.MIMEType)
+			if !hasFile {
+				command += " < " + shellQuote(filePath)
+			}
+			argv := shellArgv(command)
+			if entry.needsTerminal {
+				// В Termux новое окно терминала не открыть
+				if isTermux() {
+					continue
+				}
+				argv = inTerminal(argv)
+			}
+			return launchSpec{Command: command, Argv: argv}, true
+		}
+	}
+	return launchSpec{}, false
+}
+
+// mailcapFiles возвращает файлы mailcap: из $MAILCAPS или стандартный список
+func mailcapFiles() []string {
+	if list := os.Getenv("MAILCAPS"); list != "" {
+		return filepath.SplitList(list)
+	}
+
+	var files []string
+	if home, err := expandPath("~"); err == nil && home != "" {
+		files = append(files, filepath.Join(home, ".mailcap"))
+	}
+	return append(files, "/etc/mailcap", "/usr/etc/mailcap", "/usr/local/etc/mailcap")
+}
+
+// readMailcap читает записи файла mailcap. Отсутствующий файл - пустой результат.
+func readMailcap(path string) []mailcapEntry {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var entries []mailcapEntry
+	var line strings.Builder
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if line.Len() == 0 && (strings.HasPrefix(strings.TrimSpace(text), "#") || strings.TrimSpace(text) == "") {
+			continue
+		}
+		if strings.HasSuffix(text, "\\") {
+			line.WriteString(strings.TrimSuffix(text, "\\"))
+			continue
+		}
+		line.WriteString(text)
+
+		if entry, ok := parseMailcapLine(line.String()); ok {
+			entries = append(entries, entry)
+		}
+		line.Reset()
+	}
+	return entries
+}
+
+// parseMailcapLine разбирает строку "тип; команда; флаги"
+func parseMailcapLine(line string) (mailcapEntry, bool) {
+	fields := splitMailcapFields(line)
+	if len(fields) < 2 {
+		return mailcapEntry{}, false
+	}
+
+	entry := mailcapEntry{
+		mimeType: strings.ToLower(strings.TrimSpace(fields[0])),
+		command:  strings.TrimSpace(fields[1]),
+	}
+	if !strings.Contains(entry.mimeType, "/") {
+		entry.mimeType += "/*"
+	}
+
+	for _, field := range fields[2:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "needsterminal":
+			entry.needsTerminal = true
+		case "copiousoutput":
+			entry.copiousOutput = true
+		case "test":
+			entry.test = strings.TrimSpace(value)
+		}
+	}
+	return entry, entry.command != ""
+}
+
+// splitMailcapFields делит строку по ";", не экранированным обратной косой чертой
+func splitMailcapFields(line string) []string {
+	var fields []string
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			i++
+			if line[i] != ';' {
+				cur.WriteByte('\\')
+			}
+			cur.WriteByte(line[i])
+		case line[i] == ';':
+			fields = append(fields, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(line[i])
+		}
+	}
+	return append(fields, cur.String())
+}
+
+// matches сообщает, подходит ли запись к MIME-типу (с учётом "тип/*")
+func (e mailcapEntry) matches(mimeType string) bool {
+	mimeType = strings.ToLower(mimeType)
+	if e.mimeType == mimeType {
+		return true
+	}
+	major, _, _ := strings.Cut(mimeType, "/")
+	return e.mimeType == major+"/*"
+}
+
+// expandMailcap подставляет в команду путь (%s) и тип (%t). Второе значение
+// сообщает, встретился ли %s. Если запись сама берёт %s в кавычки ('%s' или
+// "%s"), значение экранируется внутри них, а не обрамляется ещё раз.
+func expandMailcap(command, filePath, mimeType string) (string, bool) {
+	var sb strings.Builder
+	hasFile := false
+	var quote byte // открытая кавычка шелла в command: 0, ' или "
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\\' && quote != '\'' && i+1 < len(command):
+			sb.WriteByte(c)
+			i++
+			sb.WriteByte(command[i])
+			continue
+		case (c == '\'' || c == '"') && (quote == 0 || quote == c):
+			if quote == 0 {
+				quote = c
+			} else {
+				quote = 0
+			}
+		}
+		if c != '%' || i+1 == len(command) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch command[i] {
+		case 's':
+			sb.WriteString(quoteMailcapValue(filePath, quote))
+			hasFile = true
+		case 't':
+			sb.WriteString(quoteMailcapValue(mimeType, quote))
+		case '%':
+			sb.WriteByte('%')
+		case '{':
+			if end := strings.IndexByte(command[i:], '}'); end >= 0 {
+				i += end
+			}
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(command[i])
+		}
+	}
+	return sb.String(), hasFile
+}
+
+// mailcapDoubleQuoted - символы, которые шелл раскрывает внутри двойных кавычек
+var mailcapDoubleQuoted = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+
+// quoteMailcapValue экранирует значение для места в команде, где открыта
+// кавычка quote (0 - вне кавычек)
+func quoteMailcapValue(value string, quote byte) string {
+	switch quote {
+	case '\'':
+		return strings.ReplaceAll(value, "'", `'\''`)
+	case '"':
+		return mailcapDoubleQuoted.Replace(value)
+	}
+	return shellQuote(value)
+}
+
+// runMailcapTest выполняет команду test= и сообщает, завершилась ли она успешно
+func runMailcapTest(command string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), mailcapTestTimeout)
+	defer cancel()
+	argv := shellArgv(command)
+	return execCommand(ctx, "mailcap", argv[0], argv[1:]...).Run() == nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestExpandMailcap(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+		hasFile bool
+	}{
+		{`less %s`, `less '/tmp/a b.txt'`, true},
+		{`less '%s'`, `less '/tmp/a b.txt'`, true},
+		{`less "%s"`, `less "/tmp/a b.txt"`, true},
+		{`view -t %t %s`, `view -t 'text/plain' '/tmp/a b.txt'`, true},
+		{`sh -c 'cat "%s"'`, `sh -c 'cat "/tmp/a b.txt"'`, true},
+		{`echo "it's" %s`, `echo "it's" '/tmp/a b.txt'`, true},
+		{`echo \" %s`, `echo \" '/tmp/a b.txt'`, true},
+		{`printf '%%d' 5`, `printf '%d' 5`, false},
+		{`viewer %{charset}`, `viewer `, false},
+	}
+	for _, tt := range tests {
+		got, hasFile := expandMailcap(tt.command, "/tmp/a b.txt", "text/plain")
+		if got != tt.want || hasFile != tt.hasFile {
+			t.Errorf("expandMailcap(%q) = %q, %v; want %q, %v", tt.command, got, hasFile, tt.want, tt.hasFile)
+		}
+	}
+}
+
+func TestExpandMailcapShellRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh in PATH")
+	}
+	names := []string{
+		"/tmp/plain.txt",
+		"/tmp/it's here.txt",
+		`/tmp/"quoted" $HOME ` + "`id`" + ` \n.txt`,
+	}
+	for _, command := range []string{`printf '%%s' %s`, `printf '%%s' '%s'`, `printf '%%s' "%s"`} {
+		for _, name := range names {
+			expanded, _ := expandMailcap(command, name, "text/plain")
+			out, err := exec.Command(sh, "-c", expanded).Output()
+			if err != nil || string(out) != name {
+				t.Errorf("%s: sh printed %q, %v; want %q", expanded, out, err, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mediaRoots - каталоги, в которые udisks и автомонтировщики монтируют
+// съёмные носители (/run/media/<пользователь>/<метка> в Arch и Fedora,
+// /media/<пользователь>/<метка> в Debian и Ubuntu, /media/<метка> в BSD)
+var mediaRoots = []string{"/run/media", "/media"}
+
+// mountsFile - таблица смонтированных файловых систем в Linux
+const mountsFile = "/proc/self/mounts"
+
+// mediaMount - смонтированный съёмный носитель
+type mediaMount struct {
+	device string // устройство; пусто, если неизвестно
+	path   string
+}
+
+// ejectMount - носитель, выбранный флагом --media; перед выходом его
+// предлагается извлечь
+var ejectMount *mediaMount
+
+// Пункты меню извлечения носителя
+const (
+	menuEject     = "Eject"
+	menuEjectWait = "Eject after the application exits"
+)
+
+// listMediaMounts возвращает смонтированные съёмные носители. В Linux они
+// берутся из /proc/self/mounts, в других системах - подкаталогами mediaRoots.
+func listMediaMounts() []mediaMount {
+	var mounts []mediaMount
+	if f, err := os.Open(mountsFile); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			path := unescapeMountField(fields[1])
+			if underMediaRoot(path) {
+				mounts = append(mounts, mediaMount{device: unescapeMountField(fields[0]), path: path})
+			}
+		}
+		return mounts
+	}
+
+	for _, root := range mediaRoots {
+		entries, _ := filepath.Glob(filepath.Join(root, "*"))
+		userEntries, _ := filepath.Glob(filepath.Join(root, "*", "*"))
+		for _, path := range append(entries, userEntries...) {
+			if fi, err := os.Stat(path); err == nil && fi.IsDir() && !isUserMediaDir(path) {
+				mounts = append(mounts, mediaMount{path: path})
+			}
+		}
+	}
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].path < mounts[j].path })
+	return mounts
+}
+
+// underMediaRoot сообщает, лежит ли точка монтирования внутри одного из mediaRoots
+func underMediaRoot(path string) bool {
+	for _, root := range mediaRoots {
+		if strings.HasPrefix(path, root+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isUserMediaDir сообщает, является ли каталог каталогом пользователя в
+// mediaRoots (/media/<пользователь>), внутри которого лежат носители
+func isUserMediaDir(path string) bool {
+	name := filepath.Base(path)
+	if name != os.Getenv("USER") || name == "" {
+		return false
+	}
+	for _, root := range mediaRoots {
+		if filepath.Dir(path) == root {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeMountField раскрывает восьмеричные escape-последовательности
+// (\040 - пробел) в полях /proc/self/mounts
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// pickMedia показывает смонтированные носители и делает выбранный начальным
+// каталогом. Единственный носитель выбирается без меню. Возвращает false,
+// если выбирать не из чего или выбор отменён.
+func pickMedia(cfg *Config) bool {
+	var mounts []mediaMount
+	for _, m := range listMediaMounts() {
+		if kioskAllowed(m.path) {
+			mounts = append(mounts, m)
+		}
+	}
+	if len(mounts) == 0 {
+		fmt.Fprintln(logOut, "Error: no removable media is mounted under /run/media or /media")
+		return false
+	}
+
+	chosen := mounts[0]
+	if len(mounts) > 1 {
+		items := make([]string, len(mounts))
+		for i, m := range mounts {
+			items[i] = m.path
+		}
+		choice, err := fzfMenu("Removable media> ", false, items...)
+		if err != nil || choice == "" {
+			return false
+		}
+		for _, m := range mounts {
+			if m.path == choice {
+				chosen = m
+			}
+		}
+	}
+
+	cfg.StartingDir = chosen.path
+	// Перечитанная в --loop конфигурация не должна заменить носитель своим starting_dir
+	cfg.explicitFlags["d"] = true
+	ejectMount = &chosen
+	return true
+}
+
+// offerEject предлагает извлечь носитель, выбранный флагом --media. Как и при
+// отмонтировании GVFS, извлечение ждёт завершения запущенных приложений, если
+// их не дождался флаг -w.
+func offerEject(waited bool) {
+	if ejectMount == nil || !isInteractive() {
+		return
+	}
+
+	eject := menuEjectWait
+	if waited {
+		eject = menuEject
+	}
+	choice, err := fzfMenu(fmt.Sprintf("Eject %s> ", ejectMount.path), false, eject, menuKeepMounted)
+	if err != nil || choice != eject {
+		return
+	}
+	if !waited {
+		waitLaunched()
+	}
+
+	for _, argv := range ejectCommands(*ejectMount) {
+		path, err := cachedLookPath(argv[0])
+		if err != nil {
+			continue
+		}
+		out, err := execCommand(context.Background(), "eject", path, argv[1:]...).CombinedOutput()
+		if err != nil {
+			fmt.Fprintf(logOut, "Error: could not eject %s: %v %s\n", ejectMount.path, err, strings.TrimSpace(string(out)))
+			return
+		}
+	}
+	ejectMount = nil
+}
+
+// ejectCommands возвращает команды извлечения носителя: udisksctl отмонтирует
+// устройство и выключает его, без udisks носитель отмонтирует gio или umount
+func ejectCommands(m mediaMount) [][]string {
+	if _, err := cachedLookPath("udisksctl"); err == nil && strings.HasPrefix(m.device, "/dev/") {
+		return [][]string{
+			{"udisksctl", "unmount", "-b", m.device},
+			{"udisksctl", "power-off", "-b", m.device},
+		}
+	}
+	if _, err := cachedLookPath("gio"); err == nil {
+		return [][]string{{"gio", "mount", "-e", m.path}}
+	}
+	return [][]string{{"umount", m.path}}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Пункты меню, которое показывается, если файл не удалось открыть
+const (
+	menuOpenWith   = "Open with…"
+	menuEditAsText = "Edit as text"
+	menuReveal     = "Reveal in file manager"
+	menuCopyPath   = "Copy path"
+	menuCancel     = "Cancel"
+)
+
+// isInteractive сообщает, подключён ли stdin к терминалу, в котором можно показать меню
+func isInteractive() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// runFailureMenu предлагает действия для файла, который не удалось открыть.
+// Возвращает true, если выбранное действие выполнено.
+func runFailureMenu(ctx context.Context, filePath string) bool {
+	if !isInteractive() {
+		return false
+	}
+
+	for {
+		items := []string{menuOpenWith, menuEditAsText, menuReveal, menuCopyPath}
+		if kioskMode {
+			items = []string{menuEditAsText, menuCopyPath}
+		}
+		for _, name := range actionNames() {
+			items = append(items, actionMenuPrefix+name)
+		}
+		items = append(items, menuCancel)
+
+		choice, err := fzfMenu(fmt.Sprintf("Could not open %s> ", filepath.Base(filePath)), false, items...)
+		if err != nil || choice == "" || choice == menuCancel {
+			return false
+		}
+
+		var actionErr error
+		switch choice {
+		case menuOpenWith:
+			command, err := fzfMenu("Open with> ", true, associationCommands()...)
+			if err != nil || command == "" {
+				continue
+			}
+			actionErr = launchFirst(ctx, filePath, launchSpec{Command: command})
+		case menuEditAsText:
+			actionErr = launchFirst(ctx, filePath, associationSpecs(assocTextEditor)...)
+		case menuReveal:
+			actionErr = launchFirst(ctx, filepath.Dir(filePath), associationSpecs(assocDirectoryOpener, assocFallbackOpener)...)
+		case menuCopyPath:
+			actionErr = copyToClipboard(filePath)
+		default:
+			actionErr = runAction(ctx, strings.TrimPrefix(choice, actionMenuPrefix), []string{filePath})
+		}
+
+		if actionErr == nil {
+			return true
+		}
+		fmt.Fprintf(logOut, "Error: %s failed: %v\n", choice, actionErr)
+	}
+}
+
+// fzfMenu показывает пункты в fzf и возвращает выбранный. Если allowQuery,
+// введённый текст без совпадений возвращается как выбор (например, своя команда).
+func fzfMenu(prompt string, allowQuery bool, items ...string) (string, error) {
+	argv := currentFinder().menuArgv(prompt, allowQuery)
+	fzfPath, err := cachedLookPath(argv[0])
+	if err != nil {
+		return "", err
+	}
+
+	cmd := execCommand(context.Background(), "menu", fzfPath, argv[1:]...)
+	cmd.Stdin = strings.NewReader(strings.Join(items, "\n"))
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	// Код 1 - нет совпадений: при allowQuery используется введённый запрос
+	if err != nil && !(allowQuery && errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if !allowQuery {
+		return strings.TrimSpace(lines[0]), nil
+	}
+
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		return strings.TrimSpace(lines[1]), nil
+	}
+	return strings.TrimSpace(lines[0]), nil
+}
+
+// associationCommands возвращает уникальные команды из всех ассоциаций
+func associationCommands() []string {
+	seen := make(map[string]struct{})
+	var commands []string
+
+	rv := reflect.ValueOf(appAssociations)
+	for i := 0; i < rv.NumField(); i++ {
+		for _, command := range rv.Field(i).Interface().(CommandList) {
+			if _, ok := seen[command]; ok {
+				continue
+			}
+			seen[command] = struct{}{}
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+)
+
+// multiAssociations - ассоциации из таблицы [multi], приложения которых
+// принимают несколько файлов в одной командной строке. В режиме -m такие
+// файлы открываются одним запуском, а не отдельным окном на каждый.
+var multiAssociations = defaultMultiAssociations()
+
+// defaultMultiAssociations возвращает встроенную таблицу [multi]: редакторы,
+// просмотрщики изображений и плееры обычно принимают список файлов
+func defaultMultiAssociations() map[string]bool {
+	return map[string]bool{
+		assocTextEditor:  true,
+		assocImageViewer: true,
+		assocVideoPlayer: true,
+	}
+}
+
+// launchGroup - файлы, которые откроет один запуск приложения
+type launchGroup struct {
+	spec    launchSpec
+	appPath string
+	appArgs []string
+	tui     bool
+	files   []string
+}
+
+// launchBatch собирает файлы, выбранные в режиме -m, по приложениям
+type launchBatch struct {
+	groups []*launchGroup
+	index  map[string]*launchGroup
+}
+
+// openBatch - сборщик текущего открытия в режиме -m; nil - файлы открываются сразу
+var openBatch *launchBatch
+
+func newLaunchBatch() *launchBatch {
+	return &launchBatch{index: make(map[string]*launchGroup)}
+}
+
+// add откладывает открытие файла до flush, добавляя его к запуску того же
+// приложения с теми же аргументами
+func (b *launchBatch) add(spec launchSpec, appPath string, appArgs []string, filePath string, tui bool) {
+	key := spec.Key + "\x00" + appPath + "\x00" + strings.Join(appArgs, "\x00")
+	g, ok := b.index[key]
+	if !ok {
+		g = &launchGroup{spec: spec, appPath: appPath, appArgs: appArgs, tui: tui}
+		b.index[key] = g
+		b.groups = append(b.groups, g)
+	}
+	g.files = append(g.files, filePath)
+}
+
+// flush запускает собранные приложения в порядке выбора файлов и возвращает
+// число запусков, которые не удались
+func (b *launchBatch) flush() int {
+	failed := 0
+	for _, g := range b.groups {
+		args := append(append([]string{}, g.appArgs...), g.files...)
+		if !runApp(g.spec, g.appPath, args, strings.Join(g.files, " "), g.tui) {
+			failed++
+		}
+	}
+	b.groups, b.index = nil, make(map[string]*launchGroup)
+	return failed
+}
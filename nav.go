@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// navStateSuffix - суффикс файла состояния навигации (см. pickerStatePath).
+// Строки файла: каталог, в котором запущен fzf, текущий корень списка,
+// команда списка, команда списка с переключённым .gitignore (может быть
+// пустой) и затем корни из roots для "@имя" в запросе.
+const navStateSuffix = ".nav"
+
+// navState - состояние навигации по каталогам внутри fzf
+type navState struct {
+	start   string
+	root    string
+	source  string
+	toggled string
+	roots   []string
+}
+
+// navigationEnabled сообщает, можно ли менять корень списка клавишами
+// enter_dir_key и parent_dir_key или запросом (query_roots). В режиме сервера
+// список перезагружают другие запуски, а в режиме киоска корень не должен
+// выходить за kiosk_roots.
+func navigationEnabled() bool {
+	if defaultConfig.EnterDirKey == "" && defaultConfig.ParentDirKey == "" && !defaultConfig.QueryRoots {
+		return false
+	}
+	if safeMode || recentMode || grepMode || serverMode || kioskMode || !hasPosixShell() || !usingFzf() {
+		return false
+	}
+	source, toggled := navSources()
+	return source != "" && !strings.Contains(source+toggled, "\n")
+}
+
+// navSources возвращает команды списка для корня навигации: обычную и с
+// переключённым .gitignore, если переключатель доступен
+func navSources() (source, toggled string) {
+	source = sourceCommand()
+	if source == "" {
+		source = walkSourceCommand(defaultConfig.NoIgnore)
+	}
+	_, toggled = ignoreToggleSources()
+	return source, toggled
+}
+
+// addNavKeys добавляет в параметры fzf клавиши перехода в каталог под
+// курсором и в родительский каталог
+func addNavKeys(opts *pickerOptions) {
+	if !navigationEnabled() {
+		return
+	}
+	basePath, err := pickerStatePath()
+	if err != nil {
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	statePath := basePath + navStateSuffix
+
+	var hints []string
+	if key := defaultConfig.EnterDirKey; key != "" {
+		opts.Binds = append(opts.Binds, key+":transform:"+quoteCommand([]string{exe, "nav", statePath, "enter"})+" {}")
+		hints = append(hints, key+": enter dir")
+	}
+	if key := defaultConfig.ParentDirKey; key != "" {
+		opts.Binds = append(opts.Binds, key+":transform:"+quoteCommand([]string{exe, "nav", statePath, "up"}))
+		hints = append(hints, key+": parent dir")
+	}
+	if defaultConfig.QueryRoots {
+		opts.Binds = append(opts.Binds, "change:transform:"+quoteCommand([]string{exe, "nav", statePath, "query"})+" {q}")
+	}
+	if len(hints) == 0 {
+		return
+	}
+	if opts.Header != "" {
+		hints = append([]string{opts.Header}, hints...)
+	}
+	opts.Header = strings.Join(hints, " │ ")
+}
+
+// writeNavState сохраняет начальное состояние навигации для fzf, запущенного в dir
+func writeNavState(path, dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	source, toggled := navSources()
+	state := navState{start: dir, root: dir, source: source, toggled: toggled}
+	for _, root := range defaultConfig.Roots {
+		if expanded, err := expandPath(root); err == nil && filepath.IsAbs(expanded) {
+			state.roots = append(state.roots, filepath.Clean(expanded))
+		}
+	}
+	return writeNavStateFile(path, state)
+}
+
+// navListCommand возвращает команду, которая перечисляет файлы текущего корня навигации
+func navListCommand(statePath string) string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	args := []string{exe, "nav", statePath, "list"}
+	if nulSeparated() {
+		args = append(args, "-0")
+	}
+	// Корень добавляется к путям до раскраски и значков
+	return decoratedCommand(quoteCommand(args))
+}
+
+// runNav реализует подкоманду nav, которую вызывают клавиши навигации fzf:
+// enter и up меняют корень и печатают действия fzf для transform, query
+// делает то же по началу запроса, list печатает файлы корня (вне начального
+// каталога - абсолютными путями)
+func runNav(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: fzf-open nav STATE enter ITEM|up|query QUERY|list")
+		return 2
+	}
+	statePath, action := args[0], args[1]
+	state, err := readNavState(statePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	switch action {
+	case "enter":
+		if len(args) < 3 || args[2] == "" {
+			return 0
+		}
+		// Строка списка со значком из icons и столбцами --details
+		item := stripDecoration(args[2])
+		if !filepath.IsAbs(item) {
+			item = filepath.Join(state.root, item)
+		}
+		if fi, err := os.Stat(item); err != nil || !fi.IsDir() {
+			item = filepath.Dir(item)
+		}
+		return changeNavRoot(statePath, state, item)
+	case "up":
+		return changeNavRoot(statePath, state, filepath.Dir(state.root))
+	case "query":
+		if len(args) < 3 {
+			return 0
+		}
+		root, rest, ok := queryRoot(state, args[2])
+		if !ok {
+			return 0
+		}
+		query := fzfActionArg(rest)
+		if query == "" {
+			return 0
+		}
+		if root == state.root {
+			fmt.Println("change-query" + query)
+			return 0
+		}
+		return changeNavRoot(statePath, state, root, "change-query"+query)
+	case "list":
+		return listNavRoot(statePath, state, len(args) > 2 && args[2] == "-0")
+	}
+	fmt.Fprintf(os.Stderr, "Error: unknown nav action %q\n", action)
+	return 2
+}
+
+// changeNavRoot делает root корнем списка и печатает действия fzf, которые
+// меняют приглашение, выполняют actions и перезагружают список
+func changeNavRoot(statePath string, state navState, root string, actions ...string) int {
+	root = filepath.Clean(root)
+	if root == state.root {
+		return 0
+	}
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		return 0
+	}
+	state.root = root
+	if err := writeNavStateFile(statePath, state); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if prompt := fzfActionArg(navPrompt(root)); prompt != "" {
+		actions = append([]string{"change-prompt" + prompt}, actions...)
+	}
+	// Форма с двоеточием забирает остаток строки, поэтому reload - последним
+	actions = append(actions, "reload:"+navListCommand(statePath))
+	fmt.Println(strings.Join(actions, "+"))
+	return 0
+}
+
+// listNavRoot выполняет команду списка в текущем корне. Пути вне начального
+// каталога fzf получают префикс корня, чтобы превью и выбор их нашли. С null
+// строки списка разделяет NUL.
+func listNavRoot(statePath string, state navState, null bool) int {
+	source := state.source
+	ignoreState := strings.TrimSuffix(statePath, navStateSuffix) + ignoreStateSuffix
+	if _, err := os.Stat(ignoreState); err == nil && state.toggled != "" {
+		source = state.toggled
+	}
+
+	argv := shellArgv(source)
+	cmd := execCommand(context.Background(), "nav", argv[0], argv[1:]...)
+	cmd.Dir = state.root
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	sep := byte('\n')
+	if null {
+		sep = 0
+	}
+	out := bufio.NewWriter(os.Stdout)
+	in := bufio.NewReader(stdout)
+	for {
+		line, err := in.ReadString(sep)
+		if line = strings.TrimSuffix(line, string(sep)); line != "" {
+			if state.root != state.start && !filepath.IsAbs(line) {
+				line = filepath.Join(state.root, strings.TrimPrefix(line, "./"))
+			}
+			out.WriteString(line)
+			out.WriteByte(sep)
+		}
+		if err != nil {
+			break
+		}
+	}
+	out.Flush()
+	if err := cmd.Wait(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// queryRoot разбирает начало запроса: каталог ("~/Downloads/", "/etc/",
+// "../") или "@имя" - корень из roots, имя которого начинается с имени ("@"
+// без имени - начальный каталог). Слово должно заканчиваться пробелом, чтобы
+// корень не менялся на каждой набранной букве. Возвращает каталог и остаток
+// запроса.
+func queryRoot(state navState, query string) (root, rest string, ok bool) {
+	word, rest, found := strings.Cut(query, " ")
+	if !found || word == "" {
+		return "", "", false
+	}
+
+	if name, isAlias := strings.CutPrefix(word, "@"); isAlias {
+		if name == "" {
+			return state.start, rest, true
+		}
+		for _, r := range state.roots {
+			if strings.HasPrefix(strings.ToLower(filepath.Base(r)), strings.ToLower(name)) {
+				return r, rest, true
+			}
+		}
+		return "", "", false
+	}
+
+	if !strings.HasSuffix(word, "/") {
+		return "", "", false
+	}
+	switch {
+	case strings.HasPrefix(word, "~"):
+		expanded, err := expandPath(word)
+		if err != nil {
+			return "", "", false
+		}
+		word = expanded
+	case strings.HasPrefix(word, "./") || strings.HasPrefix(word, "../"):
+		word = filepath.Join(state.root, word)
+	case !filepath.IsAbs(word):
+		return "", "", false
+	}
+	if fi, err := os.Stat(word); err != nil || !fi.IsDir() {
+		return "", "", false
+	}
+	return filepath.Clean(word), rest, true
+}
+
+// navPrompt возвращает приглашение fzf с корнем списка
+func navPrompt(root string) string {
+	if userHomeDir != "" && userHomeDir != "/" {
+		if root == userHomeDir {
+			root = "~"
+		} else if rest, ok := strings.CutPrefix(root, userHomeDir+"/"); ok {
+			root = "~/" + rest
+		}
+	}
+	return root + "> "
+}
+
+// fzfActionArg заключает аргумент действия fzf в первую пару скобок, которой
+// в нём нет. Пусто - подходящей пары не нашлось.
+func fzfActionArg(arg string) string {
+	for _, pair := range []string{"()", "[]", "{}", "<>", "~~", "!!", "@@", "##", "%%", "^^"} {
+		if !strings.ContainsRune(arg, rune(pair[1])) {
+			return pair[:1] + arg + pair[1:]
+		}
+	}
+	return ""
+}
+
+// readNavState читает файл состояния навигации
+func readNavState(path string) (navState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return navState{}, err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) < 4 || !filepath.IsAbs(lines[0]) || !filepath.IsAbs(lines[1]) {
+		return navState{}, fmt.Errorf("malformed navigation state %q", path)
+	}
+	return navState{start: lines[0], root: lines[1], source: lines[2], toggled: lines[3], roots: lines[4:]}, nil
+}
+
+// writeNavStateFile атомарно сохраняет состояние навигации
+func writeNavStateFile(path string, state navState) error {
+	lines := append([]string{state.start, state.root, state.source, state.toggled}, state.roots...)
+	data := strings.Join(lines, "\n") + "\n"
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
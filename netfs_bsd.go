@@ -0,0 +1,45 @@
+//go:build darwin || dragonfly || freebsd || openbsd
+
+package main
+
+import (
+	"strings"
+	"syscall"
+)
+
+// Имена сетевых файловых систем из statfs(2)
+var networkFSNames = map[string]bool{
+	"nfs":     true,
+	"smbfs":   true,
+	"cifs":    true,
+	"afpfs":   true, // macOS
+	"webdav":  true, // macOS
+	"afs":     true,
+	"fusefs":  true, // FreeBSD, в том числе sshfs
+	"fuse":    true, // OpenBSD
+	"macfuse": true,
+	"osxfuse": true,
+}
+
+// isNetworkFS сообщает, находится ли path в сетевой файловой системе
+func isNetworkFS(path string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false
+	}
+	name := statfsTypeName(&st)
+	// FreeBSD добавляет к fusefs подтип: fusefs.sshfs
+	return networkFSNames[name] || strings.HasPrefix(name, "fusefs.")
+}
+
+// cString переводит строку C из массива char в строку Go
+func cString(chars []int8) string {
+	b := make([]byte, 0, len(chars))
+	for _, c := range chars {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package main
+
+import "syscall"
+
+// Сигнатуры сетевых файловых систем из statfs(2)
+var networkFSMagic = map[int64]bool{
+	0x6969:     true, // NFS
+	0x517b:     true, // SMB
+	0xff534d42: true, // CIFS
+	0xfe534d42: true, // SMB2
+	0x564c:     true, // NCP
+	0x65735546: true, // FUSE (sshfs и др.)
+	0x013111a8: true, // IBRIX
+	0x5346414f: true, // AFS
+	0x6b414653: true, // kAFS
+	0x19830326: true, // FhGFS/BeeGFS
+	0x47504653: true, // GPFS
+	0x0bd00bd0: true, // Lustre
+	0x00c36400: true, // CephFS
+}
+
+// isNetworkFS сообщает, находится ли path в сетевой файловой системе
+func isNetworkFS(path string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false
+	}
+	return networkFSMagic[int64(st.Type)]
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !openbsd
+
+package main
+
+// isNetworkFS - на остальных платформах сетевая файловая система не
+// определяется; каталоги по хостам включаются через FZF_OPEN_PER_HOST
+func isNetworkFS(path string) bool {
+	return false
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// actionOCR - встроенное действие распознавания текста в сканах
+const actionOCR = "ocr"
+
+// Конвертеры для OCR: результат - PDF с текстовым слоем. ocrmypdf пропускает
+// страницы, на которых текст уже есть; tesseract принимает имя результата без
+// расширения.
+var (
+	ocrPDFConverter = ConverterConfig{
+		To:      "pdf",
+		Command: "ocrmypdf --skip-text {file} {out}",
+	}
+	ocrImageConverter = ConverterConfig{
+		To:      "pdf",
+		Command: `o={out}; tesseract {file} "${o%.pdf}" pdf`,
+	}
+)
+
+// ocrImageExts - форматы изображений, которые понимает tesseract
+var ocrImageExts = map[string]struct{}{
+	"png": {}, "jpg": {}, "jpeg": {}, "tif": {}, "tiff": {}, "bmp": {}, "webp": {}, "pnm": {},
+}
+
+// ocrConverter возвращает конвертер OCR для файла, если нужная программа есть в PATH
+func ocrConverter(filePath string) (ConverterConfig, bool) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filePath), "."))
+
+	conv, tool := ocrImageConverter, "tesseract"
+	if ext == "pdf" {
+		conv, tool = ocrPDFConverter, "ocrmypdf"
+	} else if _, ok := ocrImageExts[ext]; !ok {
+		return ConverterConfig{}, false
+	}
+
+	if _, err := cachedLookPath(tool); err != nil {
+		return ConverterConfig{}, false
+	}
+	return conv, true
+}
+
+// ocrAvailable сообщает, установлена ли хотя бы одна программа OCR
+func ocrAvailable() bool {
+	for _, tool := range []string{"ocrmypdf", "tesseract"} {
+		if _, err := cachedLookPath(tool); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// runOCR распознаёт текст в изображениях и PDF и открывает PDF с текстовым
+// слоем. Программы OCR пишут прогресс в текущий терминал; результат кэшируется,
+// как у конвертеров.
+func runOCR(ctx context.Context, files []string) error {
+	for i, file := range files {
+		conv, ok := ocrConverter(file)
+		if !ok {
+			return fmt.Errorf("no OCR tool for %q (install ocrmypdf for PDF or tesseract for images)", filepath.Base(file))
+		}
+
+		fmt.Fprintf(logOut, "[%s %d/%d] %s\n", actionOCR, i+1, len(files), filepath.Base(file))
+		out, err := convertFile(file, conv)
+		if err != nil {
+			return err
+		}
+		if err := openPath(ctx, out, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
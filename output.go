@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// outputShell - значение --output: вместо запуска приложения напечатать
+// команду, которую выполнит вызывающий шелл (eval "$(fzf-open --output shell)")
+const outputShell = "shell"
+
+// outputPaths - значение --output: напечатать выбранные пути по одному в
+// строке, например для cd "$(fzf-open -D --output path)"
+const outputPaths = "path"
+
+// shellSnippet строит команду POSIX-шелла для выбранных путей: $VISUAL или
+// $EDITOR для всех файлов, а если файлов нет - cd в первый каталог. Все пути
+// заключены в кавычки, так что результат безопасно передавать в eval.
+func shellSnippet(paths []string) string {
+	var args []string
+	dir := ""
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		line := 0
+		if err != nil {
+			file, n, ok := splitLineSuffix(path)
+			if !ok {
+				continue
+			}
+			path, line = file, n
+			if fi, err = os.Stat(path); err != nil {
+				continue
+			}
+		}
+
+		if fi.IsDir() {
+			// В каталог можно перейти только один раз
+			if dir == "" {
+				dir = path
+			}
+			continue
+		}
+		if line > 0 {
+			args = append(args, "+"+strconv.Itoa(line))
+		}
+		args = append(args, shellQuote(path))
+	}
+
+	if len(args) == 0 {
+		if dir != "" {
+			return "cd " + shellQuote(dir)
+		}
+		return ""
+	}
+	// Без кавычек: EDITOR="code -w" разбивается на слова, как и ожидается
+	return "${VISUAL:-${EDITOR:-vi}} " + strings.Join(args, " ")
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShellSnippet(t *testing.T) {
+	root := t.TempDir()
+	a, b := filepath.Join(root, "a.txt"), filepath.Join(root, "b c.txt")
+	sub, other := filepath.Join(root, "sub"), filepath.Join(root, "other")
+	for _, file := range []string{a, b} {
+		if err := os.WriteFile(file, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dir := range []string{sub, other} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	editor := "${VISUAL:-${EDITOR:-vi}} "
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{"nothing", nil, ""},
+		{"missing", []string{filepath.Join(root, "missing")}, ""},
+		{"directory", []string{sub, other}, "cd " + shellQuote(sub)},
+		{"files", []string{a, b}, editor + shellQuote(a) + " " + shellQuote(b)},
+		{"directory first", []string{sub, a, b}, editor + shellQuote(a) + " " + shellQuote(b)},
+		{"directory between files", []string{a, sub, b}, editor + shellQuote(a) + " " + shellQuote(b)},
+		{"line", []string{b + ":12", a}, editor + "+12 " + shellQuote(b) + " " + shellQuote(a)},
+	}
+	for _, tt := range tests {
+		if got := shellSnippet(tt.paths); got != tt.want {
+			t.Errorf("%s: shellSnippet() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
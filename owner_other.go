@@ -0,0 +1,25 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// checkProjectConfigOwner - на платформах без владельцев в стиле Unix проверка не выполняется
+func checkProjectConfigOwner(path string) error {
+	return nil
+}
+
+// isOwnedByCurrentUser - без владельцев в стиле Unix любой файл считается своим
+func isOwnedByCurrentUser(fi os.FileInfo) bool {
+	return true
+}
+
+// isOwnedByRoot - без владельцев в стиле Unix файлов root нет
+func isOwnedByRoot(fi os.FileInfo) bool {
+	return false
+}
+
+// canWrite - права на запись здесь не проверяются: ошибку сообщит приложение
+func canWrite(path string) bool {
+	return true
+}
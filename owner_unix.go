@@ -0,0 +1,47 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// checkProjectConfigOwner отклоняет файлы проекта, которые принадлежат другому
+// пользователю или доступны на запись группе и остальным: такие файлы меняют
+// выбор приложений и не должны подменяться чужими руками. От файла в только
+// что склонированном репозитории эта проверка не защищает - для этого
+// команды из файла проекта применяются только из trusted_projects.
+func checkProjectConfigOwner(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode().Perm()&0o022 != 0 {
+		return errors.New("file is writable by group or others")
+	}
+	if !isOwnedByCurrentUser(fi) {
+		return errors.New("file is owned by another user")
+	}
+	return nil
+}
+
+// isOwnedByCurrentUser сообщает, принадлежит ли файл текущему пользователю
+func isOwnedByCurrentUser(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return !ok || int(st.Uid) == os.Getuid()
+}
+
+// isOwnedByRoot сообщает, принадлежит ли файл root
+func isOwnedByRoot(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && st.Uid == 0
+}
+
+// canWrite сообщает, может ли текущий пользователь записать файл
+func canWrite(path string) bool {
+	const wOK = 2 // W_OK из unistd.h
+	return syscall.Access(path, wOK) == nil
+}
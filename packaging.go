@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// PackagingConfig - вспомогательные команды для файлов сборки пакетов одного
+// вида из таблицы [packaging.<вид>]. В командах работают те же заполнители,
+// что и в [actions]; команда выполняется в каталоге файла.
+type PackagingConfig struct {
+	// Lint проверяет пакет (namcap, rpmlint, lintian)
+	Lint string `toml:"lint,omitempty"`
+	// Diff показывает изменения файла
+	Diff string `toml:"diff,omitempty"`
+}
+
+// Виды файлов сборки пакетов - ключи таблицы [packaging]
+const (
+	packagePKGBUILD = "pkgbuild"
+	packageRPMSpec  = "rpm"
+	packageDebian   = "deb"
+)
+
+// packagingHelpers - команды для файлов сборки пакетов по видам
+var packagingHelpers = defaultPackagingHelpers()
+
+// defaultPackagingHelpers возвращает встроенные команды для файлов сборки пакетов
+func defaultPackagingHelpers() map[string]PackagingConfig {
+	return map[string]PackagingConfig{
+		packagePKGBUILD: {Lint: "namcap {file}", Diff: "git diff -- {file}"},
+		packageRPMSpec:  {Lint: "rpmlint {file}", Diff: "git diff -- {file}"},
+		// Без аргументов lintian проверяет последний собранный .changes в
+		// каталоге над исходниками
+		packageDebian: {Lint: "cd .. && lintian", Diff: "git diff -- {file}"},
+	}
+}
+
+// Пункты меню файла сборки пакета
+const (
+	menuPackageEdit = "Open in editor"
+	menuPackageLint = "Lint"
+	menuPackageDiff = "Show diff"
+)
+
+// packageKind определяет вид файла сборки пакета; пустая строка - не он
+func packageKind(filePath string, ext string) string {
+	name := filepath.Base(filePath)
+	switch {
+	case name == "PKGBUILD":
+		return packagePKGBUILD
+	case ext == "spec":
+		return packageRPMSpec
+	case ext == "dsc":
+		return packageDebian
+	case filepath.Base(filepath.Dir(filePath)) == "debian" &&
+		(name == "control" || name == "rules" || name == "changelog" || name == "copyright"):
+		return packageDebian
+	}
+	return ""
+}
+
+// offerPackaging предлагает для файла сборки пакета открыть его в редакторе,
+// проверить пакет или показать изменения. Команды, которых нет в PATH, в меню
+// не попадают. Возвращает true, если файл обработан.
+func offerPackaging(ctx context.Context, filePath string, ext string) (bool, error) {
+	kind := packageKind(filePath, ext)
+	if kind == "" || safeMode || kioskMode || !isInteractive() {
+		return false, nil
+	}
+
+	helpers := packagingHelpers[kind]
+	commands := map[string]string{}
+	items := []string{menuPackageEdit}
+	for _, item := range []struct{ label, command string }{
+		{menuPackageLint, helpers.Lint},
+		{menuPackageDiff, helpers.Diff},
+	} {
+		if !helperAvailable(item.command) {
+			continue
+		}
+		label := fmt.Sprintf("%s (%s)", item.label, item.command)
+		commands[label] = item.command
+		items = append(items, label)
+	}
+	if len(commands) == 0 {
+		return false, nil
+	}
+
+	choice, err := fzfMenu(filepath.Base(filePath)+" ("+kind+")> ", false, append(items, menuCancel)...)
+	if err != nil || choice == "" || choice == menuCancel {
+		return true, nil
+	}
+	if choice == menuPackageEdit {
+		return true, launchFirst(ctx, filePath, associationSpecs(assocTextEditor)...)
+	}
+	if err := runActionCommand(commands[choice], []string{filePath}, ""); err != nil {
+		return true, fmt.Errorf("%s: %w", commands[choice], err)
+	}
+	return true, nil
+}
+
+// helperAvailable сообщает, есть ли в PATH программа, которую запускает
+// команда шелла (первая программа после cd ... &&)
+func helperAvailable(command string) bool {
+	if i := strings.LastIndex(command, "&&"); i >= 0 {
+		command = command[i+2:]
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return false
+	}
+	_, err := cachedLookPath(fields[0])
+	return err == nil
+}
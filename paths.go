@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// appDirName - имя подкаталога утилиты в каталогах XDG
+const appDirName = "fzf-open"
+
+// xdgDir возвращает $envVar/fzf-open или ~/<fallback>/fzf-open, если переменная не задана
+func xdgDir(envVar string, fallback string) (string, error) {
+	if base := os.Getenv(envVar); base != "" && filepath.IsAbs(base) {
+		return filepath.Join(base, appDirName), nil
+	}
+
+	home, err := expandPath("~")
+	if err != nil {
+		return "", err
+	}
+	if home == "" {
+		return "", fmt.Errorf("cannot determine %s: home directory is unknown", envVar)
+	}
+	return filepath.Join(home, fallback, appDirName), nil
+}
+
+// stateDir возвращает каталог состояния ($XDG_STATE_HOME/fzf-open)
+func stateDir() (string, error) {
+	return hostScoped(xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state")))
+}
+
+// cacheDir возвращает каталог кэша ($XDG_CACHE_HOME/fzf-open)
+func cacheDir() (string, error) {
+	return hostScoped(xdgDir("XDG_CACHE_HOME", ".cache"))
+}
+
+// hostScoped добавляет к каталогу состояния или кэша подкаталог hosts/<имя хоста>,
+// если каталог лежит в сетевой файловой системе: домашний каталог, общий для
+// нескольких машин по NFS, не должен получать записи от процессов на разных
+// хостах в одни и те же файлы. FZF_OPEN_PER_HOST=1 или 0 включает или
+// отключает разделение независимо от файловой системы.
+func hostScoped(dir string, err error) (string, error) {
+	if err != nil || !perHostDirs(dir) {
+		return dir, err
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return dir, nil
+	}
+	return filepath.Join(dir, "hosts", host), nil
+}
+
+// perHostDirs сообщает, нужно ли разделять каталог dir по хостам
+func perHostDirs(dir string) bool {
+	if v, err := strconv.ParseBool(os.Getenv("FZF_OPEN_PER_HOST")); err == nil {
+		return v
+	}
+
+	// Каталог может ещё не существовать - проверяется ближайший существующий предок
+	for path := dir; ; path = filepath.Dir(path) {
+		if _, err := os.Stat(path); err == nil {
+			return isNetworkFS(path)
+		}
+		if filepath.Dir(path) == path {
+			return false
+		}
+	}
+}
+
+// runtimeDir возвращает каталог для временных файлов текущего пользователя:
+// $XDG_RUNTIME_DIR/fzf-open или, если переменная не задана, <tmp>/fzf-open-<uid>.
+// Каталог создаётся при необходимости; в общем tmp проверяется, что он
+// принадлежит пользователю и не является символической ссылкой.
+func runtimeDir() (string, error) {
+	if base := os.Getenv("XDG_RUNTIME_DIR"); base != "" && filepath.IsAbs(base) {
+		dir := filepath.Join(base, appDirName)
+		return dir, ensurePrivateDir(dir)
+	}
+
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", appDirName, os.Getuid()))
+	if err := ensureDir(dir); err != nil {
+		return "", err
+	}
+
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() || !isOwnedByCurrentUser(fi) {
+		return "", fmt.Errorf("runtime directory %q is not a directory owned by the current user", dir)
+	}
+	return dir, ensurePrivateDir(dir)
+}
+
+// pickerStatePath возвращает общую часть путей файлов состояния fzf: клавиш
+// навигации, переключателя .gitignore, удержания терминала. Имя содержит PID,
+// поэтому одновременные запуски не мешают друг другу.
+func pickerStatePath() (string, error) {
+	dir, err := runtimeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fmt.Sprintf("picker-%d", os.Getpid())), nil
+}
+
+// ensureDir создаёт каталог с правами только для владельца
+func ensureDir(dir string) error {
+	return os.MkdirAll(dir, 0o700)
+}
+
+// ensurePrivateDir создаёт каталог утилиты и, если он уже существовал с более
+// широкими правами, оставляет доступ только владельцу. Родительские каталоги
+// не меняются.
+func ensurePrivateDir(dir string) error {
+	if err := ensureDir(dir); err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Perm()&^0o700 != 0 {
+		return os.Chmod(dir, fi.Mode().Perm()&0o700)
+	}
+	return nil
+}
+
+// createPrivateFile создаёт пустой файл, доступный только владельцу. Существующий
+// файл обрезается, а его права сужаются до 0600.
+func createPrivateFile(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// passwdFile - база пользователей для последней попытки определить домашний
+// каталог; переменная, чтобы тесты подставляли свою базу
+var passwdFile = "/etc/passwd"
+
+// lookupCurrentUser и osUserHomeDir - источники resolveHomeDir, которые тесты
+// подменяют, чтобы проверить запасные варианты
+var (
+	lookupCurrentUser = user.Current
+	osUserHomeDir     = os.UserHomeDir
+)
+
+// resolveHomeDir определяет домашний каталог пользователя. Источники перебираются
+// по порядку: $FZF_OPEN_HOME, учётная запись пользователя, $HOME, os.UserHomeDir и
+// запись текущего UID в /etc/passwd - в контейнерах и cron часть из них недоступна.
+func resolveHomeDir() (string, error) {
+	var errs []error
+
+	if home := os.Getenv("FZF_OPEN_HOME"); home != "" {
+		return home, nil
+	}
+
+	if u, err := lookupCurrentUser(); err == nil && u.HomeDir != "" {
+		return u.HomeDir, nil
+	} else if err != nil {
+		errs = append(errs, fmt.Errorf("user lookup: %w", err))
+	}
+
+	if home := os.Getenv("HOME"); home != "" {
+		return home, nil
+	}
+	errs = append(errs, errors.New("$HOME is not set"))
+
+	if home, err := osUserHomeDir(); err == nil && home != "" {
+		return home, nil
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+
+	home, err := homeFromPasswd(os.Getuid())
+	if err == nil {
+		return home, nil
+	}
+	errs = append(errs, err)
+
+	return "", fmt.Errorf("home directory is unknown (set HOME or FZF_OPEN_HOME): %w", errors.Join(errs...))
+}
+
+// homeFromPasswd ищет домашний каталог пользователя с указанным UID в passwdFile
+func homeFromPasswd(uid int) (string, error) {
+	return passwdField(uid, 5, "home directory")
+}
+
+// shellFromPasswd ищет оболочку входа пользователя с указанным UID в passwdFile
+func shellFromPasswd(uid int) (string, error) {
+	return passwdField(uid, 6, "login shell")
+}
+
+// passwdField возвращает поле index записи пользователя с указанным UID;
+// what - название поля для сообщения об ошибке
+func passwdField(uid int, index int, what string) (string, error) {
+	if uid < 0 {
+		return "", errors.New("no numeric user id on this platform")
+	}
+
+	f, err := os.Open(passwdFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	want := strconv.Itoa(uid)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 7 || fields[2] != want {
+			continue
+		}
+		if fields[index] == "" {
+			break
+		}
+		return fields[index], nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("uid %d has no %s in %s", uid, what, passwdFile)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePasswd создаёт временную базу пользователей и подставляет её в passwdFile
+func writePasswd(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "passwd")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := passwdFile
+	passwdFile = path
+	t.Cleanup(func() { passwdFile = old })
+}
+
+// stubHomeSources убирает $HOME и $FZF_OPEN_HOME и подменяет учётную запись
+// пользователя и os.UserHomeDir: как в контейнере без записи о пользователе
+func stubHomeSources(t *testing.T, osHome string, osErr error) {
+	t.Helper()
+	t.Setenv("FZF_OPEN_HOME", "")
+	t.Setenv("HOME", "")
+	oldUser, oldHome := lookupCurrentUser, osUserHomeDir
+	lookupCurrentUser = func() (*user.User, error) { return nil, errors.New("no user database") }
+	osUserHomeDir = func() (string, error) { return osHome, osErr }
+	t.Cleanup(func() { lookupCurrentUser, osUserHomeDir = oldUser, oldHome })
+}
+
+func TestResolveHomeDirPrefersFzfOpenHome(t *testing.T) {
+	stubHomeSources(t, "/from/os", nil)
+	t.Setenv("FZF_OPEN_HOME", "/override")
+	t.Setenv("HOME", "/from/env")
+
+	home, err := resolveHomeDir()
+	if err != nil || home != "/override" {
+		t.Fatalf("resolveHomeDir() = %q, %v; want /override", home, err)
+	}
+}
+
+func TestResolveHomeDirFallbacks(t *testing.T) {
+	if os.Getuid() < 0 {
+		t.Skip("no numeric user id on this platform")
+	}
+	uid := os.Getuid()
+	tests := []struct {
+		name   string
+		env    string
+		osHome string
+		osErr  error
+		passwd string
+		want   string
+	}{
+		{"HOME", "/from/env", "/from/os", nil, "", "/from/env"},
+		{"os.UserHomeDir without HOME", "", "/from/os", nil, "", "/from/os"},
+		{"passwd without HOME", "", "", errors.New("$HOME is not defined"),
+			fmt.Sprintf("other:x:%d:0::/home/other:/bin/sh\nme:x:%d:%d::/from/passwd:/bin/zsh\n", uid+1, uid, uid), "/from/passwd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubHomeSources(t, tt.osHome, tt.osErr)
+			t.Setenv("HOME", tt.env)
+			writePasswd(t, tt.passwd)
+
+			home, err := resolveHomeDir()
+			if err != nil || home != tt.want {
+				t.Fatalf("resolveHomeDir() = %q, %v; want %q", home, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveHomeDirError(t *testing.T) {
+	stubHomeSources(t, "", errors.New("$HOME is not defined"))
+	writePasswd(t, fmt.Sprintf("other:x:%d:0::/home/other:/bin/sh\n", os.Getuid()+1))
+
+	home, err := resolveHomeDir()
+	if err == nil {
+		t.Fatalf("resolveHomeDir() = %q, want an error", home)
+	}
+	for _, want := range []string{"set HOME or FZF_OPEN_HOME", "user lookup", "$HOME is not set"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestHomeFromPasswd(t *testing.T) {
+	writePasswd(t, strings.Join([]string{
+		"# comment",
+		"short:x:1000",
+		"alice:x:1000:1000:Alice:/home/alice:/bin/bash",
+		"nohome:x:1001:1001:::/bin/sh",
+		"bob:x:1002:1002::/home/bob:/usr/bin/fish",
+	}, "\n")+"\n")
+
+	tests := []struct {
+		uid     int
+		want    string
+		wantErr bool
+	}{
+		{1000, "/home/alice", false},
+		{1002, "/home/bob", false},
+		{1001, "", true},
+		{4242, "", true},
+		{-1, "", true},
+	}
+	for _, tt := range tests {
+		home, err := homeFromPasswd(tt.uid)
+		if (err != nil) != tt.wantErr || home != tt.want {
+			t.Errorf("homeFromPasswd(%d) = %q, %v; want %q (error: %v)", tt.uid, home, err, tt.want, tt.wantErr)
+		}
+	}
+	if shell, err := shellFromPasswd(1002); err != nil || shell != "/usr/bin/fish" {
+		t.Errorf("shellFromPasswd(1002) = %q, %v", shell, err)
+	}
+}
+
+func TestHomeFromPasswdMissingFile(t *testing.T) {
+	old := passwdFile
+	passwdFile = filepath.Join(t.TempDir(), "missing")
+	t.Cleanup(func() { passwdFile = old })
+
+	if home, err := homeFromPasswd(1000); err == nil {
+		t.Fatalf("homeFromPasswd() = %q, want an error", home)
+	}
+}
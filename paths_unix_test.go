@@ -0,0 +1,96 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// permissiveUmask снимает umask на время теста: права задаёт только код утилиты
+func permissiveUmask(t *testing.T) {
+	t.Helper()
+	old := syscall.Umask(0)
+	t.Cleanup(func() { syscall.Umask(old) })
+}
+
+// assertPerm проверяет права файла или каталога
+func assertPerm(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != want {
+		t.Errorf("%s: mode %04o, want %04o", path, got, want)
+	}
+}
+
+func TestEnsurePrivateDirCreates(t *testing.T) {
+	permissiveUmask(t)
+	dir := filepath.Join(t.TempDir(), "state", "fzf-open")
+
+	if err := ensurePrivateDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	assertPerm(t, dir, 0o700)
+}
+
+func TestEnsurePrivateDirTightensExisting(t *testing.T) {
+	permissiveUmask(t)
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "fzf-open")
+	if err := os.Mkdir(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(parent, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ensurePrivateDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	assertPerm(t, dir, 0o700)
+	// Родительский каталог не трогается
+	assertPerm(t, parent, 0o755)
+}
+
+func TestEnsurePrivateDirKeepsNarrower(t *testing.T) {
+	permissiveUmask(t)
+	dir := filepath.Join(t.TempDir(), "fzf-open")
+	if err := os.Mkdir(dir, 0o500); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0o700) })
+
+	if err := ensurePrivateDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	assertPerm(t, dir, 0o500)
+}
+
+func TestCreatePrivateFile(t *testing.T) {
+	permissiveUmask(t)
+	dir := t.TempDir()
+
+	created := filepath.Join(dir, "new")
+	if err := createPrivateFile(created); err != nil {
+		t.Fatal(err)
+	}
+	assertPerm(t, created, 0o600)
+
+	// Существующий файл с широкими правами обрезается и закрывается от других
+	existing := filepath.Join(dir, "existing")
+	if err := os.WriteFile(existing, []byte("old contents"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := createPrivateFile(existing); err != nil {
+		t.Fatal(err)
+	}
+	assertPerm(t, existing, 0o600)
+	if data, err := os.ReadFile(existing); err != nil || len(data) != 0 {
+		t.Errorf("existing file after createPrivateFile: %q, %v; want it empty", data, err)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PickerConfig - таблица [picker] файла конфигурации
+type PickerConfig struct {
+	// FZF - дополнительные флаги fzf из [picker.fzf]: ключ - длинное имя флага
+	// без "--", true - флаг без значения, false - флаг не передаётся, массив -
+	// флаг повторяется для каждого значения
+	FZF map[string]any `toml:"fzf,omitempty"`
+}
+
+// fzfOptions - действующие флаги из [picker.fzf]
+var fzfOptions = map[string]any{}
+
+// Значения ключа layout
+const (
+	finderFullscreen = "fullscreen" // fzf занимает весь терминал
+	finderInline     = "inline"     // fzf открывается под строкой приглашения, как в шелле
+)
+
+// checkFinderLayout проверяет значение ключа layout
+func checkFinderLayout(value string) error {
+	switch value {
+	case "", finderFullscreen, finderInline:
+		return nil
+	}
+	return fmt.Errorf("layout: unknown value %q (expected %s or %s)", value, finderFullscreen, finderInline)
+}
+
+// Значения ключа mouse
+const (
+	mouseOn    = "on"    // как в fzf: щелчок ставит курсор, двойной щелчок открывает
+	mouseClick = "click" // файл открывается одним щелчком
+	mouseOff   = "off"   // мышь не используется (--no-mouse)
+)
+
+// checkMouse проверяет значение ключа mouse
+func checkMouse(value string) error {
+	switch value {
+	case "", mouseOn, mouseClick, mouseOff:
+		return nil
+	}
+	return fmt.Errorf("mouse: unknown value %q (expected %s, %s or %s)", value, mouseOn, mouseClick, mouseOff)
+}
+
+// addMouse передаёт fzf настройку мыши из ключа mouse: с "off" - --no-mouse,
+// с "click" - привязку, открывающую файл щелчком по строке. Флаги мыши и
+// привязки из [picker.fzf] имеют приоритет.
+func addMouse(opts *pickerOptions) {
+	switch defaultConfig.Mouse {
+	case mouseOff:
+		opts.NoMouse = true
+	case mouseClick:
+		if _, ok := fzfOptions["no-mouse"]; !ok && !fzfOptionBinds("left-click") {
+			opts.Binds = append(opts.Binds, "left-click:accept")
+		}
+	}
+}
+
+// fzfOptionBinds сообщает, привязано ли событие или клавиша key в bind из
+// [picker.fzf]. Флаги [picker.fzf] идут перед флагами fzf-open, поэтому
+// привязка fzf-open к тому же событию заменила бы пользовательскую.
+func fzfOptionBinds(key string) bool {
+	values, ok := fzfOptions["bind"].([]any)
+	if !ok {
+		values = []any{fzfOptions["bind"]}
+	}
+	for _, value := range values {
+		bind, _ := value.(string)
+		for _, chord := range strings.Split(bind, ",") {
+			if k, _, _ := strings.Cut(chord, ":"); normalizeKey(k) == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addInlineLayout открывает fzf под строкой приглашения высотой inline_height,
+// если задан layout = "inline" и fzf запущен в текущем терминале. Высота из
+// [picker.fzf] имеет приоритет.
+func addInlineLayout(opts *pickerOptions, cfg *Config) {
+	if defaultConfig.FinderLayout != finderInline || cfg.SpawnTerm {
+		return
+	}
+	if _, ok := fzfOptions["height"]; ok {
+		return
+	}
+	opts.Height = defaultConfig.InlineHeight
+}
+
+// queryHistoryFileName - файл истории запросов fzf в stateDir
+const queryHistoryFileName = "query-history"
+
+// addQueryHistory передаёт fzf файл истории запросов, если задан
+// query_history = true: Ctrl-P и Ctrl-N листают запросы прошлых запусков.
+// История своя у fzf-open и не смешивается с историей fzf в шелле; в режиме
+// киоска она не ведётся.
+func addQueryHistory(opts *pickerOptions) {
+	if !defaultConfig.QueryHistory || kioskMode {
+		return
+	}
+	if _, ok := fzfOptions["history"]; ok {
+		return
+	}
+	dir, err := stateDir()
+	if err == nil {
+		err = ensureDir(dir)
+	}
+	if err != nil {
+		fmt.Fprintf(logOut, "Warning: query history is unavailable: %v\n", err)
+		return
+	}
+	opts.History = filepath.Join(dir, queryHistoryFileName)
+}
+
+// fzfHelpTimeout ограничивает запуск fzf --help и fzf --version
+const fzfHelpTimeout = 2 * time.Second
+
+var (
+	fzfHelpOnce      sync.Once
+	fzfKnownOptions  map[string]bool
+	fzfVersionString string
+)
+
+// fzfLongOption находит длинные флаги в выводе fzf --help
+var fzfLongOption = regexp.MustCompile(`--([a-z][a-z0-9-]*)`)
+
+// loadFzfHelp узнаёт версию fzf и флаги, которые она поддерживает. Если fzf
+// не запускается, проверка флагов пропускается: ошибку покажет сам запуск.
+func loadFzfHelp() {
+	fields := strings.Fields(finderCommand())
+	if len(fields) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fzfHelpTimeout)
+	defer cancel()
+
+	help, err := execCommand(ctx, "probe", fields[0], "--help").CombinedOutput()
+	if err != nil || len(help) == 0 {
+		return
+	}
+
+	fzfKnownOptions = make(map[string]bool)
+	for _, m := range fzfLongOption.FindAllStringSubmatch(string(help), -1) {
+		fzfKnownOptions[m[1]] = true
+	}
+
+	if out, err := execCommand(ctx, "probe", fields[0], "--version").Output(); err == nil {
+		fzfVersionString = strings.TrimSpace(string(out))
+	}
+}
+
+// fzfOptionArgs проверяет флаги из [picker.fzf] по справке установленного fzf
+// и возвращает их в виде аргументов командной строки
+func fzfOptionArgs() ([]string, error) {
+	if len(fzfOptions) == 0 || !currentFinder().fzfFlags() {
+		return nil, nil
+	}
+	fzfHelpOnce.Do(loadFzfHelp)
+
+	names := make([]string, 0, len(fzfOptions))
+	for name := range fzfOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var args []string
+	for _, name := range names {
+		flag := strings.TrimPrefix(name, "--")
+		if fzfKnownOptions != nil && !fzfKnownOptions[flag] {
+			return nil, unsupportedFzfOption(flag)
+		}
+
+		values, ok := fzfOptions[name].([]any)
+		if !ok {
+			values = []any{fzfOptions[name]}
+		}
+		for _, value := range values {
+			switch v := value.(type) {
+			case bool:
+				if v {
+					args = append(args, "--"+flag)
+				}
+			case string, int64, float64:
+				args = append(args, fmt.Sprintf("--%s=%v", flag, v))
+			default:
+				return nil, fmt.Errorf("picker.fzf.%s: unsupported value %v", name, value)
+			}
+		}
+	}
+	return args, nil
+}
+
+// unsupportedFzfOption формирует ошибку для флага, которого нет в справке fzf
+func unsupportedFzfOption(flag string) error {
+	known := make([]string, 0, len(fzfKnownOptions))
+	for name := range fzfKnownOptions {
+		known = append(known, name)
+	}
+
+	version := fzfVersionString
+	if version == "" {
+		version = "unknown version"
+	}
+	msg := fmt.Sprintf("picker.fzf: fzf (%s) does not support --%s", version, flag)
+	if suggestion := suggestKey(flag, known); suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean --%s?)", suggestion)
+	}
+	return fmt.Errorf("%s", msg)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pipelineOpen - шаг конвейера, который открывает текущий файл настроенным приложением
+const pipelineOpen = "open"
+
+// runPipeline выполняет шаги конвейера name для файла: результат каждого шага
+// с output становится входом следующего. Промежуточные файлы создаются во
+// временном каталоге внутри runtimeDir; если последний шаг открывает файл,
+// каталог остаётся, чтобы приложение успело его прочитать, иначе удаляется.
+func runPipeline(ctx context.Context, name string, steps []string, file string) error {
+	dir, err := runtimeDir()
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(dir, "pipeline-")
+	if err != nil {
+		return err
+	}
+	if steps[len(steps)-1] != pipelineOpen {
+		defer os.RemoveAll(tmpDir)
+	}
+
+	stem := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	current := file
+	for i, step := range steps {
+		fmt.Fprintf(logOut, "[%s %d/%d] %s: %s\n", name, i+1, len(steps), step, filepath.Base(current))
+
+		if step == pipelineOpen {
+			if err := openPath(ctx, current, false); err != nil {
+				return fmt.Errorf("pipeline %q: %w", name, err)
+			}
+			continue
+		}
+
+		action, ok := userActions[step]
+		if !ok || action.Command == "" {
+			return fmt.Errorf("pipeline %q: step %q is not an action with a command", name, step)
+		}
+
+		out := ""
+		if action.Output != "" {
+			out = filepath.Join(tmpDir, fmt.Sprintf("%d-%s.%s", i+1, stem, strings.TrimPrefix(action.Output, ".")))
+		}
+		if err := runActionCommand(action.Command, []string{current}, out); err != nil {
+			return fmt.Errorf("pipeline %q: step %q: %w", name, step, err)
+		}
+
+		if out == "" {
+			continue
+		}
+		if _, err := os.Stat(out); err != nil {
+			return fmt.Errorf("pipeline %q: step %q did not produce %s", name, step, out)
+		}
+		current = out
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultFallbackOpeners открывают файлы, для которых нет своего приложения
+var defaultFallbackOpeners = CommandList{"open"}
+
+const (
+	// mimeQueryTool определяет тип файла по UTI из Spotlight, если формат не
+	// распознан по первым байтам
+	mimeQueryTool = "mdls"
+	mimeQueryHint = "part of macOS; files outside Spotlight-indexed volumes are detected by their first bytes only"
+)
+
+// appBundleDirs - каталоги, в которых ищутся приложения для open -a
+var appBundleDirs = []string{"/Applications", "/System/Applications", "/System/Applications/Utilities"}
+
+// utiMimeTypes - MIME-типы распространённых UTI
+var utiMimeTypes = map[string]string{
+	"com.adobe.pdf":                  mimePDF,
+	"public.plain-text":              mimeTextPrefix + "plain",
+	"public.html":                    "text/html",
+	"public.xml":                     mimeApplicationXML,
+	"public.json":                    mimeApplicationJSON,
+	"public.shell-script":            mimeApplicationScript,
+	"com.netscape.javascript-source": mimeApplicationJS,
+	"public.png":                     "image/png",
+	"public.jpeg":                    "image/jpeg",
+	"com.compuserve.gif":             "image/gif",
+	"public.svg-image":               "image/svg+xml",
+	"public.mpeg-4":                  "video/mp4",
+	"com.apple.quicktime-movie":      "video/quicktime",
+	"public.mp3":                     "audio/mpeg",
+	"org.idpf.epub-container":        mimeEpub,
+	"com.microsoft.word.doc":         mimeWordDoc,
+	"com.microsoft.excel.xls":        mimeExcel,
+	"org.openxmlformats.wordprocessingml.document": mimeWordDocx,
+	"org.openxmlformats.spreadsheetml.sheet":       mimeExcelX,
+	"org.oasis-open.opendocument.text":             mimeODT,
+	"org.oasis-open.opendocument.spreadsheet":      mimeODS,
+	"public.zip-archive":                           "application/zip",
+	"org.gnu.gnu-zip-archive":                      "application/gzip",
+	"public.tar-archive":                           "application/x-tar",
+	"public.folder":                                "inode/directory",
+}
+
+// utiFamilies - MIME-префиксы для UTI, которым соответствует общий тип
+var utiFamilies = []struct{ uti, prefix string }{
+	{"public.text", mimeTextPrefix},
+	{"public.image", mimeImagePrefix},
+	{"public.movie", mimeVideoPrefix},
+	{"public.audio", mimeAudioPrefix},
+	{"public.font", mimeFontPrefix},
+}
+
+// defaultTerminal выбирает терминал для -n: тот, из которого запущен
+// fzf-open, иначе kitty, если он установлен, иначе Terminal.app
+func defaultTerminal() string {
+	switch {
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app":
+		return "iTerm"
+	case os.Getenv("TERM_PROGRAM") == "Apple_Terminal":
+		return "Terminal"
+	case os.Getenv("KITTY_WINDOW_ID") != "":
+		return "kitty"
+	}
+	if _, err := exec.LookPath("kitty"); err == nil {
+		return "kitty"
+	}
+	return "Terminal"
+}
+
+// macTerminalApp возвращает "Terminal" или "iTerm" для терминалов, окна
+// которых открываются через AppleScript, иначе пустую строку
+func macTerminalApp(terminal string) string {
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(terminal), ".app"))
+	switch name {
+	case "terminal":
+		return "Terminal"
+	case "iterm", "iterm2":
+		return "iTerm"
+	}
+	return ""
+}
+
+// terminalArgv возвращает команду, открывающую argv в новом окне терминала
+// (с заголовком title, если он задан). Terminal.app и iTerm2 не запускают
+// команду из аргументов: окно открывается через osascript.
+func terminalArgv(terminal, title string, argv []string) []string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = shellQuote(arg)
+	}
+	// Команда вводится в оболочку пользователя; exec закрывает сеанс вместе с ней
+	command := appleScriptQuote("exec " + strings.Join(quoted, " "))
+
+	var script string
+	switch macTerminalApp(terminal) {
+	case "Terminal":
+		script = "tell application \"Terminal\"\nactivate\nset t to do script " + command + "\n"
+		if title != "" {
+			script += "set custom title of t to " + appleScriptQuote(title) + "\n"
+		}
+		script += "end tell"
+	case "iTerm":
+		script = "tell application \"iTerm\"\nactivate\nset w to (create window with default profile)\n" +
+			"tell current session of w\nwrite text " + command + "\n"
+		if title != "" {
+			script += "set name to " + appleScriptQuote(title) + "\n"
+		}
+		script += "end tell\nend tell"
+	default:
+		return terminalExecArgv(terminal, title, argv)
+	}
+	return []string{"osascript", "-e", script}
+}
+
+// terminalDetached сообщает, возвращает ли команда терминала управление сразу,
+// не дожидаясь завершения запущенной в окне программы: osascript только
+// открывает окно
+func terminalDetached(terminal string) bool {
+	return macTerminalApp(terminal) != ""
+}
+
+// terminalProgram возвращает программу, которую нужно найти в PATH для терминала
+func terminalProgram(terminal string) string {
+	if macTerminalApp(terminal) != "" {
+		return "osascript"
+	}
+	return terminal
+}
+
+// appleScriptQuote заключает строку в кавычки AppleScript
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// appBundleArgv позволяет указывать в ассоциациях приложения macOS по имени
+// ("Preview", "Visual Studio Code"): если такой программы нет в PATH, но есть
+// приложение command.app, файл открывается через open -a
+func appBundleArgv(command, filePath string) ([]string, bool) {
+	name := strings.TrimSuffix(strings.TrimSpace(command), ".app")
+	if name == "" || strings.Contains(name, "/") {
+		return nil, false
+	}
+
+	dirs := appBundleDirs
+	if userHomeDir != "" {
+		dirs = append(dirs[:len(dirs):len(dirs)], filepath.Join(userHomeDir, "Applications"))
+	}
+	for _, dir := range dirs {
+		if fi, err := os.Stat(filepath.Join(dir, name+".app")); err == nil && fi.IsDir() {
+			return []string{"open", "-a", name, filePath}, true
+		}
+	}
+	return nil, false
+}
+
+// queryMimeType определяет тип файла по UTI из метаданных Spotlight (mdls)
+func queryMimeType(ctx context.Context, filePath string) string {
+	mdlsPath, err := cachedLookPath(mimeQueryTool)
+	if err != nil {
+		return ""
+	}
+
+	// Дерево типов начинается с самого UTI файла, дальше - типы, которым он
+	// соответствует: ( "public.png", "public.image", "public.data", ... )
+	output, err := execCommand(ctx, "mime", mdlsPath, "-raw", "-name", "kMDItemContentTypeTree", filePath).Output()
+	if err != nil {
+		return ""
+	}
+	var utis []string
+	for i, part := range strings.Split(string(output), `"`) {
+		if i%2 == 1 {
+			utis = append(utis, part)
+		}
+	}
+	return mimeFromUTIs(utis)
+}
+
+// mimeFromUTIs переводит дерево UTI в MIME-тип: сначала ищется точное
+// соответствие, затем общий тип (изображение, видео, текст)
+func mimeFromUTIs(utis []string) string {
+	for _, uti := range utis {
+		if mimeType, ok := utiMimeTypes[uti]; ok {
+			return mimeType
+		}
+	}
+	if len(utis) == 0 {
+		return ""
+	}
+
+	subtype := utis[0][strings.LastIndexByte(utis[0], '.')+1:]
+	for _, family := range utiFamilies {
+		if slices.Contains(utis, family.uti) {
+			if family.prefix == mimeTextPrefix {
+				return mimeTextPrefix + "plain"
+			}
+			return family.prefix + subtype
+		}
+	}
+	return ""
+}
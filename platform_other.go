@@ -0,0 +1,83 @@
+//go:build !darwin && !windows
+
+package main
+
+import (
+	"context"
+)
+
+// defaultFallbackOpeners открывают файлы, для которых нет своего приложения
+var defaultFallbackOpeners = fallbackOpeners()
+
+// fallbackOpeners возвращает открывалки по умолчанию. xdg-utils есть не везде
+// (в BSD это отдельный пакет), поэтому за xdg-open следуют открывалки GLib и
+// Xfce. В WSL файлы открываются приложениями Windows: через wslview из wslu
+// или explorer.exe, в Termux - приложениями Android через termux-open.
+func fallbackOpeners() CommandList {
+	if isTermux() {
+		return CommandList{"termux-open"}
+	}
+	if isWSL() {
+		return CommandList{"wslview", "explorer.exe"}
+	}
+	return CommandList{"xdg-open", "gio open", "exo-open"}
+}
+
+const (
+	// mimeQueryTool определяет MIME-тип, если формат не распознан по первым байтам
+	mimeQueryTool = "xdg-mime"
+	mimeQueryHint = "install xdg-utils; without it only formats recognized by their first bytes are detected"
+)
+
+// defaultTerminal возвращает терминал для -n по умолчанию
+func defaultTerminal() string {
+	return "alacritty"
+}
+
+// terminalArgv возвращает команду, открывающую argv в новом окне терминала
+// (с заголовком title, если он задан)
+func terminalArgv(terminal, title string, argv []string) []string {
+	return terminalExecArgv(terminal, title, argv)
+}
+
+// terminalDetached сообщает, возвращает ли команда терминала управление сразу,
+// не дожидаясь завершения запущенной в окне программы
+func terminalDetached(terminal string) bool {
+	return false
+}
+
+// terminalProgram возвращает программу, которую нужно найти в PATH для терминала
+func terminalProgram(terminal string) string {
+	return terminal
+}
+
+// appBundleArgv - приложения вне PATH есть только в macOS
+func appBundleArgv(command, filePath string) ([]string, bool) {
+	return nil, false
+}
+
+// queryMimeType спрашивает MIME-тип у xdg-mime
+func queryMimeType(ctx context.Context, filePath string) string {
+	xdgMimePath, err := cachedLookPath(mimeQueryTool)
+	if err != nil {
+		return ""
+	}
+
+	cmd := execCommand(ctx, "mime", xdgMimePath, "query", "filetype", filePath)
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	end := len(output)
+	for end > 0 && (output[end-1] == '\n' || output[end-1] == '\r' || output[end-1] == ' ' || output[end-1] == '\t') {
+		end--
+	}
+
+	start := 0
+	for start < end && (output[start] == ' ' || output[start] == '\t') {
+		start++
+	}
+
+	return string(output[start:end])
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// defaultFallbackOpeners открывают файл приложением, связанным с ним в
+// Windows. В отличие от cmd /c start, путь с пробелами не нужно заключать в
+// кавычки и он не принимается за заголовок окна.
+var defaultFallbackOpeners = CommandList{"rundll32 url.dll,FileProtocolHandler"}
+
+const (
+	// mimeQueryTool - в Windows внешней программы для MIME-типа нет: тип
+	// определяется по расширению и первым байтам
+	mimeQueryTool = ""
+	mimeQueryHint = ""
+)
+
+// defaultTerminal возвращает терминал для -n по умолчанию
+func defaultTerminal() string {
+	return "wt.exe"
+}
+
+// isWindowsTerminal сообщает, является ли terminal программой wt.exe
+func isWindowsTerminal(terminal string) bool {
+	return strings.TrimSuffix(strings.ToLower(filepath.Base(terminal)), ".exe") == "wt"
+}
+
+// terminalArgv возвращает команду, открывающую argv в новом окне терминала
+// (с заголовком title, если он задан). wt.exe разделяет свои подкоманды
+// символом ";", поэтому в аргументах он экранируется.
+func terminalArgv(terminal, title string, argv []string) []string {
+	if !isWindowsTerminal(terminal) {
+		return terminalExecArgv(terminal, title, argv)
+	}
+
+	cmd := []string{terminal, "new-tab"}
+	if title != "" {
+		cmd = append(cmd, "--title", title)
+	}
+	cmd = append(cmd, "--")
+	for _, arg := range argv {
+		cmd = append(cmd, strings.ReplaceAll(arg, ";", `\;`))
+	}
+	return cmd
+}
+
+// terminalDetached сообщает, возвращает ли команда терминала управление сразу,
+// не дожидаясь завершения запущенной в окне программы: wt.exe передаёт вкладку
+// уже работающему Windows Terminal
+func terminalDetached(terminal string) bool {
+	return isWindowsTerminal(terminal)
+}
+
+// terminalProgram возвращает программу, которую нужно найти в PATH для терминала
+func terminalProgram(terminal string) string {
+	return terminal
+}
+
+// appBundleArgv - приложения вне PATH есть только в macOS
+func appBundleArgv(command, filePath string) ([]string, bool) {
+	return nil, false
+}
+
+// queryMimeType - в Windows MIME-тип определяется только без внешних программ
+func queryMimeType(ctx context.Context, filePath string) string {
+	return ""
+}
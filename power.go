@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// powerSaver - режим энергосбережения (флаг --power-saver или power_saver):
+// fd и rg обходят каталоги в один поток, превью PDF и архивов не строятся
+// (показываются только уже готовые из кэша), а окна приложений и терминала
+// опрашиваются реже
+var powerSaver bool
+
+// powerSaverFlag - флаг --power-saver: режим включён независимо от питания
+var powerSaverFlag bool
+
+// Значения ключа power_saver; пусто - режим выключен
+const (
+	powerSaverOff  = "off"
+	powerSaverAuto = "auto" // включать, когда ноутбук работает от батареи
+	powerSaverOn   = "on"
+)
+
+// powerSaverProfile - профиль, который применяется в режиме энергосбережения,
+// если профиль не выбран флагом -p
+const powerSaverProfile = "power_saver"
+
+// powerSaverEnv передаёт режим процессам превью, которые запускает fzf
+const powerSaverEnv = "FZF_OPEN_POWER_SAVER"
+
+// powerSaverPollFactor - во сколько раз реже опрашиваются окна в режиме энергосбережения
+const powerSaverPollFactor = 5
+
+// powerSupplyDir - источники питания в sysfs
+const powerSupplyDir = "/sys/class/power_supply"
+
+// updatePowerSaver включает или выключает режим по флагу и ключу power_saver
+func updatePowerSaver() {
+	switch strings.ToLower(defaultConfig.PowerSaver) {
+	case powerSaverOn:
+		powerSaver = true
+	case powerSaverAuto:
+		powerSaver = powerSaverFlag || onBattery()
+	default:
+		powerSaver = powerSaverFlag
+	}
+}
+
+// checkPowerSaver проверяет значение ключа power_saver
+func checkPowerSaver(value string) error {
+	switch strings.ToLower(value) {
+	case "", powerSaverOff, powerSaverAuto, powerSaverOn:
+		return nil
+	}
+	return fmt.Errorf("power_saver: unknown value %q (expected auto, on or off)", value)
+}
+
+// powerSupplyChanged сообщает, что в режиме auto ноутбук отключили от сети
+// или подключили к ней и режим энергосбережения нужно пересчитать
+func powerSupplyChanged() bool {
+	if powerSaverFlag || !strings.EqualFold(defaultConfig.PowerSaver, powerSaverAuto) {
+		return false
+	}
+	return onBattery() != powerSaver
+}
+
+// onBattery сообщает, работает ли компьютер от батареи: есть разряжающаяся
+// батарея и нет подключённого блока питания. Без sysfs (не Linux) - false.
+func onBattery() bool {
+	supplies, _ := filepath.Glob(filepath.Join(powerSupplyDir, "*"))
+	discharging := false
+	for _, dir := range supplies {
+		switch readSysfs(dir, "type") {
+		case "Mains", "USB":
+			if readSysfs(dir, "online") == "1" {
+				return false
+			}
+		case "Battery":
+			// Батареи периферии (мыши, геймпады) питание компьютера не описывают
+			if readSysfs(dir, "scope") == "Device" {
+				continue
+			}
+			if readSysfs(dir, "status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging
+}
+
+// readSysfs читает атрибут sysfs без завершающего перевода строки
+func readSysfs(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// pollInterval возвращает период опроса окон с учётом режима энергосбережения
+func pollInterval() time.Duration {
+	if powerSaver {
+		return windowPollInterval * powerSaverPollFactor
+	}
+	return windowPollInterval
+}
+
+// sourceThreadArgs - флаги fd и rg, которые в режиме энергосбережения
+// ограничивают обход каталогов одним потоком
+func sourceThreadArgs() []string {
+	if !powerSaver {
+		return nil
+	}
+	return []string{"--threads", "1"}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// previewTextLines - сколько строк текстового файла показывать в превью
+const previewTextLines = 200
+
+// thumbnailSizes - подкаталоги кэша миниатюр freedesktop в порядке
+// предпочтения для окна превью
+var thumbnailSizes = []string{"large", "x-large", "xx-large", "normal"}
+
+// previewCommand возвращает команду --preview для fzf, если превью включено.
+// Профиль передаётся подкоманде, чтобы она взяла из него [previewers].
+func previewCommand(profile string) string {
+	if !defaultConfig.Preview || safeMode {
+		return ""
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(logOut, "Warning: preview disabled: %v\n", err)
+		return ""
+	}
+	command := shellQuote(exe) + " preview "
+	if profile != "" {
+		command += "-p " + shellQuote(profile) + " "
+	}
+	// fzf сам заключает {} в кавычки
+	return command + "{}"
+}
+
+// runPreview реализует подкоманду preview, которую fzf вызывает для текущей
+// строки: сначала ищется команда из [previewers] для MIME-типа файла, иначе
+// изображения и видео показываются по миниатюре из кэша, если она есть
+// (через kitty icat или chafa), PDF и архивы - текстом из кэша превью,
+// текст - через bat или первыми строками, каталоги - списком ls
+func runPreview(args []string) int {
+	if len(args) == 2 && args[0] == "--generate" {
+		return generatePreview(args[1])
+	}
+	profile := ""
+	if len(args) == 3 && args[0] == "-p" {
+		profile, args = args[1], args[2:]
+	}
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: fzf-open preview [-p profile] <file>")
+		return 2
+	}
+	// С ошибкой в конфигурации превью остаётся встроенным
+	powerSaverFlag = os.Getenv(powerSaverEnv) == "1"
+	_ = loadConfig(profile)
+
+	arg := args[0]
+	// Строка списка со значком из icons и столбцами --details
+	if _, err := os.Stat(arg); err != nil {
+		arg = stripDecoration(arg)
+	}
+	path, err := filepath.Abs(arg)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	line := 0
+	fi, err := os.Stat(path)
+	if err != nil {
+		// Строка вида "путь:строка:текст" из --grep
+		if file, n, ok := splitLineSuffix(path); ok {
+			path, line = file, n
+			fi, err = os.Stat(path)
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if fi.IsDir() {
+		if command, ok := previewerFor(mimeDirectory); ok {
+			return runPreviewer(command, path)
+		}
+		return previewDirectory(path)
+	}
+	if len(previewers) > 0 {
+		if command, ok := previewerFor(getMimeType(path)); ok {
+			return runPreviewer(command, path)
+		}
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	_, isImage := extToImageViewer[ext]
+	_, isVideo := extToVideoPlayer[ext]
+	if isImage || isVideo {
+		if thumb, ok := cachedThumbnail(path, fi); ok {
+			return showImage(thumb)
+		}
+		if isImage {
+			return showImage(path)
+		}
+	}
+
+	if _, ok := expensivePreview(path); ok {
+		return showCachedPreview(path, fi)
+	}
+
+	if line > 0 && isTextFile(path) {
+		previewLines(path, line)
+		return 0
+	}
+	if isTextFile(path) {
+		if previewWithBat(path) {
+			return 0
+		}
+		previewText(path)
+		return 0
+	}
+	fmt.Printf("%s\n%d bytes\n", filepath.Base(path), fi.Size())
+	return 0
+}
+
+// cachedThumbnail ищет миниатюру файла в кэше freedesktop (~/.cache/thumbnails).
+// Имя миниатюры - md5 от URI файла; миниатюра устаревшего файла (Thumb::MTime не
+// совпадает с временем изменения) не используется.
+func cachedThumbnail(path string, fi os.FileInfo) (string, bool) {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if !filepath.IsAbs(base) {
+		home, err := expandPath("~")
+		if err != nil || home == "" {
+			return "", false
+		}
+		base = filepath.Join(home, ".cache")
+	}
+
+	uri := (&url.URL{Scheme: "file", Path: path}).String()
+	sum := md5.Sum([]byte(uri))
+	name := hex.EncodeToString(sum[:]) + ".png"
+
+	for _, size := range thumbnailSizes {
+		thumb := filepath.Join(base, "thumbnails", size, name)
+		mtime, ok := pngTextChunk(thumb, "Thumb::MTime")
+		if !ok {
+			continue
+		}
+		if mtime == strconv.FormatInt(fi.ModTime().Unix(), 10) {
+			return thumb, true
+		}
+	}
+	return "", false
+}
+
+// pngTextChunk возвращает значение ключа из текстового блока tEXt файла PNG.
+// Блоки читаются только до начала данных изображения.
+func pngTextChunk(path, key string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	signature := make([]byte, 8)
+	if _, err := io.ReadFull(r, signature); err != nil || string(signature) != "\x89PNG\r\n\x1a\n" {
+		return "", false
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return "", false
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		kind := string(header[4:])
+		if kind == "IDAT" || kind == "IEND" || length > 1<<20 {
+			return "", false
+		}
+
+		data := make([]byte, length+4) // с контрольной суммой
+		if _, err := io.ReadFull(r, data); err != nil {
+			return "", false
+		}
+		if kind != "tEXt" {
+			continue
+		}
+		if k, v, ok := bytes.Cut(data[:length], []byte{0}); ok && string(k) == key {
+			return string(v), true
+		}
+	}
+}
+
+// showImage выводит изображение в окно превью: в kitty - графикой через
+// kitty icat, в остальных терминалах - символами через chafa
+func showImage(path string) int {
+	cols, lines := os.Getenv("FZF_PREVIEW_COLUMNS"), os.Getenv("FZF_PREVIEW_LINES")
+	if os.Getenv("KITTY_WINDOW_ID") != "" && cols != "" && lines != "" {
+		if kitty, err := cachedLookPath("kitty"); err == nil {
+			cmd := execCommand(context.Background(), "preview", kitty, "+kitten", "icat", "--clear", "--transfer-mode=memory",
+				"--stdin=no", "--place="+cols+"x"+lines+"@0x0", path)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if cmd.Run() == nil {
+				return 0
+			}
+		}
+	}
+
+	chafa, err := cachedLookPath("chafa")
+	if err != nil {
+		fmt.Printf("%s\n(install chafa to preview images)\n", filepath.Base(path))
+		return 0
+	}
+
+	args := []string{}
+	if cols != "" && lines != "" {
+		args = append(args, "--size="+cols+"x"+lines)
+	}
+	cmd := execCommand(context.Background(), "preview", chafa, append(args, path)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// isTextFile сообщает, похоже ли начало файла на текст
+func isTextFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	head := make([]byte, 4096)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	return bytes.IndexByte(head, 0) < 0 && utf8.Valid(trimPartialRune(head))
+}
+
+// previewText печатает первые строки текстового файла
+func previewText(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < previewTextLines && scanner.Scan(); i++ {
+		fmt.Println(scanner.Text())
+	}
+}
+
+// previewWithBat печатает начало файла с подсветкой синтаксиса через bat (в
+// Debian и Ubuntu он называется batcat)
+func previewWithBat(path string) bool {
+	for _, name := range []string{"bat", "batcat"} {
+		bat, err := cachedLookPath(name)
+		if err != nil {
+			continue
+		}
+		cmd := execCommand(context.Background(), "preview", bat, "--color=always", "--style=numbers", "--paging=never",
+			"--line-range=:"+strconv.Itoa(previewTextLines), path)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stdout
+		cmd.Run()
+		return true
+	}
+	return false
+}
+
+// previewDirectory показывает содержимое каталога через ls в цветах
+// LS_COLORS или базы dircolors. ls без --color (BSD, busybox) выводит список
+// без цветов.
+func previewDirectory(path string) int {
+	var out bytes.Buffer
+	cmd := execCommand(context.Background(), "preview", "ls", "-lA", "--color=always", path)
+	cmd.Env = lsColorsEnv()
+	cmd.Stdout = &out
+	if cmd.Run() == nil {
+		os.Stdout.Write(out.Bytes())
+		return 0
+	}
+	cmd = execCommand(context.Background(), "preview", "ls", "-lA", path)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stdout
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("%s/\n", filepath.Base(path))
+	}
+	return 0
+}
+
+// trimPartialRune отбрасывает незаконченный символ UTF-8 в конце прочитанного блока
+func trimPartialRune(b []byte) []byte {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size != 1 {
+			return b
+		}
+		b = b[:len(b)-1]
+	}
+	return b
+}
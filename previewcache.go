@@ -0,0 +1,279 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// previewPlaceholderDelay - сколько превью ждёт фоновую генерацию, прежде
+	// чем показать заглушку
+	previewPlaceholderDelay = 150 * time.Millisecond
+	// previewGenerateTimeout ограничивает генерацию одного превью
+	previewGenerateTimeout = 30 * time.Second
+	// previewPollInterval - период проверки готовности превью
+	previewPollInterval = 50 * time.Millisecond
+	// previewListLimit - сколько записей архива показывать
+	previewListLimit = 500
+)
+
+// previewGenerator строит текст превью файла
+type previewGenerator func(ctx context.Context, path string, w io.Writer) error
+
+// expensivePreview возвращает генератор для файлов, превью которых строится
+// долго: оно кэшируется и создаётся в фоне
+func expensivePreview(path string) (previewGenerator, bool) {
+	name := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasSuffix(name, ".pdf"):
+		if _, err := cachedLookPath("pdftotext"); err != nil {
+			return nil, false
+		}
+		return pdfPreview, true
+	case hasAnySuffix(name, ".zip", ".jar", ".apk", ".epub", ".docx", ".xlsx", ".odt", ".ods"):
+		return zipPreview, true
+	case hasAnySuffix(name, ".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2"):
+		return tarPreview, true
+	}
+	return nil, false
+}
+
+// hasAnySuffix сообщает, оканчивается ли s одним из суффиксов
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// showCachedPreview печатает превью из кэша. Если его ещё нет, запускает
+// фоновую генерацию и, пока она идёт, показывает заглушку: fzf завершает
+// процесс превью при переходе к другому файлу, а генерация продолжается, так
+// что прокрутка списка не тормозит. Заглушка печатается в stderr, чтобы в
+// stdout было только само превью.
+func showCachedPreview(path string, fi os.FileInfo) int {
+	cachePath, err := previewCachePath(path, fi)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if data, err := os.ReadFile(cachePath); err == nil {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if powerSaver {
+		fmt.Println("preview is not generated in power saver mode")
+		return 0
+	}
+
+	exited, err := startPreviewGenerator(path)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	placeholder := time.Now().Add(previewPlaceholderDelay)
+	deadline := time.Now().Add(previewGenerateTimeout)
+	shown := false
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			os.Stdout.Write(data)
+			return 0
+		}
+		if !shown && time.Now().After(placeholder) {
+			fmt.Fprintln(os.Stderr, "loading…")
+			shown = true
+		}
+		select {
+		case <-exited:
+			// Генератор завершился. Превью нет - ждать нечего, если только его
+			// не строит другой процесс, который держит блокировку
+			if data, err := os.ReadFile(cachePath); err == nil {
+				os.Stdout.Write(data)
+				return 0
+			}
+			lockPath := cachePath + ".lock"
+			if unlock, err := tryLockFile(lockPath); !errors.Is(err, errLocked) {
+				if err == nil {
+					os.Remove(lockPath)
+					unlock()
+				}
+				return 1
+			}
+			exited = nil
+		case <-time.After(previewPollInterval):
+		}
+	}
+	return 1
+}
+
+// previewCachePath возвращает путь превью в кэше. Ключ учитывает путь, размер и
+// время изменения файла, так что изменённый файл получает новое превью.
+func previewCachePath(path string, fi os.FileInfo) (string, error) {
+	base, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d", path, fi.Size(), fi.ModTime().UnixNano())))
+	return filepath.Join(base, "previews", hex.EncodeToString(sum[:16])+".txt"), nil
+}
+
+// startPreviewGenerator запускает "fzf-open preview --generate" отдельной
+// группой процессов, чтобы fzf не завершил её вместе с процессом превью.
+// Канал закрывается, когда генератор завершится.
+func startPreviewGenerator(path string) (<-chan struct{}, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, "preview", "--generate", path)
+	cmd.SysProcAttr = newProcessGroup()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	return exited, nil
+}
+
+// generatePreview строит превью и атомарно записывает его в кэш. Одновременно
+// превью файла строит только один процесс; ошибка тоже кэшируется, чтобы не
+// повторять заведомо неудачную генерацию.
+func generatePreview(path string) int {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 1
+	}
+	gen, ok := expensivePreview(path)
+	if !ok {
+		return 1
+	}
+	cachePath, err := previewCachePath(path, fi)
+	if err != nil {
+		return 1
+	}
+	base, _ := cacheDir()
+	if err := ensurePrivateDir(base); err != nil {
+		return 1
+	}
+	if err := ensurePrivateDir(filepath.Dir(cachePath)); err != nil {
+		return 1
+	}
+
+	lockPath := cachePath + ".lock"
+	unlock, err := tryLockFile(lockPath)
+	if err != nil {
+		return 0
+	}
+	defer unlock()
+	defer os.Remove(lockPath)
+	// Превью могли построить, пока блокировку держал другой процесс
+	if _, err := os.Stat(cachePath); err == nil {
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), previewGenerateTimeout)
+	defer cancel()
+
+	var buf bytes.Buffer
+	if err := gen(ctx, path, &buf); err != nil {
+		fmt.Fprintf(&buf, "\npreview failed: %v\n", err)
+	}
+
+	tmp := cachePath + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return 1
+	}
+	if err := os.Rename(tmp, cachePath); err != nil {
+		os.Remove(tmp)
+		return 1
+	}
+	pruneOrphanLocks(filepath.Dir(cachePath))
+	return 0
+}
+
+// pdfPreview - текст первых страниц PDF
+func pdfPreview(ctx context.Context, path string, w io.Writer) error {
+	cmd := execCommand(ctx, "preview", "pdftotext", "-l", "10", "-layout", path, "-")
+	cmd.Stdout = w
+	return cmd.Run()
+}
+
+// zipPreview - список файлов zip-архива (в том числе docx, epub, jar)
+func zipPreview(_ context.Context, path string, w io.Writer) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for i, f := range r.File {
+		if i == previewListLimit {
+			fmt.Fprintf(w, "… and %d more\n", len(r.File)-i)
+			break
+		}
+		fmt.Fprintf(w, "%10d  %s\n", f.UncompressedSize64, f.Name)
+	}
+	return nil
+}
+
+// tarPreview - список файлов tar-архива, в том числе сжатого gzip или bzip2
+func tarPreview(ctx context.Context, path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	name := strings.ToLower(path)
+	switch {
+	case hasAnySuffix(name, ".gz", ".tgz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case hasAnySuffix(name, ".bz2", ".tbz2"):
+		r = bzip2.NewReader(f)
+	}
+
+	tr := tar.NewReader(r)
+	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if i == previewListLimit {
+			fmt.Fprintln(w, "…")
+			return nil
+		}
+		fmt.Fprintf(w, "%10d  %s\n", hdr.Size, hdr.Name)
+	}
+}
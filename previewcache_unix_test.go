@@ -0,0 +1,95 @@
+//go:build unix
+
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureStdout возвращает, что fn напечатала в os.Stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	old := os.Stdout
+	os.Stdout = f
+	defer func() { os.Stdout = old }()
+
+	fn()
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// usePreviewGenerator настраивает окружение, в котором генератор превью -
+// тестовый бинарник, запущенный как fzf-open (см. TestMain)
+func usePreviewGenerator(t *testing.T) {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("FZF_OPEN_TEST_MAIN", "1")
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(root, "cache"))
+	t.Setenv("FZF_OPEN_CONFIG", filepath.Join(root, "config.toml"))
+}
+
+func TestShowCachedPreviewGenerates(t *testing.T) {
+	usePreviewGenerator(t)
+	archive := filepath.Join(t.TempDir(), "docs.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	if _, err := zw.Create("inside/readme.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	fi, err := os.Stat(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var code int
+	out := captureStdout(t, func() { code = showCachedPreview(archive, fi) })
+	if code != 0 || !strings.Contains(out, "inside/readme.txt") {
+		t.Fatalf("showCachedPreview() = %d, output %q", code, out)
+	}
+	if strings.Contains(out, "loading") {
+		t.Errorf("placeholder in the preview output: %q", out)
+	}
+}
+
+func TestShowCachedPreviewStopsWhenGeneratorExits(t *testing.T) {
+	usePreviewGenerator(t)
+	// Для текстового файла генератор сразу завершается без превью
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(file, []byte("notes\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	var code int
+	out := captureStdout(t, func() { code = showCachedPreview(file, fi) })
+	if code != 1 || out != "" {
+		t.Errorf("showCachedPreview() = %d, output %q; want 1 and no output", code, out)
+	}
+	if elapsed := time.Since(start); elapsed > previewGenerateTimeout/3 {
+		t.Errorf("showCachedPreview() waited %v after the generator exited", elapsed)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+)
+
+// previewerTimeout ограничивает команду превью из [previewers]
+const previewerTimeout = 10 * time.Second
+
+// mimeDirectory - MIME-тип каталога, ключ [previewers] для превью каталогов
+const mimeDirectory = "inode/directory"
+
+// previewers - команды превью из таблицы [previewers]: MIME-тип или шаблон
+// вида "image/*" -> команда шелла, {file} заменяется путём в кавычках.
+// Проверяются раньше встроенного превью.
+var previewers = map[string]string{}
+
+// previewerFor возвращает команду превью для MIME-типа: сначала по точному
+// совпадению, затем по шаблону "тип/*"
+func previewerFor(mimeType string) (string, bool) {
+	if mimeType == "" || len(previewers) == 0 {
+		return "", false
+	}
+
+	mimeType = normalizeMIME(mimeType)
+	if command, ok := previewers[mimeType]; ok {
+		return command, true
+	}
+	if major, _, ok := strings.Cut(mimeType, "/"); ok {
+		command, ok := previewers[major+"/*"]
+		return command, ok
+	}
+	return "", false
+}
+
+// runPreviewer выполняет команду превью из конфигурации, её вывод попадает в
+// окно превью fzf
+func runPreviewer(command string, path string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), previewerTimeout)
+	defer cancel()
+
+	argv := shellArgv(strings.ReplaceAll(command, "{file}", shellQuote(path)))
+	cmd := execCommand(ctx, "previewer", argv[0], argv[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stdout
+	if err := cmd.Run(); err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package main
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// newProcessGroup - атрибуты запуска отдельной группой процессов: приложение не
+// получает сигналы терминала fzf-open и завершается вместе со своими потомками
+func newProcessGroup() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup завершает группу процессов, начатую процессом pid:
+// SIGTERM, а при force - SIGKILL
+func signalProcessGroup(pid int, force bool) {
+	sig := syscall.SIGTERM
+	if force {
+		sig = syscall.SIGKILL
+	}
+	syscall.Kill(-pid, sig)
+}
+
+// shellArgv возвращает команду, выполняющую строку command в POSIX-шелле
+func shellArgv(command string) []string {
+	return []string{"/bin/sh", "-c", command}
+}
+
+// fallbackShells - оболочки, которые ищутся, если $SHELL не подходит. В
+// OpenBSD и NetBSD стандартная оболочка - ksh, bash и zsh там ставятся из
+// пакетов и лежат в /usr/local/bin.
+func fallbackShells() []string {
+	switch runtime.GOOS {
+	case "openbsd", "netbsd":
+		return []string{"ksh", "zsh", "bash", "sh"}
+	}
+	return []string{"zsh", "bash", "fish", "dash", "sh"}
+}
+
+// inheritsExtraFiles - дочерний процесс получает exec.Cmd.ExtraFiles
+// дескрипторами 3, 4, …
+const inheritsExtraFiles = true
+
+// hasPosixShell сообщает, можно ли запускать команды через POSIX-шелл
+func hasPosixShell() bool {
+	return true
+}
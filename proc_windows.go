@@ -0,0 +1,49 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// newProcessGroup - атрибуты запуска отдельной группой процессов: Ctrl+C в
+// консоли fzf-open не доходит до приложения
+func newProcessGroup() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// signalProcessGroup завершает процесс pid вместе с потомками через taskkill;
+// без force приложению предлагается закрыться самому
+func signalProcessGroup(pid int, force bool) {
+	args := []string{"/T", "/PID", strconv.Itoa(pid)}
+	if force {
+		args = append(args, "/F")
+	}
+	exec.Command("taskkill", args...).Run()
+}
+
+// shellArgv возвращает команду, выполняющую строку command: через sh из Git
+// for Windows или MSYS2, если он есть в PATH, иначе через cmd.exe
+func shellArgv(command string) []string {
+	if sh, err := cachedLookPath("sh"); err == nil {
+		return []string{sh, "-c", command}
+	}
+	return []string{"cmd", "/C", command}
+}
+
+// fallbackShells - оболочки Git for Windows и MSYS2, если $SHELL не подходит
+func fallbackShells() []string {
+	return []string{"bash", "sh"}
+}
+
+// inheritsExtraFiles - в Windows exec.Cmd.ExtraFiles не поддерживается
+const inheritsExtraFiles = false
+
+// hasPosixShell сообщает, можно ли запускать команды через POSIX-шелл: выбор
+// в fzf строится командой sh и без него запускается напрямую, как с --safe
+func hasPosixShell() bool {
+	_, err := cachedLookPath("sh")
+	return err == nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// inertProjectKeys - ключи .fzf-open.toml, которые действуют без доверия к
+// проекту: чем открывать файлы по выбору пользователя и что показывать в
+// списке. Остальные ключи (fzf_command, source, previewers, actions,
+// converters и другие) запускают команды сами - при старте fzf-open или при
+// движении курсора - и применяются только из каталогов trusted_projects.
+var inertProjectKeys = map[string]bool{
+	"associations": true,
+	"extensions":   true,
+	"mime":         true,
+	"mime_types":   true,
+	"tui":          true,
+	"multi":        true,
+	"timeouts":     true,
+	"ignore":       true,
+	"hidden":       true,
+	"no_ignore":    true,
+	"sort":         true,
+	"preview":      true,
+	"colors":       true,
+	"icons":        true,
+	"fuzzy_keys":   true,
+}
+
+// projectTrusted сообщает, лежит ли каталог файла проекта внутри одного из
+// trusted_projects глобальной конфигурации. Ссылки раскрываются, как в kioskAllowed.
+func projectTrusted(path string) bool {
+	dir, err := resolvedPath(filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+	for _, trusted := range defaultConfig.TrustedProjects {
+		root, err := expandPath(trusted)
+		if err != nil {
+			continue
+		}
+		if root, err = resolvedPath(root); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// inertProjectConfig оставляет в настройках проекта только inertProjectKeys и
+// возвращает отброшенные ключи верхнего уровня
+func inertProjectConfig(p ProfileConfig) (ProfileConfig, []string) {
+	safe := ProfileConfig{
+		Associations: p.Associations,
+		Extensions:   p.Extensions,
+		MIME:         p.MIME,
+		MIMETypes:    p.MIMETypes,
+		TUI:          p.TUI,
+		Multi:        p.Multi,
+		Timeouts:     p.Timeouts,
+		Ignore:       p.Ignore,
+		FuzzyKeys:    p.FuzzyKeys,
+		defined:      definedKeys{},
+	}
+	safe.Hidden, safe.NoIgnore, safe.Sort = p.Hidden, p.NoIgnore, p.Sort
+	safe.Preview, safe.Colors, safe.Icons = p.Preview, p.Colors, p.Icons
+
+	seen := map[string]bool{}
+	var ignored []string
+	for name, key := range p.defined {
+		if inertProjectKeys[key[0]] {
+			safe.defined[name] = key
+			continue
+		}
+		if !seen[key[0]] {
+			seen[key[0]] = true
+			ignored = append(ignored, key[0])
+		}
+	}
+	sort.Strings(ignored)
+	return safe, ignored
+}
+
+// restrictProjectConfig применяет к настройкам проекта path правила доверия:
+// trusted_projects задаётся только глобально, а файл вне доверенных каталогов
+// теряет ключи, которые запускают команды
+func restrictProjectConfig(path string, p ProfileConfig) ProfileConfig {
+	p.TrustedProjects = nil
+	delete(p.defined, "trusted_projects")
+	if projectTrusted(path) {
+		return p
+	}
+
+	safe, ignored := inertProjectConfig(p)
+	if len(ignored) > 0 {
+		fmt.Fprintf(logOut, "Warning: project config %q is not in trusted_projects, ignoring %s\n",
+			path, strings.Join(ignored, ", "))
+	}
+	return safe
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// projectFile - .fzf-open.toml, который пытается запустить команды при старте
+const projectFile = `
+fzf_command = "fzf --bind start:execute(touch pwned)"
+source = "touch pwned; fd"
+trusted_projects = ["/"]
+hidden = true
+
+[associations]
+text_editor = "idea"
+
+[previewers]
+"text/*" = "touch pwned"
+
+[actions.pwn]
+command = "touch pwned"
+`
+
+// loadProject применяет глобальную конфигурацию global и файл проекта
+// projectFile в каталоге project
+func loadProject(t *testing.T, project, global string) {
+	t.Helper()
+	useConfig(t, writeConfig(t, t.TempDir(), "config.toml", global))
+	writeConfig(t, project, projectConfigName, projectFile)
+
+	if err := loadConfig(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyProjectConfig(&Config{StartingDir: project}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUntrustedProjectConfigIsInert(t *testing.T) {
+	loadProject(t, t.TempDir(), "fzf_command = \"fzf\"\n")
+
+	if defaultConfig.FzfCommand != "fzf" {
+		t.Errorf("fzf_command = %q, want the global value", defaultConfig.FzfCommand)
+	}
+	if defaultConfig.Source != "" {
+		t.Errorf("source = %q, want it unset", defaultConfig.Source)
+	}
+	if _, ok := userActions["pwn"]; ok {
+		t.Error("untrusted project defined an action")
+	}
+	if len(previewers) != 0 {
+		t.Errorf("previewers = %v, want none", previewers)
+	}
+	if len(defaultConfig.TrustedProjects) != 0 {
+		t.Errorf("trusted_projects = %q, set by the project itself", defaultConfig.TrustedProjects)
+	}
+	// Безопасные ключи применяются
+	if !defaultConfig.Hidden || !slices.Equal(appAssociations.TextEditor, CommandList{"idea"}) {
+		t.Errorf("hidden = %v, text_editor = %q; want the project values", defaultConfig.Hidden, appAssociations.TextEditor)
+	}
+}
+
+func TestTrustedProjectConfig(t *testing.T) {
+	trusted := t.TempDir()
+	project := filepath.Join(trusted, "repo")
+	if err := os.Mkdir(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	loadProject(t, project, "trusted_projects = ["+tomlString(trusted)+"]\n")
+
+	if defaultConfig.Source != "touch pwned; fd" {
+		t.Errorf("source = %q, want the value from the trusted project", defaultConfig.Source)
+	}
+	if _, ok := userActions["pwn"]; !ok {
+		t.Error("trusted project could not define an action")
+	}
+}
+
+// tomlString записывает строку в виде литерала toml
+func tomlString(s string) string {
+	return "'" + s + "'"
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// recentMode - флаг --recent: fzf показывает недавние файлы из
+// recently-used.xbel вместо файлов начального каталога
+var recentMode bool
+
+// recentFileName - общий для GTK и Qt список недавних файлов freedesktop
+const recentFileName = "recently-used.xbel"
+
+// recentTimeFormat - формат времени в recently-used.xbel, как у GLib
+const recentTimeFormat = "2006-01-02T15:04:05.000000Z"
+
+// xbelFile - содержимое recently-used.xbel. Закладки разбираются только до
+// атрибутов, а их содержимое переписывается как есть, чтобы не потерять
+// данные других приложений.
+type xbelFile struct {
+	XMLName   xml.Name       `xml:"xbel"`
+	Bookmarks []xbelBookmark `xml:"bookmark"`
+	// Other - элементы, кроме закладок; если они есть, файл не переписывается
+	Other []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+type xbelBookmark struct {
+	Href     string     `xml:"href,attr"`
+	Added    string     `xml:"added,attr"`
+	Modified string     `xml:"modified,attr"`
+	Visited  string     `xml:"visited,attr"`
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Inner    string     `xml:",innerxml"`
+}
+
+// recentFilePath возвращает путь к recently-used.xbel ($XDG_DATA_HOME)
+func recentFilePath() (string, error) {
+	dir, err := xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(dir), recentFileName), nil
+}
+
+// readRecentFile читает recently-used.xbel; отсутствующий файл - пустой список
+func readRecentFile(path string) (xbelFile, error) {
+	var f xbelFile
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return f, err
+	}
+	if err := xml.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// recordRecent добавляет открытый файл в recently-used.xbel (ключ
+// recent_files), чтобы его видели диалоги GTK и Qt. Ошибки только
+// записываются в лог: открытию файла они не мешают.
+func recordRecent(filePath string) {
+	if !defaultConfig.RecentFiles || kioskMode {
+		return
+	}
+	if err := addRecent(filePath); err != nil {
+		fmt.Fprintf(logOut, "Warning: could not update %s: %v\n", recentFileName, err)
+	}
+}
+
+// addRecent обновляет время посещения закладки файла или добавляет новую
+func addRecent(filePath string) error {
+	path, err := recentFilePath()
+	if err != nil {
+		return err
+	}
+	state, err := stateDir()
+	if err != nil {
+		return err
+	}
+	if err := ensurePrivateDir(state); err != nil {
+		return err
+	}
+
+	return withFileLock(filepath.Join(state, "recent"), func() error {
+		f, err := readRecentFile(path)
+		if err != nil {
+			return err
+		}
+		if len(f.Other) > 0 {
+			return fmt.Errorf("unexpected <%s> element", f.Other[0].XMLName.Local)
+		}
+
+		now := time.Now().UTC().Format(recentTimeFormat)
+		href := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filePath)}).String()
+		found := false
+		for i := range f.Bookmarks {
+			if f.Bookmarks[i].Href == href {
+				f.Bookmarks[i].Modified, f.Bookmarks[i].Visited = now, now
+				found = true
+			}
+		}
+		if !found {
+			f.Bookmarks = append(f.Bookmarks, xbelBookmark{
+				Href: href, Added: now, Modified: now, Visited: now,
+				Inner: recentMetadata(getMimeType(filePath), now),
+			})
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return err
+		}
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, encodeRecentFile(f), 0o600); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return nil
+	})
+}
+
+// recentMetadata - содержимое новой закладки: MIME-тип и запись о приложении
+func recentMetadata(mimeType, now string) string {
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return `
+    <info>
+      <metadata owner="http://freedesktop.org">
+        <mime:mime-type type="` + xmlAttr(mimeType) + `"/>
+        <bookmark:applications>
+          <bookmark:application name="fzf-open" exec="&apos;fzf-open %u&apos;" modified="` + now + `" count="1"/>
+        </bookmark:applications>
+      </metadata>
+    </info>
+  `
+}
+
+// encodeRecentFile собирает recently-used.xbel в том виде, в каком его пишет GLib
+func encodeRecentFile(f xbelFile) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<xbel version="1.0"
+      xmlns:bookmark="http://www.freedesktop.org/standards/desktop-bookmarks"
+      xmlns:mime="http://www.freedesktop.org/standards/shared-mime-info"
+>
+`)
+	for _, bm := range f.Bookmarks {
+		b.WriteString("  <bookmark")
+		for _, attr := range []xml.Attr{
+			{Name: xml.Name{Local: "href"}, Value: bm.Href},
+			{Name: xml.Name{Local: "added"}, Value: bm.Added},
+			{Name: xml.Name{Local: "modified"}, Value: bm.Modified},
+			{Name: xml.Name{Local: "visited"}, Value: bm.Visited},
+		} {
+			if attr.Value != "" {
+				fmt.Fprintf(&b, " %s=\"%s\"", attr.Name.Local, xmlAttr(attr.Value))
+			}
+		}
+		for _, attr := range bm.Attrs {
+			if attr.Name.Space == "" {
+				fmt.Fprintf(&b, " %s=\"%s\"", attr.Name.Local, xmlAttr(attr.Value))
+			}
+		}
+		b.WriteString(">")
+		b.WriteString(bm.Inner)
+		b.WriteString("</bookmark>\n")
+	}
+	b.WriteString("</xbel>\n")
+	return b.Bytes()
+}
+
+// xmlAttr экранирует значение атрибута XML
+func xmlAttr(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// recentFiles возвращает существующие локальные файлы из recently-used.xbel,
+// начиная с последних открытых
+func recentFiles() ([]string, error) {
+	path, err := recentFilePath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := readRecentFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Время в формате GLib сравнивается как строка
+	bookmarks := f.Bookmarks
+	sort.SliceStable(bookmarks, func(i, j int) bool {
+		return max(bookmarks[i].Visited, bookmarks[i].Modified) > max(bookmarks[j].Visited, bookmarks[j].Modified)
+	})
+
+	var files []string
+	for _, bm := range bookmarks {
+		u, err := url.Parse(bm.Href)
+		if err != nil || u.Scheme != "file" || (u.Host != "" && u.Host != "localhost") {
+			continue
+		}
+		file := filepath.FromSlash(u.Path)
+		if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// runRecent реализует подкоманду recent: печатает недавние файлы по одному в
+// строке. Её вызывает fzf в режиме --recent.
+func runRecent(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: fzf-open recent")
+		return 2
+	}
+	files, err := recentFiles()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	for _, file := range files {
+		fmt.Println(file)
+	}
+	return 0
+}
+
+// recentSourceCommand возвращает команду для FZF_DEFAULT_COMMAND в режиме --recent
+func recentSourceCommand() string {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(logOut, "Warning: --recent is unavailable: %v\n", err)
+		return ""
+	}
+	return shellQuote(exe) + " recent"
+}
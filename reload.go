@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Встроенные значения, к которым конфигурация сбрасывается перед перезагрузкой
+var (
+	builtinDefaultConfig   = defaultConfig
+	builtinAppAssociations = appAssociations
+)
+
+// loadedConfigFiles - файлы, из которых собрана текущая конфигурация
+// (основной, включённые и файл проекта); за ними следит configWatcher
+var loadedConfigFiles []string
+
+// configSnapshot - состояние глобальной конфигурации для отката неудачной перезагрузки
+type configSnapshot struct {
+	defaultConfig       DefaultConfig
+	appAssociations     AppAssociations
+	associationTimeouts map[string]time.Duration
+	tuiAssociations     map[string]bool
+	multiAssociations   map[string]bool
+	ignorePatterns      []string
+	extensionRules      map[string]string
+	mimeRules           map[string]string
+	extensionMIMETypes  map[string]string
+	filenameRules       []filenameRule
+	selectionFilters    []selectionFilter
+	fzfOptions          map[string]any
+	userActions         map[string]ActionConfig
+	converters          map[string]ConverterConfig
+	previewers          map[string]string
+	packagingHelpers    map[string]PackagingConfig
+	keyBindings         map[string]string
+	layouts             map[string]LayoutConfig
+	loadedConfigFiles   []string
+	cfg                 Config
+}
+
+func takeConfigSnapshot(cfg *Config) configSnapshot {
+	return configSnapshot{
+		defaultConfig:       defaultConfig,
+		appAssociations:     appAssociations,
+		associationTimeouts: associationTimeouts,
+		tuiAssociations:     tuiAssociations,
+		multiAssociations:   multiAssociations,
+		ignorePatterns:      ignorePatterns,
+		extensionRules:      extensionRules,
+		mimeRules:           mimeRules,
+		extensionMIMETypes:  extensionMIMETypes,
+		filenameRules:       filenameRules,
+		selectionFilters:    selectionFilters,
+		fzfOptions:          fzfOptions,
+		userActions:         userActions,
+		converters:          converters,
+		previewers:          previewers,
+		packagingHelpers:    packagingHelpers,
+		keyBindings:         keyBindings,
+		layouts:             layouts,
+		loadedConfigFiles:   loadedConfigFiles,
+		cfg:                 *cfg,
+	}
+}
+
+func (s configSnapshot) restore(cfg *Config) {
+	defaultConfig = s.defaultConfig
+	appAssociations = s.appAssociations
+	associationTimeouts = s.associationTimeouts
+	tuiAssociations = s.tuiAssociations
+	multiAssociations = s.multiAssociations
+	ignorePatterns = s.ignorePatterns
+	extensionRules = s.extensionRules
+	mimeRules = s.mimeRules
+	extensionMIMETypes = s.extensionMIMETypes
+	filenameRules = s.filenameRules
+	selectionFilters = s.selectionFilters
+	fzfOptions = s.fzfOptions
+	userActions = s.userActions
+	converters = s.converters
+	previewers = s.previewers
+	packagingHelpers = s.packagingHelpers
+	keyBindings = s.keyBindings
+	layouts = s.layouts
+	loadedConfigFiles = s.loadedConfigFiles
+	*cfg = s.cfg
+}
+
+// resetConfig возвращает глобальную конфигурацию к встроенным значениям.
+// Определённая при запуске оболочка сохраняется.
+func resetConfig() {
+	// Оболочку определяет фоновая горутина init - дождёмся её
+	shellDetectOnce.Do(detectUserShell)
+	shell := defaultConfig.ShellToUse
+	defaultConfig = builtinDefaultConfig
+	defaultConfig.ShellToUse = shell
+
+	appAssociations = builtinAppAssociations
+	associationTimeouts = map[string]time.Duration{}
+	tuiAssociations = map[string]bool{}
+	multiAssociations = defaultMultiAssociations()
+	ignorePatterns = nil
+	extensionRules = map[string]string{}
+	mimeRules = map[string]string{}
+	extensionMIMETypes = map[string]string{}
+	filenameRules = nil
+	selectionFilters = nil
+	fzfOptions = map[string]any{}
+	userActions = map[string]ActionConfig{}
+	converters = map[string]ConverterConfig{}
+	previewers = map[string]string{}
+	packagingHelpers = defaultPackagingHelpers()
+	keyBindings = defaultKeyBindings()
+	layouts = map[string]LayoutConfig{}
+	loadedConfigFiles = nil
+}
+
+// reloadConfig заново читает конфигурацию. При ошибке остаётся прежняя.
+func reloadConfig(cfg *Config) error {
+	snapshot := takeConfigSnapshot(cfg)
+	resetConfig()
+
+	if err := setupConfig(cfg); err != nil {
+		snapshot.restore(cfg)
+		return err
+	}
+	return nil
+}
+
+// configWatcher следит за файлами конфигурации в постоянных режимах (--loop)
+type configWatcher struct {
+	watcher *fsnotify.Watcher
+	changed atomic.Bool
+
+	mu    sync.Mutex
+	files map[string]bool
+	dirs  map[string]bool
+}
+
+// startConfigWatcher начинает следить за loadedConfigFiles
+func startConfigWatcher() (*configWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &configWatcher{
+		watcher: watcher,
+		files:   make(map[string]bool),
+		dirs:    make(map[string]bool),
+	}
+	w.track(loadedConfigFiles)
+	go w.run()
+	return w, nil
+}
+
+// track добавляет файлы к отслеживаемым. Следим за каталогами, а не за самими
+// файлами: редакторы часто сохраняют через запись во временный файл и rename.
+func (w *configWatcher) track(files []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, file := range files {
+		w.files[file] = true
+		dir := filepath.Dir(file)
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.watcher.Add(dir); err == nil {
+			w.dirs[dir] = true
+		}
+	}
+}
+
+func (w *configWatcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) &&
+				!event.Has(fsnotify.Rename) && !event.Has(fsnotify.Remove) {
+				continue
+			}
+			w.mu.Lock()
+			relevant := w.files[filepath.Clean(event.Name)]
+			w.mu.Unlock()
+			if relevant {
+				w.changed.Store(true)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(logOut, "Warning: config watcher: %v\n", err)
+		}
+	}
+}
+
+// reloadIfChanged перезагружает конфигурацию, если её файлы изменились с
+// прошлого вызова или с power_saver = "auto" сменился источник питания
+func (w *configWatcher) reloadIfChanged(cfg *Config) {
+	if changed := w.changed.Swap(false); !changed && !powerSupplyChanged() {
+		return
+	}
+
+	if err := reloadConfig(cfg); err != nil {
+		fmt.Fprintf(logOut, "Warning: configuration reload failed, keeping the previous one: %v\n", err)
+		return
+	}
+	w.track(loadedConfigFiles)
+	fmt.Fprintf(logOut, "Info: configuration reloaded\n")
+}
+
+// Close прекращает наблюдение
+func (w *configWatcher) Close() error {
+	return w.watcher.Close()
+}
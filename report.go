@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportLogLines - сколько последних строк лога включать в отчёт
+const reportLogLines = 50
+
+// secretKeyPattern - имена параметров и переменных, значения которых скрываются в отчёте
+var secretKeyPattern = regexp.MustCompile(`(?i)(token|secret|passw|api[_-]?key|auth|cookie|credential)`)
+
+// secretArgPatterns - секреты в тексте команд: первая группа сохраняется,
+// вторая скрывается. Это аргументы вида --token=... и --password ..., заголовки
+// вида -H "Authorization: Bearer ..." и параметры вида token=... в адресах.
+var secretArgPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)((?:proxy-)?authorization:\s*(?:(?:bearer|basic|token)\s+)?|(?:cookie|[\w-]*(?:token|secret|passw|api-?key|auth)[\w-]*):\s*)([^\s"']+)`),
+	regexp.MustCompile(`(?i)(--?[\w-]*(?:token|secret|passw|key|auth)[\w-]*[= ])(\S+)`),
+	regexp.MustCompile(`(?i)([\w-]*(?:token|secret|passw|api[_-]?key|auth)[\w-]*=)([^\s&"']+)`),
+}
+
+// reportEnvVars - переменные окружения, влияющие на работу утилиты
+var reportEnvVars = []string{
+	"SHELL", "TERM", "DISPLAY", "WAYLAND_DISPLAY", "XDG_SESSION_TYPE", "XDG_CURRENT_DESKTOP",
+	"XDG_CONFIG_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME", "XDG_RUNTIME_DIR",
+	"FZF_DEFAULT_COMMAND", "FZF_DEFAULT_OPTS",
+}
+
+// runReport реализует подкоманду report: собирает локальный диагностический отчёт
+// для приложения к баг-репорту. Отчёт никуда не отправляется.
+func runReport(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	output := fs.String("o", "", "Write the report to a file instead of stdout")
+	profile := fs.String("p", "", "Configuration profile to report on")
+	fs.Parse(args)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		path, err := expandPath(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error expanding report path %q: %v\n", *output, err)
+			return 1
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating report file %q: %v\n", path, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	writeReport(w, *profile)
+
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Report written to %q. Review it before sharing.\n", *output)
+	}
+	return 0
+}
+
+// writeReport пишет все разделы отчёта
+func writeReport(w io.Writer, profile string) {
+	fmt.Fprintf(w, "fzf-open diagnostic report\n")
+	fmt.Fprintf(w, "Generated locally at %s. Nothing has been uploaded; review before sharing.\n",
+		time.Now().Format(time.RFC3339))
+
+	reportSection(w, "Versions")
+	fmt.Fprintf(w, "fzf-open: %s\n", buildVersion())
+	fmt.Fprintf(w, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if finderName() == finderBuiltin {
+		fmt.Fprintf(w, "%s: fzf-open select %s\n", finderBuiltin, buildVersion())
+	} else {
+		fmt.Fprintf(w, "%s: %s\n", finderName(), toolVersion(finderName(), "--version"))
+	}
+	fmt.Fprintf(w, "xdg-mime: %s\n", toolVersion("xdg-mime", "--version"))
+
+	reportSection(w, "Environment")
+	shellDetectOnce.Do(detectUserShell)
+	fmt.Fprintf(w, "detected shell: %s\n", defaultConfig.ShellToUse)
+	for _, name := range reportEnvVars {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			value = "(unset)"
+		}
+		fmt.Fprintf(w, "%s=%s\n", name, redact(name, value))
+	}
+
+	reportSection(w, "Configuration")
+	if path, err := configPath(); err == nil {
+		fmt.Fprintf(w, "config file: %s\n", redactHome(path))
+	}
+	if profile != "" {
+		fmt.Fprintf(w, "profile: %s\n", profile)
+	}
+	if err := loadConfig(profile); err != nil {
+		fmt.Fprintf(w, "config error: %s\n", redactText(err.Error()))
+	}
+	writeStructFields(w, defaultConfig)
+	writeStructFields(w, appAssociations)
+	writeConfigTable(w, "rules", configuredFilenameRules())
+	filters := make([]SelectionFilter, len(selectionFilters))
+	for i, f := range selectionFilters {
+		filters[i] = f.SelectionFilter
+	}
+	writeConfigTable(w, "selection_filters", filters)
+	writeConfigTable(w, "actions", userActions)
+	writeConfigTable(w, "converters", converters)
+	writeConfigTable(w, "previewers", previewers)
+	writeConfigTable(w, "packaging", packagingHelpers)
+	writeConfigTable(w, "keys", keyBindings)
+	writeConfigTable(w, "layouts", layouts)
+
+	reportSection(w, "Applications")
+	for _, app := range configuredApps() {
+		path, err := exec.LookPath(app)
+		if err != nil {
+			path = "NOT FOUND"
+		}
+		fmt.Fprintf(w, "%s: %s\n", app, redactHome(path))
+	}
+
+	reportSection(w, fmt.Sprintf("Log (last %d lines)", reportLogLines))
+	lines, err := tailLog(reportLogLines)
+	if err != nil {
+		fmt.Fprintf(w, "(no log available: %v)\n", redactText(err.Error()))
+		return
+	}
+	for _, line := range lines {
+		fmt.Fprintln(w, redactText(line))
+	}
+}
+
+func reportSection(w io.Writer, title string) {
+	fmt.Fprintf(w, "\n== %s ==\n", title)
+}
+
+// writeStructFields печатает экспортируемые строковые поля структуры с маскировкой секретов
+func writeStructFields(w io.Writer, v any) {
+	rv := reflect.ValueOf(v)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fmt.Fprintf(w, "%s = %s\n", field.Name, redact(field.Name, fmt.Sprint(rv.Field(i).Interface())))
+	}
+}
+
+// writeConfigTable печатает таблицу конфигурации table - карту по ключам или
+// срез по порядку - с маскировкой секретов: поля структур строками
+// table.ключ.Поле, остальные значения строками table.ключ
+func writeConfigTable(w io.Writer, table string, v any) {
+	rv := reflect.ValueOf(v)
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	var entries []entry
+	switch rv.Kind() {
+	case reflect.Map:
+		for _, key := range rv.MapKeys() {
+			entries = append(entries, entry{fmt.Sprint(key.Interface()), rv.MapIndex(key)})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	case reflect.Slice:
+		for i := 0; i < rv.Len(); i++ {
+			entries = append(entries, entry{strconv.Itoa(i), rv.Index(i)})
+		}
+	}
+
+	for _, e := range entries {
+		name := table + "." + e.key
+		if e.value.Kind() != reflect.Struct {
+			fmt.Fprintf(w, "%s = %s\n", name, redact(e.key, fmt.Sprint(e.value.Interface())))
+			continue
+		}
+		rt := e.value.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			if field := rt.Field(i); field.IsExported() {
+				value := fmt.Sprint(e.value.Field(i).Interface())
+				fmt.Fprintf(w, "%s.%s = %s\n", name, field.Name, redact(e.key+field.Name, value))
+			}
+		}
+	}
+}
+
+// configuredApps возвращает уникальные исполняемые файлы из всех ассоциаций и терминала
+func configuredApps() []string {
+	seen := make(map[string]struct{})
+	apps := make([]string, 0, 10)
+
+	add := func(command string) {
+		parts := strings.Fields(command)
+		if len(parts) == 0 {
+			return
+		}
+		if _, ok := seen[parts[0]]; ok {
+			return
+		}
+		seen[parts[0]] = struct{}{}
+		apps = append(apps, parts[0])
+	}
+
+	add(defaultConfig.Terminal)
+	if finderName() != finderBuiltin {
+		add(finderCommand())
+	}
+	rv := reflect.ValueOf(appAssociations)
+	for i := 0; i < rv.NumField(); i++ {
+		for _, command := range rv.Field(i).Interface().(CommandList) {
+			add(command)
+		}
+	}
+	return apps
+}
+
+// redact скрывает значение, если имя похоже на секрет, и маскирует секреты в аргументах команд
+func redact(name string, value string) string {
+	if value == "" || value == "(unset)" {
+		return value
+	}
+	if secretKeyPattern.MatchString(name) {
+		return "[redacted]"
+	}
+	return redactText(value)
+}
+
+// redactText маскирует секреты в аргументах команд и домашний каталог в тексте
+// без имени ключа: строках лога и сообщениях об ошибках
+func redactText(s string) string {
+	for _, pattern := range secretArgPatterns {
+		s = pattern.ReplaceAllString(s, "${1}[redacted]")
+	}
+	return redactHome(s)
+}
+
+// redactHome заменяет домашний каталог на ~, чтобы не раскрывать имя
+// пользователя. Заменяется только путь целиком: /home/me не трогает /home/meow.
+func redactHome(s string) string {
+	if userHomeDir == "" || userHomeDir == "/" {
+		return s
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, userHomeDir)
+		if i < 0 {
+			break
+		}
+		end := i + len(userHomeDir)
+		b.WriteString(s[:i])
+		if (i == 0 || !pathNameChar(s[i-1])) && (end == len(s) || !pathNameChar(s[end])) {
+			b.WriteString("~")
+		} else {
+			b.WriteString(userHomeDir)
+		}
+		s = s[end:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// pathNameChar сообщает, что байт может продолжать имя каталога: граница
+// пути - разделитель, пробел, кавычка или конец строки
+func pathNameChar(c byte) bool {
+	return c == '.' || c == '-' || c == '_' || c >= 0x80 ||
+		'0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// buildVersion возвращает версию из ldflags или из информации о сборке модуля
+func buildVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
+
+// toolVersion возвращает первую строку вывода `name args...` или причину неудачи
+func toolVersion(name string, args ...string) string {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "not found"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	out, err := execCommand(ctx, "probe", path, args...).Output()
+	if err != nil {
+		return fmt.Sprintf("error (%v)", err)
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return line
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactText(t *testing.T) {
+	old := userHomeDir
+	userHomeDir = "/home/me"
+	t.Cleanup(func() { userHomeDir = old })
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`Info: opening "/home/me/notes.md"`, `Info: opening "~/notes.md"`},
+		{`Error: "sync --token=abc123 /home/me/x" failed`, `Error: "sync --token=[redacted] ~/x" failed`},
+		{`Warning: mycli --password hunter2 exited`, `Warning: mycli --password [redacted] exited`},
+		{`Warning: mycli -api-key=k exited`, `Warning: mycli -api-key=[redacted] exited`},
+		// Без имени ключа слова "token" и "secret" в тексте не скрываются
+		{`Info: token file is /home/me/.secret`, `Info: token file is ~/.secret`},
+		{`Error: curl -H "Authorization: Bearer abc.def" exited`, `Error: curl -H "Authorization: Bearer [redacted]" exited`},
+		{`Error: curl -H 'X-Api-Key: k1' exited`, `Error: curl -H 'X-Api-Key: [redacted]' exited`},
+		{`Info: fetching https://x.org/f?access_token=abc&page=2`, `Info: fetching https://x.org/f?access_token=[redacted]&page=2`},
+		// Домашний каталог заменяется только целым путём
+		{`Info: opening /home/meow/a /home/me`, `Info: opening /home/meow/a ~`},
+		{`Info: opening /srv/home/me/a "/home/me"`, `Info: opening /srv/home/me/a "~"`},
+	}
+	for _, tt := range tests {
+		if got := redactText(tt.line); got != tt.want {
+			t.Errorf("redactText(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestRedactKeepsKeyCheck(t *testing.T) {
+	if got := redact("APIToken", "plain-value"); got != "[redacted]" {
+		t.Errorf("redact(APIToken) = %q, want [redacted]", got)
+	}
+	if got := redact("Terminal", "foot --auth=x"); got != "foot --auth=[redacted]" {
+		t.Errorf("redact(Terminal) = %q", got)
+	}
+}
+
+func TestWriteConfigTableRedacts(t *testing.T) {
+	var b strings.Builder
+	writeConfigTable(&b, "actions", map[string]ActionConfig{
+		"upload": {Key: "ctrl-u", Command: `curl -H "Authorization: Bearer abc" -T {file} https://x.org`},
+	})
+	writeConfigTable(&b, "previewers", map[string]string{"text/*": "bat --token=abc {file}"})
+	writeConfigTable(&b, "selection_filters", []SelectionFilter{{Command: "sync --password hunter2"}})
+
+	got := b.String()
+	for _, secret := range []string{"abc", "hunter2"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("report leaks %q:\n%s", secret, got)
+		}
+	}
+	for _, line := range []string{
+		"actions.upload.Key = ctrl-u\n",
+		"previewers.text/* = bat --token=[redacted] {file}\n",
+		"selection_filters.0.Command = sync --password [redacted]\n",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("report has no line %q:\n%s", line, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resumeFileName - файл состояния с каталогом и запросом последнего выбора
+const resumeFileName = "resume"
+
+// resumeState - где закончился последний выбор: каталог списка (корень
+// навигации, если по каталогам ходили) и запрос, если включён resume_query
+type resumeState struct {
+	dir   string
+	query string
+}
+
+// resumeFilePath возвращает путь файла $XDG_STATE_HOME/fzf-open/resume
+func resumeFilePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, resumeFileName), nil
+}
+
+// readResume читает сохранённое состояние; ok == false - его нет или каталог
+// уже не существует
+func readResume() (state resumeState, ok bool) {
+	path, err := resumeFilePath()
+	if err != nil {
+		return resumeState{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return resumeState{}, false
+	}
+	dir, query, _ := strings.Cut(strings.TrimSuffix(string(data), "\n"), "\n")
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() || !filepath.IsAbs(dir) {
+		return resumeState{}, false
+	}
+	return resumeState{dir: dir, query: query}, true
+}
+
+// saveResume запоминает каталог и запрос сессии fzf для -r. Ошибки
+// записываются в лог и не мешают открытию файла.
+func saveResume(result pickResult) {
+	dir, err := filepath.Abs(result.Dir)
+	if result.Dir == "" || err != nil {
+		return
+	}
+	path, err := resumeFilePath()
+	if err != nil {
+		return
+	}
+	query := ""
+	if defaultConfig.ResumeQuery {
+		// Запрос в файле - одна строка
+		query = strings.ReplaceAll(result.Query, "\n", " ")
+	}
+	if err := ensureDir(filepath.Dir(path)); err != nil {
+		fmt.Fprintf(logOut, "Warning: could not save the last directory: %v\n", err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(dir+"\n"+query+"\n"), 0o600); err != nil {
+		fmt.Fprintf(logOut, "Warning: could not save the last directory: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		fmt.Fprintf(logOut, "Warning: could not save the last directory: %v\n", err)
+	}
+}
+
+// applyResume начинает выбор там, где закончился прошлый (флаг -r): в его
+// каталоге и, с resume_query, с его запросом. Каталог из -d и запрос из -q
+// важнее сохранённых.
+func applyResume(cfg *Config) {
+	state, ok := readResume()
+	if !ok {
+		fmt.Fprintln(logOut, "Info: nothing to resume, starting in the usual directory")
+		return
+	}
+	if !cfg.explicitFlags["d"] {
+		cfg.StartingDir = state.dir
+	}
+	if defaultConfig.ResumeQuery && cfg.Query == "" {
+		cfg.Query = state.query
+	}
+}
+
+// addResumeQuery просит fzf напечатать запрос, чтобы -r мог его восстановить
+func addResumeQuery(opts *pickerOptions) {
+	if defaultConfig.ResumeQuery {
+		opts.PrintQuery = true
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FilenameRule - правило из [[rules]]: шаблон имени файла (glob) или регулярное
+// выражение для полного пути (regex), MIME-тип и границы размера файла ->
+// ключ ассоциации или команда (open). Условия, которые заданы, должны
+// выполняться все.
+type FilenameRule struct {
+	Glob    string `toml:"glob,omitempty"`
+	Regex   string `toml:"regex,omitempty"`
+	MIME    string `toml:"mime,omitempty"`
+	MinSize string `toml:"min_size,omitempty"`
+	MaxSize string `toml:"max_size,omitempty"`
+	Open    string `toml:"open"`
+
+	// Terminal - команда из open работает в терминале, как приложения из [tui]
+	Terminal bool `toml:"terminal,omitempty"`
+	// Backup - перед открытием скопировать файл в каталог резервных копий
+	Backup bool `toml:"backup,omitempty"`
+}
+
+// filenameRule - проверенное правило с откомпилированным выражением и
+// разобранными границами размера (0 - граница не задана)
+type filenameRule struct {
+	FilenameRule
+	re               *regexp.Regexp
+	minSize, maxSize int64
+}
+
+// sizeUnits - множители суффиксов размера; K, M и G - степени 1024, как в du и less
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+// parseSize разбирает размер вида "512", "100K", "10MB" или "1.5GiB"
+func parseSize(s string) (int64, error) {
+	number := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// filenameRules проверяются раньше всех правил по расширению и MIME-типу.
+// Побеждает первое подходящее; правила профиля и проекта стоят впереди
+// правил, загруженных раньше.
+var filenameRules []filenameRule
+
+// extensionRules - пользовательские правила из таблицы [extensions]: расширение
+// (без точки, в нижнем регистре) -> ключ ассоциации или команда. Проверяются
+// раньше встроенных extTo*.
+var extensionRules = map[string]string{}
+
+// mimeRules - пользовательские правила из таблицы [mime]: MIME-тип или шаблон
+// вида "image/*" -> ключ ассоциации или команда. Проверяются раньше встроенных
+// правил getAssociationByMIME.
+var mimeRules = map[string]string{}
+
+// extensionMIMETypes - MIME-типы расширений из таблицы [mime_types]: расширение
+// (без точки, в нижнем регистре) -> MIME-тип. Такой файл классифицируется по
+// MIME-типу ([mime] и встроенные правила), а не по встроенным таблицам расширений.
+var extensionMIMETypes = map[string]string{}
+
+// addRules возвращает копию dst, дополненную правилами rules, ключи которых
+// приведены функцией normalize
+func addRules(dst map[string]string, rules map[string]string, normalize func(string) string) map[string]string {
+	if len(rules) == 0 {
+		return dst
+	}
+
+	merged := make(map[string]string, len(dst)+len(rules))
+	for key, target := range dst {
+		merged[key] = target
+	}
+	for key, target := range rules {
+		merged[normalize(key)] = target
+	}
+	return merged
+}
+
+// addMIMETypes возвращает копию dst, дополненную таблицей [mime_types]
+func addMIMETypes(dst map[string]string, types map[string]string) map[string]string {
+	if len(types) == 0 {
+		return dst
+	}
+
+	merged := make(map[string]string, len(dst)+len(types))
+	for ext, mimeType := range dst {
+		merged[ext] = mimeType
+	}
+	for ext, mimeType := range types {
+		merged[normalizeExtension(ext)] = normalizeMIME(mimeType)
+	}
+	return merged
+}
+
+// checkMIMEType проверяет строку таблицы [mime_types]
+func checkMIMEType(ext, mimeType string) error {
+	major, minor, ok := strings.Cut(strings.TrimSpace(mimeType), "/")
+	if normalizeExtension(ext) == "" || !ok || major == "" || minor == "" || strings.ContainsAny(mimeType, "*; ") {
+		return fmt.Errorf("mime_types: %q = %q: expected an extension and a MIME type such as \"application/wasm\"", ext, mimeType)
+	}
+	return nil
+}
+
+// normalizeExtension приводит расширение к виду ключей extensionRules
+func normalizeExtension(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+// normalizeMIME приводит MIME-тип к виду ключей mimeRules
+func normalizeMIME(mimeType string) string {
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// mimeRule возвращает цель пользовательского правила для MIME-типа: сначала
+// ищется точное совпадение, затем шаблон "тип/*"
+func mimeRule(mimeType string) string {
+	if mimeType == "" || len(mimeRules) == 0 {
+		return ""
+	}
+
+	mimeType = normalizeMIME(mimeType)
+	if target, ok := mimeRules[mimeType]; ok {
+		return target
+	}
+	if major, _, ok := strings.Cut(mimeType, "/"); ok {
+		return mimeRules[major+"/*"]
+	}
+	return ""
+}
+
+// addFilenameRules проверяет правила и ставит их перед уже загруженными
+func addFilenameRules(rules []FilenameRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	compiled := make([]filenameRule, 0, len(rules)+len(filenameRules))
+	for i, r := range rules {
+		if r.Open == "" {
+			return fmt.Errorf("rules[%d]: open is empty", i)
+		}
+
+		c := filenameRule{FilenameRule: r}
+		switch {
+		case r.Glob != "" && r.Regex != "":
+			return fmt.Errorf("rules[%d]: set either glob or regex, not both", i)
+		case r.Glob != "":
+			if _, err := filepath.Match(r.Glob, ""); err != nil {
+				return fmt.Errorf("rules[%d]: invalid glob %q: %w", i, r.Glob, err)
+			}
+		case r.Regex != "":
+			re, err := regexp.Compile(r.Regex)
+			if err != nil {
+				return fmt.Errorf("rules[%d]: invalid regex: %w", i, err)
+			}
+			c.re = re
+		case r.MIME == "" && r.MinSize == "" && r.MaxSize == "":
+			return fmt.Errorf("rules[%d]: glob, regex, mime or a size limit is required", i)
+		}
+
+		var err error
+		if r.MinSize != "" {
+			if c.minSize, err = parseSize(r.MinSize); err != nil {
+				return fmt.Errorf("rules[%d]: min_size: %w", i, err)
+			}
+		}
+		if r.MaxSize != "" {
+			if c.maxSize, err = parseSize(r.MaxSize); err != nil {
+				return fmt.Errorf("rules[%d]: max_size: %w", i, err)
+			}
+		}
+		compiled = append(compiled, c)
+	}
+
+	filenameRules = append(compiled, filenameRules...)
+	return nil
+}
+
+// filenameRuleFor возвращает первое правило, подходящее к файлу. Размер и
+// MIME-тип определяются, только если их проверяет какое-нибудь правило.
+func filenameRuleFor(filePath string) (FilenameRule, bool) {
+	name := filepath.Base(filePath)
+	size := int64(-1)
+	mimeType, mimeKnown := "", false
+
+	for _, r := range filenameRules {
+		if r.re != nil && !r.re.MatchString(filePath) {
+			continue
+		}
+		if r.Glob != "" {
+			if ok, _ := filepath.Match(r.Glob, name); !ok {
+				continue
+			}
+		}
+		if r.minSize > 0 || r.maxSize > 0 {
+			if size < 0 {
+				fi, err := os.Stat(filePath)
+				if err != nil {
+					continue
+				}
+				size = fi.Size()
+			}
+			if size < r.minSize || (r.maxSize > 0 && size > r.maxSize) {
+				continue
+			}
+		}
+		if r.MIME != "" {
+			if !mimeKnown {
+				mimeType, mimeKnown = getMimeType(filePath), true
+			}
+			if !mimeMatches(r.MIME, mimeType) {
+				continue
+			}
+		}
+		return r.FilenameRule, true
+	}
+	return FilenameRule{}, false
+}
+
+// mimeMatches сообщает, подходит ли MIME-тип к шаблону: точному типу или "тип/*"
+func mimeMatches(pattern, mimeType string) bool {
+	if mimeType == "" {
+		return false
+	}
+	pattern, mimeType = normalizeMIME(pattern), normalizeMIME(mimeType)
+	if major, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mimeType, major+"/")
+	}
+	return pattern == mimeType
+}
+
+// configuredFilenameRules возвращает действующие правила в виде для конфигурации
+func configuredFilenameRules() []FilenameRule {
+	rules := make([]FilenameRule, len(filenameRules))
+	for i, r := range filenameRules {
+		rules[i] = r.FilenameRule
+	}
+	return rules
+}
+
+// ruleSpecs возвращает кандидатов для цели правила: если это ключ ассоциации
+// (например, "image_viewer"), берётся её цепочка команд, иначе цель считается командой
+func ruleSpecs(target string) []launchSpec {
+	if specs := associationSpecs(target); len(specs) > 0 {
+		return specs
+	}
+	return []launchSpec{{Command: target}}
+}
+
+// filenameRuleSpecs возвращает кандидатов для правила из [[rules]]
+func filenameRuleSpecs(r FilenameRule) []launchSpec {
+	specs := ruleSpecs(r.Open)
+	if r.Terminal {
+		for i := range specs {
+			specs[i].TUI = true
+		}
+	}
+	return specs
+}
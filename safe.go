@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// safeMode - флаг --safe: не запускаются шелл, xdg-mime, mailcap, действия,
+// конвертеры и превью; fzf и итоговое приложение запускаются напрямую
+var safeMode bool
+
+// runFzfDirect запускает fzf без шелла в каталоге dir и возвращает его вывод.
+// fzf_command разбирается на слова как в шелле, но конвейеры,
+// перенаправления и переменные в нём недопустимы.
+func runFzfDirect(ctx context.Context, dir string, fzfArgs []string) ([]byte, error) {
+	argv, err := splitShellWords(finderCommand())
+	if err != nil {
+		return nil, fmt.Errorf("fzf_command in --safe mode: %w", err)
+	}
+	if len(argv) == 0 {
+		return nil, errors.New("fzf_command is empty")
+	}
+
+	var out bytes.Buffer
+
+	argv = append(argv, fzfArgs...)
+	cmd := execCommand(ctx, "picker", argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Env = fzfEnv()
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if usingFzf() && !frecencyRanked() && !sortedListing() && !newListDecorator().enabled() {
+		err := cmd.Run()
+		return out.Bytes(), err
+	}
+
+	// У других программ выбора нет встроенного обходчика, а обходчик fzf не
+	// ставит вперёд файлы из базы frecency, не сортирует и не раскрашивает их: список
+	// строит обходчик fzf-open и подаёт на стандартный ввод
+	cmd.Stdin = nil
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		w := bufio.NewWriter(stdin)
+		writeDirectList(w, dir)
+		w.Flush()
+		stdin.Close()
+	}()
+	err = cmd.Wait()
+	return out.Bytes(), err
+}
+
+// writeDirectList пишет список файлов dir для программы выбора, запущенной без
+// шелла: сначала файлы из базы frecency, как подкоманда rank, затем остальные
+// в порядке встроенного обходчика. С colors, icons и --details строки
+// оформлены, как у colorize.
+func writeDirectList(w *bufio.Writer, dir string) {
+	sep := byte('\n')
+	if nulSeparated() {
+		sep = 0
+	}
+	d := newListDecorator()
+	d.base = dir
+	write := func(rel string) {
+		line := rel
+		if d.enabled() {
+			line = d.decorate(rel)
+		}
+		w.WriteString(line)
+		w.WriteByte(sep)
+	}
+	printed := map[string]bool{}
+	if frecencyRanked() {
+		for _, rel := range frecentFilesIn(dir) {
+			write(rel)
+			printed[rel] = true
+		}
+		// Привычные файлы видны сразу, до конца обхода
+		w.Flush()
+	}
+	opts := walkOptions{hidden: defaultConfig.Hidden, noIgnore: defaultConfig.NoIgnore, dirs: dirsMode, exclude: ignorePatterns}
+	if sortedListing() {
+		opts.sort = sortOrder
+	}
+	walkSorted(dir, opts, func(rel string) {
+		if !printed[rel] {
+			write(rel)
+		}
+	})
+}
+
+// splitShellWords разбивает команду на слова с учётом одинарных и двойных
+// кавычек и обратной косой черты. Конструкции, которым нужен шелл (|, ;, &,
+// <, >, $, `), считаются ошибкой.
+func splitShellWords(command string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	var quote byte
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				cur.WriteByte(c)
+			}
+		case quote == '"':
+			switch {
+			case c == '"':
+				quote = 0
+			case c == '\\' && i+1 < len(command) && strings.IndexByte(`"\$`+"`", command[i+1]) >= 0:
+				i++
+				cur.WriteByte(command[i])
+			case c == '$' || c == '`':
+				return nil, fmt.Errorf("%q needs a shell", command)
+			default:
+				cur.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == '\\' && i+1 < len(command):
+			i++
+			cur.WriteByte(command[i])
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		case strings.IndexByte("|;&<>$`", c) >= 0:
+			return nil, fmt.Errorf("%q needs a shell", command)
+		default:
+			cur.WriteByte(c)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", command)
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// selectionFilterTimeout ограничивает работу внешнего фильтра выбора
+const selectionFilterTimeout = 5 * time.Second
+
+// SelectionFilter - фильтр из [[selection_filters]]: строки, выбранные в fzf,
+// переписываются до разрешения путей. Regex и Replace задают замену по
+// регулярному выражению ($1 и ${name} в Replace - группы), Command - команду
+// шелла, которая читает строки из stdin и печатает результат построчно (или
+// через NUL, если так разделён список fzf).
+type SelectionFilter struct {
+	Regex   string `toml:"regex,omitempty"`
+	Replace string `toml:"replace,omitempty"`
+	Command string `toml:"command,omitempty"`
+}
+
+// selectionFilter - проверенный фильтр с откомпилированным выражением
+type selectionFilter struct {
+	SelectionFilter
+	re *regexp.Regexp
+}
+
+// selectionFilters применяются по порядку загрузки: глобальные, затем фильтры
+// профиля и проекта
+var selectionFilters []selectionFilter
+
+// addSelectionFilters проверяет фильтры и добавляет их после уже загруженных
+func addSelectionFilters(filters []SelectionFilter) error {
+	for i, f := range filters {
+		switch {
+		case f.Regex != "" && f.Command != "":
+			return fmt.Errorf("selection_filters[%d]: set either regex or command, not both", i)
+		case f.Regex != "":
+			re, err := regexp.Compile(f.Regex)
+			if err != nil {
+				return fmt.Errorf("selection_filters[%d]: invalid regex: %w", i, err)
+			}
+			selectionFilters = append(selectionFilters, selectionFilter{SelectionFilter: f, re: re})
+		case f.Command != "":
+			selectionFilters = append(selectionFilters, selectionFilter{SelectionFilter: f})
+		default:
+			return fmt.Errorf("selection_filters[%d]: regex or command is required", i)
+		}
+	}
+	return nil
+}
+
+// filterSelections пропускает выбранные строки через фильтры; null - строки
+// разделены NUL (--print0). Ошибка внешнего фильтра не фатальна: строки
+// передаются дальше без его изменений.
+func filterSelections(ctx context.Context, selections []string, null bool) []string {
+	for _, f := range selectionFilters {
+		if f.re != nil {
+			for i, selection := range selections {
+				selections[i] = f.re.ReplaceAllString(selection, f.Replace)
+			}
+			continue
+		}
+
+		if safeMode {
+			fmt.Fprintf(logOut, "Warning: selection filter %q is skipped in --safe mode\n", f.Command)
+			continue
+		}
+		filtered, err := runSelectionFilter(ctx, f.Command, selections, null)
+		if err != nil {
+			fmt.Fprintf(logOut, "Warning: selection filter %q failed, using the selection as is: %v\n", f.Command, err)
+			continue
+		}
+		selections = filtered
+	}
+
+	// Пробелы на концах могут быть частью имени файла и не обрезаются
+	result := selections[:0]
+	for _, selection := range selections {
+		if strings.TrimSpace(selection) != "" {
+			result = append(result, selection)
+		}
+	}
+	return result
+}
+
+// runSelectionFilter передаёт строки внешнему фильтру и читает его вывод.
+// С null строки в обе стороны разделяет NUL, а фильтр получает FZF_OPEN_NUL=1.
+// Пробелы на концах строк сохраняются: они могут быть частью имени файла.
+func runSelectionFilter(ctx context.Context, command string, selections []string, null bool) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, selectionFilterTimeout)
+	defer cancel()
+
+	sep := "\n"
+	argv := shellArgv(command)
+	cmd := execCommand(ctx, "filter", argv[0], argv[1:]...)
+	if null {
+		sep = "\x00"
+		cmd.Env = append(os.Environ(), "FZF_OPEN_NUL=1")
+	}
+	cmd.Stdin = strings.NewReader(strings.Join(selections, sep) + sep)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(out), sep), sep), nil
+}
+
+// configuredSelectionFilters возвращает действующие фильтры в виде для конфигурации
+func configuredSelectionFilters() []SelectionFilter {
+	filters := make([]SelectionFilter, len(selectionFilters))
+	for i, f := range selectionFilters {
+		filters[i] = f.SelectionFilter
+	}
+	return filters
+}
@@ -0,0 +1,50 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestRunSelectionFilterKeepsSpaces(t *testing.T) {
+	selections := []string{" leading.txt", "trailing.txt ", "in the middle.txt"}
+
+	got, err := runSelectionFilter(context.Background(), "cat", selections, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, selections) {
+		t.Errorf("runSelectionFilter(cat) = %q, want %q", got, selections)
+	}
+}
+
+func TestRunSelectionFilterNUL(t *testing.T) {
+	selections := []string{"two\nlines.txt", " spaced.txt ", "plain.txt"}
+
+	// Фильтр отвечает только при FZF_OPEN_NUL=1; перевод строки внутри имени
+	// не разбивает запись
+	got, err := runSelectionFilter(context.Background(), `[ "$FZF_OPEN_NUL" = 1 ] && cat`, selections, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, selections) {
+		t.Errorf("runSelectionFilter() = %q, want %q", got, selections)
+	}
+}
+
+func TestFilterSelectionsFallsBackOnError(t *testing.T) {
+	old := selectionFilters
+	t.Cleanup(func() { selectionFilters = old })
+	selectionFilters = nil
+	if err := addSelectionFilters([]SelectionFilter{{Command: "exit 3"}, {Regex: `\.bak$`}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := filterSelections(context.Background(), []string{"a.txt.bak ", "b.txt.bak", "  "}, true)
+	want := []string{"a.txt.bak ", "b.txt"}
+	if !slices.Equal(got, want) {
+		t.Errorf("filterSelections() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// selector - состояние встроенного выбора (подкоманда select)
+type selector struct {
+	items   []string
+	matches []int // индексы items, подходящих под запрос, лучшие первыми
+	marked  map[int]bool
+	query   []rune
+	cursor  int // позиция в matches
+	offset  int // первая показанная строка matches
+	rows    int // размер терминала при последней отрисовке
+	cols    int
+
+	prompt string
+	header []string
+	multi  bool
+	expect map[string]string // последовательность байтов клавиши -> имя из --expect
+}
+
+// runSelect реализует подкоманду select - встроенную замену fzf для машин,
+// где его нет: читает строки со стандартного ввода (или, если это терминал,
+// обходит текущий каталог) и показывает их в терминале. Вывод и коды
+// возврата - как у fzf: 1 - нет совпадений, 130 - выбор отменён.
+func runSelect(args []string) int {
+	fset := flag.NewFlagSet("select", flag.ExitOnError)
+	prompt := fset.String("prompt", "> ", "Input prompt")
+	query := fset.String("query", "", "Start with this query")
+	header := fset.String("header", "", "Header shown above the list")
+	expect := fset.String("expect", "", "Comma-separated keys that accept the selection and are printed first")
+	printQuery := fset.Bool("print-query", false, "Print the query as the first line")
+	multi := fset.Bool("multi", false, "Allow marking several lines with Tab")
+	fset.Bool("no-multi", false, "Select a single line (default)")
+	fset.Bool("ansi", false, "Accepted for compatibility with fzf")
+	plain := fset.Bool("plain", false, "Numbered list and line prompts instead of the full-screen list")
+	fset.Parse(args)
+
+	s := &selector{
+		prompt: *prompt,
+		multi:  *multi,
+		marked: map[int]bool{},
+		query:  []rune(*query),
+		expect: map[string]string{},
+	}
+	if *header != "" {
+		s.header = strings.Split(*header, "\n")
+	}
+	for _, key := range strings.Split(*expect, ",") {
+		if seq := keySequence(key); seq != "" {
+			s.expect[seq] = key
+		}
+	}
+
+	var key string
+	var accepted bool
+	if *plain {
+		tty, restore, err := openLineTTY()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: the built-in finder needs a terminal: %v\n", err)
+			return 2
+		}
+		defer restore()
+		s.items = readSelectItems()
+		s.filter()
+		accepted = s.runPlain(tty, *printQuery)
+		restore()
+	} else {
+		tty, restore, err := openTTY()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: the built-in finder needs a terminal: %v\n", err)
+			return 2
+		}
+		defer restore()
+		fmt.Fprint(tty, "\033[?1049h")
+		drawLine(tty, 1, "Loading…")
+		s.items = readSelectItems()
+		s.filter()
+		key, accepted = s.run(tty)
+		fmt.Fprint(tty, "\033[?1049l")
+		restore()
+	}
+	if !accepted {
+		return 130
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	if *printQuery {
+		fmt.Fprintln(w, string(s.query))
+	}
+	if *expect != "" {
+		fmt.Fprintln(w, key)
+	}
+	selected := s.selection()
+	for _, line := range selected {
+		fmt.Fprintln(w, line)
+	}
+	if len(selected) == 0 {
+		return 1
+	}
+	return 0
+}
+
+// readSelectItems читает строки списка: со стандартного ввода или, если там
+// терминал, встроенным обходчиком из текущего каталога
+func readSelectItems() []string {
+	var items []string
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		walkFiles(".", walkOptions{}, func(rel string) {
+			items = append(items, rel)
+		})
+		return items
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items
+}
+
+// run обрабатывает клавиши до выбора или отмены. Возвращает имя клавиши из
+// --expect (пусто для Enter) и false, если выбор отменён.
+func (s *selector) run(tty io.ReadWriter) (string, bool) {
+	buf := make([]byte, 256)
+	for {
+		s.draw(tty)
+		n, err := tty.Read(buf)
+		if err != nil {
+			return "", false
+		}
+		input := string(buf[:n])
+		for input != "" {
+			seq := nextKey(input)
+			input = input[len(seq):]
+			if key, ok := s.expect[seq]; ok {
+				return key, true
+			}
+			switch seq {
+			case "\r", "\n":
+				return "", true
+			case "\x1b", "\x03", "\x07":
+				return "", false
+			case "\x7f", "\x08":
+				if len(s.query) > 0 {
+					s.query = s.query[:len(s.query)-1]
+					s.filter()
+				}
+			case "\x15":
+				s.query = nil
+				s.filter()
+			case "\x17":
+				s.deleteWord()
+			case "\x1b[A", "\x1bOA", "\x10", "\x0b":
+				s.move(-1)
+			case "\x1b[B", "\x1bOB", "\x0e":
+				s.move(1)
+			case "\x1b[5~":
+				s.move(-s.pageSize())
+			case "\x1b[6~":
+				s.move(s.pageSize())
+			case "\t":
+				if s.multi && len(s.matches) > 0 {
+					i := s.matches[s.cursor]
+					s.marked[i] = !s.marked[i]
+					s.move(1)
+				}
+			default:
+				r, _ := utf8.DecodeRuneInString(seq)
+				if len(seq) == utf8.RuneLen(r) && unicode.IsPrint(r) {
+					s.query = append(s.query, r)
+					s.filter()
+				}
+			}
+		}
+	}
+}
+
+// nextKey отделяет от ввода одну клавишу: символ, управляющий байт или
+// escape-последовательность
+func nextKey(input string) string {
+	if input[0] != 0x1b || len(input) == 1 {
+		_, size := utf8.DecodeRuneInString(input)
+		return input[:size]
+	}
+	if input[1] != '[' && input[1] != 'O' {
+		// Alt и символ
+		_, size := utf8.DecodeRuneInString(input[1:])
+		return input[:1+size]
+	}
+	for i := 2; i < len(input); i++ {
+		if c := input[i]; c >= 0x40 && c <= 0x7e {
+			return input[:i+1]
+		}
+	}
+	return input
+}
+
+// keySequence переводит имя клавиши fzf (ctrl-o, alt-x) в байты, которые
+// посылает терминал. Пусто - клавиша не поддерживается.
+func keySequence(key string) string {
+	switch {
+	case len(key) == len("ctrl-x") && strings.HasPrefix(key, "ctrl-") && key[5] >= 'a' && key[5] <= 'z':
+		return string(rune(key[5] - 'a' + 1))
+	case strings.HasPrefix(key, "alt-") && utf8.RuneCountInString(key) == len("alt-x"):
+		return "\x1b" + key[4:]
+	}
+	return ""
+}
+
+// filter отбирает строки под запрос и упорядочивает их по оценке совпадения
+func (s *selector) filter() {
+	terms := strings.Fields(string(s.query))
+	caseSensitive := strings.ToLower(string(s.query)) != string(s.query)
+	scores := make(map[int]int)
+	s.matches = s.matches[:0]
+	for i, item := range s.items {
+		total, ok := 0, true
+		for _, term := range terms {
+			score, matched := fuzzyScore(item, term, caseSensitive)
+			if !matched {
+				ok = false
+				break
+			}
+			total += score
+		}
+		if ok {
+			s.matches = append(s.matches, i)
+			scores[i] = total
+		}
+	}
+	if len(terms) > 0 {
+		sort.SliceStable(s.matches, func(a, b int) bool {
+			return scores[s.matches[a]] > scores[s.matches[b]]
+		})
+	}
+	s.cursor, s.offset = 0, 0
+}
+
+// fuzzyScore ищет символы pattern в s по порядку. Оценка выше, если символы
+// идут подряд, начинают слово или лежат в имени файла, и ниже за пропуски.
+func fuzzyScore(s, pattern string, caseSensitive bool) (int, bool) {
+	if !caseSensitive {
+		s, pattern = strings.ToLower(s), strings.ToLower(pattern)
+	}
+	text, pat := []rune(s), []rune(pattern)
+	base := 0
+	for i, r := range text {
+		if r == '/' || r == '\\' {
+			base = i + 1
+		}
+	}
+
+	best, found := 0, false
+	// Совпадение только в имени файла обычно важнее первого попавшегося
+	for _, start := range []int{0, base} {
+		score, ok := scoreFrom(text, pat, start, base)
+		if ok && (!found || score > best) {
+			best, found = score, true
+		}
+	}
+	return best, found
+}
+
+// scoreFrom оценивает жадное совпадение pat в text, начиная с позиции start
+func scoreFrom(text, pat []rune, start, base int) (int, bool) {
+	score, j, prev := 0, 0, -2
+	for i := start; i < len(text) && j < len(pat); i++ {
+		if text[i] != pat[j] {
+			continue
+		}
+		score += 1
+		switch {
+		case i == prev+1:
+			score += 5
+		case prev >= 0:
+			score -= min(i-prev-1, 5)
+		}
+		if i == 0 || strings.ContainsRune("/\\_-. ", text[i-1]) {
+			score += 8
+		}
+		if i >= base {
+			score += 2
+		}
+		prev = i
+		j++
+	}
+	if j < len(pat) {
+		return 0, false
+	}
+	return score - len(text)/16, true
+}
+
+// deleteWord удаляет слово перед курсором, как Ctrl-W в шелле
+func (s *selector) deleteWord() {
+	q := strings.TrimRight(string(s.query), " ")
+	if i := strings.LastIndex(q, " "); i >= 0 {
+		q = q[:i+1]
+	} else {
+		q = ""
+	}
+	s.query = []rune(q)
+	s.filter()
+}
+
+// move сдвигает курсор по списку
+func (s *selector) move(delta int) {
+	s.cursor = max(0, min(s.cursor+delta, len(s.matches)-1))
+}
+
+// pageSize - сколько строк списка помещается на экране
+func (s *selector) pageSize() int {
+	return max(1, s.rows-2-len(s.header))
+}
+
+// selection возвращает отмеченные строки или строку под курсором
+func (s *selector) selection() []string {
+	var lines []string
+	for i, item := range s.items {
+		if s.marked[i] {
+			lines = append(lines, item)
+		}
+	}
+	if len(lines) == 0 && len(s.matches) > 0 {
+		lines = append(lines, s.items[s.matches[s.cursor]])
+	}
+	return lines
+}
+
+// draw перерисовывает экран: строка запроса, счётчик, заголовок и список
+func (s *selector) draw(out io.Writer) {
+	s.rows, s.cols = ttySize()
+	rows, cols := s.rows, s.cols
+	page := s.pageSize()
+	if s.cursor < s.offset {
+		s.offset = s.cursor
+	}
+	if s.cursor >= s.offset+page {
+		s.offset = s.cursor - page + 1
+	}
+
+	w := bufio.NewWriter(out)
+	row := 1
+	drawLine(w, row, fitWidth(s.prompt+string(s.query), cols))
+	row++
+	info := fmt.Sprintf("  %d/%d", len(s.matches), len(s.items))
+	if marked := s.countMarked(); s.multi && marked > 0 {
+		info += fmt.Sprintf(" (%d)", marked)
+	}
+	drawLine(w, row, info)
+	for _, line := range s.header {
+		row++
+		drawLine(w, row, fitWidth("  "+line, cols))
+	}
+	for i := s.offset; i < s.offset+page && row < rows; i++ {
+		row++
+		if i >= len(s.matches) {
+			drawLine(w, row, "")
+			continue
+		}
+		mark := "  "
+		if s.marked[s.matches[i]] {
+			mark = " *"
+		}
+		if i == s.cursor {
+			drawLine(w, row, "\033[7m"+fitWidth(">"+mark[1:]+s.items[s.matches[i]], cols)+"\033[0m")
+		} else {
+			drawLine(w, row, fitWidth(mark+s.items[s.matches[i]], cols))
+		}
+	}
+	fmt.Fprint(w, "\033[J")
+	fmt.Fprintf(w, "\033[1;%dH", min(utf8.RuneCountInString(s.prompt)+len(s.query)+1, cols))
+	w.Flush()
+}
+
+// countMarked возвращает число отмеченных строк
+func (s *selector) countMarked() int {
+	n := 0
+	for _, marked := range s.marked {
+		if marked {
+			n++
+		}
+	}
+	return n
+}
+
+// drawLine выводит строку экрана row, стирая её прежнее содержимое
+func drawLine(w io.Writer, row int, text string) {
+	fmt.Fprintf(w, "\033[%d;1H%s\033[K", row, text)
+}
+
+// fitWidth обрезает строку до ширины терминала
+func fitWidth(s string, cols int) string {
+	if utf8.RuneCountInString(s) <= cols {
+		return s
+	}
+	return string([]rune(s)[:max(0, cols-1)]) + "…"
+}
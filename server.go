@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// serverMode - флаг --server: fzf-open работает как --loop, но держит fzf
+// запущенным с --listen. Следующие запуски fzf-open не открывают свой
+// терминал и fzf, а перезагружают список в этом fzf и поднимают его окно.
+var serverMode bool
+
+// serverClient - запуск передаёт список серверу: fzf сервера читает его
+// построчно, как и при своём старте
+var serverClient bool
+
+const (
+	// serverSocketName - сокет fzf --listen в runtimeDir
+	serverSocketName = "server.sock"
+	// serverDirName - файл с каталогом, который сейчас показывает сервер
+	serverDirName = "server.dir"
+	// serverWindowTitle - заголовок окна сервера, по которому его находит композитор
+	serverWindowTitle = "fzf-open-server"
+	// serverQuitKey останавливает сервер; Esc только сбрасывает список
+	serverQuitKey = "ctrl-q"
+	// serverRequestTimeout ограничивает обращение к серверу: если он не
+	// ответил, fzf-open запускается как обычно
+	serverRequestTimeout = 500 * time.Millisecond
+)
+
+// serverPaths возвращает пути сокета сервера и файла с его каталогом
+func serverPaths() (socket, dirFile string, err error) {
+	dir, err := runtimeDir()
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(dir, serverSocketName), filepath.Join(dir, serverDirName), nil
+}
+
+// setupServer проверяет, что сервер можно запустить: fzf умеет --listen и
+// другой сервер ещё не работает. Окно терминала получает заголовок
+// serverWindowTitle.
+func setupServer() error {
+	if !usingFzf() {
+		return fmt.Errorf("--server needs fzf (finder = %q)", finderName())
+	}
+	fzfHelpOnce.Do(loadFzfHelp)
+	if fzfKnownOptions != nil && !fzfKnownOptions["listen"] {
+		return errors.New("--server needs fzf with --listen support")
+	}
+	socket, _, err := serverPaths()
+	if err != nil {
+		return err
+	}
+	if _, err := serverRequest(socket, http.MethodGet, ""); err == nil {
+		return fmt.Errorf("a server is already running (%s)", socket)
+	}
+	if isInteractive() {
+		fmt.Fprintf(os.Stderr, "\033]0;%s\007", serverWindowTitle)
+	}
+	return nil
+}
+
+// stopServer удаляет сокет и файл каталога при выходе сервера
+func stopServer() {
+	if socket, dirFile, err := serverPaths(); err == nil {
+		os.Remove(socket)
+		os.Remove(dirFile)
+	}
+}
+
+// addServerListen добавляет в параметры fzf сокет --listen и клавишу остановки
+// сервера и запоминает каталог dir, от которого fzf строит относительные пути
+func addServerListen(opts *pickerOptions, dir string) {
+	if !serverMode {
+		return
+	}
+	socket, dirFile, err := serverPaths()
+	if err != nil {
+		fmt.Fprintf(logOut, "Warning: %v\n", err)
+		return
+	}
+	// Сокет, оставшийся от завершившегося fzf, помешал бы новому
+	os.Remove(socket)
+	if err := os.WriteFile(dirFile, []byte(dir), 0o600); err != nil {
+		fmt.Fprintf(logOut, "Warning: %v\n", err)
+	}
+
+	opts.Listen = socket
+	opts.Expect = append(opts.Expect, serverQuitKey)
+	hint := serverQuitKey + ": stop server"
+	if opts.Header != "" {
+		hint = opts.Header + " │ " + hint
+	}
+	opts.Header = hint
+}
+
+// serverCompatible сообщает, может ли запуск передать выбор серверу: у
+// сервера свои параметры fzf, поэтому флаги, которые их меняют, и запуски,
+// которые ждут результата, открывают fzf как обычно
+func serverCompatible(cfg *Config) bool {
+	return !serverMode && !cfg.Loop && !cfg.Multi && !cfg.Wait && cfg.Output == "" &&
+		cfg.With == "" && !dirsMode && !recentMode && !grepMode && !snapshotMode && !safeMode && !kioskMode && hasPosixShell()
+}
+
+// sendToServer перезагружает список запущенного сервера для cfg.StartingDir и
+// поднимает его окно. false - сервера нет или он не ответил.
+func sendToServer(cfg *Config) bool {
+	socket, dirFile, err := serverPaths()
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(socket); err != nil {
+		return false
+	}
+	serverDir, err := os.ReadFile(dirFile)
+	if err != nil {
+		return false
+	}
+
+	// Повторный запрос (двойное нажатие горячей клавиши) объединяется с первым
+	release, claimed := claimLaunch("server\x00" + cfg.StartingDir + "\x00" + cfg.Query)
+	if !claimed {
+		fmt.Fprintf(logOut, "Info: the fzf-open server was just asked to show %q, ignoring the repeated request\n", cfg.StartingDir)
+		return true
+	}
+	sent := false
+	defer func() {
+		if !sent {
+			release()
+		}
+	}()
+
+	serverClient = true
+	defer func() { serverClient = false }()
+	source := serverSourceCommand(cfg.StartingDir, string(serverDir))
+	if source == "" {
+		return false
+	}
+	// Каждое действие отправляется отдельно: форма с двоеточием забирает
+	// остаток строки, так что аргумент не нужно экранировать
+	actions := []string{"reload:" + source, "change-query:" + cfg.Query}
+	for _, action := range actions {
+		journalExec("server", socket, action)
+		if _, err := serverRequest(socket, http.MethodPost, action); err != nil {
+			fmt.Fprintf(logOut, "Warning: the fzf-open server did not respond, starting fzf: %v\n", err)
+			// Сервер завершился, не убрав сокет
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				stopServer()
+			}
+			return false
+		}
+	}
+	sent = true
+	focusServerWindow()
+	return true
+}
+
+// serverSourceCommand возвращает команду списка файлов dir для сервера, fzf
+// которого работает в serverDir. Файлы другого каталога перечисляются
+// абсолютными путями, потому что сервер разрешает выбор от serverDir.
+// fzf выполняет reload через $SHELL, поэтому команда обёрнута в sh -c.
+func serverSourceCommand(dir, serverDir string) string {
+	source := sourceCommand()
+	if source == "" {
+		source = walkSourceCommand(defaultConfig.NoIgnore)
+	}
+	if source == "" {
+		return ""
+	}
+	if dir == serverDir {
+		return shCommand(source)
+	}
+	prefix := strings.NewReplacer(`\`, `\\`, "|", `\|`, "&", `\&`).Replace(strings.TrimSuffix(dir, "/") + "/")
+	return shCommand("cd " + shellQuote(dir) + " && (" + source + ") | sed " + shellQuote("s|^|"+prefix+"|"))
+}
+
+// serverRequest отправляет запрос HTTP в сокет fzf --listen
+func serverRequest(socket, method, body string) (string, error) {
+	client := &http.Client{
+		Timeout: serverRequestTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	req, err := http.NewRequest(method, "http://fzf/", strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return string(data), nil
+}
+
+// focusServerWindow поднимает окно сервера по заголовку serverWindowTitle
+func focusServerWindow() {
+	argv := serverFocusArgv()
+	if argv == nil {
+		return
+	}
+	if out, err := execCommand(context.Background(), "server", argv[0], argv[1:]...).CombinedOutput(); err != nil {
+		fmt.Fprintf(logOut, "Warning: could not focus the fzf-open server: %v %s\n", err, strings.TrimSpace(string(out)))
+	}
+}
+
+// serverFocusArgv возвращает команду, которая поднимает окно сервера в
+// текущем композиторе или оконном менеджере X11
+func serverFocusArgv() []string {
+	title := "^" + serverWindowTitle + "$"
+	switch {
+	case compositorAvailable("HYPRLAND_INSTANCE_SIGNATURE", "hyprctl"):
+		return []string{"hyprctl", "dispatch", "focuswindow", "title:" + title}
+	case compositorAvailable("SWAYSOCK", "swaymsg"):
+		return []string{"swaymsg", `[title="` + title + `"] focus`}
+	case compositorAvailable("I3SOCK", "i3-msg"):
+		return []string{"i3-msg", `[title="` + title + `"] focus`}
+	case compositorAvailable("DISPLAY", "xdotool"):
+		return []string{"xdotool", "search", "--name", title, "windowactivate"}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServerSourceCommandUnderFish(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "other dir")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	useConfig(t, writeConfig(t, root, "config.toml", "source = \"echo b.txt; echo a.txt\"\n"))
+	if err := loadConfig(""); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL", "/usr/bin/fish")
+
+	for _, tt := range []struct {
+		dir  string
+		want string
+	}{
+		{root, "b.txt\na.txt\n"},
+		{dir, dir + "/b.txt\n" + dir + "/a.txt\n"},
+	} {
+		command := serverSourceCommand(tt.dir, root)
+		if !strings.HasPrefix(command, quoteCommand([]string{"sh", "-c"})+" ") {
+			t.Fatalf("serverSourceCommand(%q) = %q, want a sh -c command", tt.dir, command)
+		}
+		for shell, got := range runListCommand(t, root, command, "sh", "fish") {
+			if got != tt.want {
+				t.Errorf("%s: serverSourceCommand(%q) lists %q, want %q", shell, tt.dir, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// snapshotMode - флаг --snapshots: для выбранного файла показываются его
+// версии из снимков btrfs и ZFS, и открывается копия выбранной версии только
+// для чтения
+var snapshotMode bool
+
+// snapshotVersion - версия файла в снимке файловой системы
+type snapshotVersion struct {
+	snapshot string // вид и имя снимка: "snapper 412", "zfs daily-2024-05-01"
+	path     string
+	modTime  time.Time
+	size     int64
+}
+
+// snapshotLayouts - где в корне тома лежат снимки: каталог снимков и путь
+// внутри снимка до корня тома. snapper кладёт том в .snapshots/<номер>/snapshot,
+// btrbk и ручные снимки - прямо в .snapshots/<имя>, ZFS показывает снимки
+// набора данных в скрытом .zfs/snapshot/<имя>.
+var snapshotLayouts = []struct {
+	kind, dir, inner string
+}{
+	{"snapper", ".snapshots", "snapshot"},
+	{"btrfs", ".snapshots", ""},
+	{"zfs", filepath.Join(".zfs", "snapshot"), ""},
+}
+
+// findSnapshots ищет версии файла в снимках всех каталогов выше него. Версии
+// идут от новых к старым; одинаковые (по размеру и времени изменения) и
+// совпадающие с текущим файлом пропускаются.
+func findSnapshots(filePath string) ([]snapshotVersion, error) {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+
+	var versions []snapshotVersion
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil {
+			break
+		}
+		for _, layout := range snapshotLayouts {
+			entries, err := os.ReadDir(filepath.Join(dir, layout.dir))
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				path := filepath.Join(dir, layout.dir, entry.Name(), layout.inner, rel)
+				if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+					versions = append(versions, snapshotVersion{
+						snapshot: layout.kind + " " + entry.Name(),
+						path:     path,
+						modTime:  fi.ModTime(),
+						size:     fi.Size(),
+					})
+				}
+			}
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].modTime.After(versions[j].modTime)
+	})
+	current, _ := os.Stat(abs)
+	result := versions[:0]
+	for _, v := range versions {
+		if current != nil && v.size == current.Size() && v.modTime.Equal(current.ModTime()) {
+			continue
+		}
+		if n := len(result); n > 0 && v.size == result[n-1].size && v.modTime.Equal(result[n-1].modTime) {
+			continue
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// label возвращает строку меню версии: время изменения, размер и снимок
+func (v snapshotVersion) label() string {
+	return fmt.Sprintf("%s  %9d B  %s", v.modTime.Format("2006-01-02 15:04:05"), v.size, v.snapshot)
+}
+
+// pickSnapshot показывает версии файла из снимков и возвращает путь копии
+// выбранной версии только для чтения. Пусто - версий нет или выбор отменён.
+func pickSnapshot(filePath string) (string, error) {
+	versions, err := findSnapshots(filePath)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no earlier versions of %s in .snapshots or .zfs/snapshot", filePath)
+	}
+
+	items := make([]string, len(versions))
+	for i, v := range versions {
+		items[i] = v.label()
+	}
+	choice, err := fzfMenu(fmt.Sprintf("Versions of %s> ", filepath.Base(filePath)), false, items...)
+	if err != nil || choice == "" {
+		return "", err
+	}
+	for _, v := range versions {
+		if v.label() == choice {
+			return readOnlySnapshotCopy(v.path)
+		}
+	}
+	return "", nil
+}
+
+// readOnlySnapshotCopy копирует версию из снимка в runtimeDir с правами только
+// на чтение: снимок btrfs может быть доступен на запись, и редактор не должен
+// менять историю. Имя файла сохраняется, чтобы приложение выбиралось как для
+// оригинала; готовая копия той же версии используется повторно.
+func readOnlySnapshotCopy(path string) (string, error) {
+	base, err := runtimeDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(path))
+	dir := filepath.Join(base, "snapshots", hex.EncodeToString(sum[:16]))
+	out := filepath.Join(dir, filepath.Base(path))
+	if _, err := os.Stat(out); err == nil {
+		return out, nil
+	}
+	if err := ensurePrivateDir(filepath.Dir(dir)); err != nil {
+		return "", err
+	}
+	if err := ensurePrivateDir(dir); err != nil {
+		return "", err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(dir, ".copy-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("copying %s: %w", path, err)
+	}
+	if fi, err := src.Stat(); err == nil {
+		os.Chtimes(tmp.Name(), fi.ModTime(), fi.ModTime())
+	}
+	if err := os.Chmod(tmp.Name(), 0o444); err != nil {
+		return "", err
+	}
+	return out, os.Rename(tmp.Name(), out)
+}
+
+// snapshotPaths заменяет выбранные файлы копиями их версий из снимков.
+// Файлы без версий и с отменённым выбором пропускаются.
+func snapshotPaths(paths []string) []string {
+	var result []string
+	for _, path := range paths {
+		copyPath, err := pickSnapshot(path)
+		if err != nil {
+			fmt.Fprintf(logOut, "Error: %v\n", err)
+			continue
+		}
+		if copyPath != "" {
+			result = append(result, copyPath)
+		}
+	}
+	return result
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// sniffLength - сколько байт начала файла читает sniffMimeType (заголовок tar
+// занимает 512 байт)
+const sniffLength = 512
+
+// magicSignature - сигнатура формата: байты prefix по смещению offset
+type magicSignature struct {
+	offset   int
+	prefix   string
+	mimeType string
+}
+
+// magicSignatures - распознаваемые по первым байтам форматы
+var magicSignatures = []magicSignature{
+	{0, "\x89PNG\r\n\x1a\n", "image/png"},
+	{0, "\xff\xd8\xff", "image/jpeg"},
+	{0, "GIF87a", "image/gif"},
+	{0, "GIF89a", "image/gif"},
+	{0, "%PDF-", mimePDF},
+	{0, "\x7fELF", "application/x-executable"},
+	{0, "\x1f\x8b", "application/gzip"},
+	{0, "BZh", "application/x-bzip2"},
+	{0, "\xfd7zXZ\x00", "application/x-xz"},
+	{0, "7z\xbc\xaf\x27\x1c", "application/x-7z-compressed"},
+	{0, "Rar!\x1a\x07", "application/vnd.rar"},
+	{0, "\x1a\x45\xdf\xa3", "video/x-matroska"},
+	{0, "OggS", "audio/ogg"},
+	{0, "fLaC", "audio/flac"},
+	{0, "ID3", "audio/mpeg"},
+	{0, "SQLite format 3\x00", "application/vnd.sqlite3"},
+	{257, "ustar", "application/x-tar"},
+}
+
+// Интерпретаторы из строки #! и соответствующие MIME-типы
+var shebangTypes = map[string]string{
+	"sh": mimeApplicationScript, "bash": mimeApplicationScript, "zsh": mimeApplicationScript,
+	"dash": mimeApplicationScript, "ksh": mimeApplicationScript, "fish": "application/x-fishscript",
+	"python": "text/x-python", "python3": "text/x-python", "perl": "application/x-perl",
+	"ruby": "application/x-ruby", "node": mimeApplicationJS, "lua": "text/x-lua",
+}
+
+// sniffMimeType определяет MIME-тип по первым байтам файла без внешних
+// программ. Пустая строка - формат не распознан.
+func sniffMimeType(filePath string) string {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return ""
+	}
+	return sniffContent(head[:n])
+}
+
+// sniffContent распознаёт формат по началу файла
+func sniffContent(head []byte) string {
+	if len(head) == 0 {
+		return mimeInodeEmpty
+	}
+
+	for _, sig := range magicSignatures {
+		if len(head) >= sig.offset+len(sig.prefix) && string(head[sig.offset:sig.offset+len(sig.prefix)]) == sig.prefix {
+			return sig.mimeType
+		}
+	}
+
+	switch {
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return "image/webp"
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "AVI ":
+		return "video/x-msvideo"
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return "audio/x-wav"
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		return sniffFtyp(string(head[8:12]))
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return sniffZip(head)
+	}
+
+	return sniffText(head)
+}
+
+// sniffFtyp различает контейнеры ISO BMFF по бренду
+func sniffFtyp(brand string) string {
+	switch {
+	case strings.HasPrefix(brand, "M4A"):
+		return "audio/mp4"
+	case strings.HasPrefix(brand, "qt"):
+		return "video/quicktime"
+	case brand == "avif" || brand == "avis":
+		return "image/avif"
+	case brand == "heic" || brand == "heix" || brand == "mif1":
+		return "image/heic"
+	}
+	return "video/mp4"
+}
+
+// sniffZip распознаёт документы OpenDocument и EPUB: по спецификации их первая
+// запись - несжатый файл "mimetype" с MIME-типом документа
+func sniffZip(head []byte) string {
+	const nameOffset = 30
+	if len(head) > nameOffset+8 && string(head[nameOffset:nameOffset+8]) == "mimetype" {
+		rest := head[nameOffset+8:]
+		if end := bytes.Index(rest, []byte("PK\x03\x04")); end > 0 {
+			rest = rest[:end]
+		}
+		if mimeType := string(rest); strings.Contains(mimeType, "/") && !strings.ContainsAny(mimeType, " \n") {
+			return mimeType
+		}
+	}
+	return "application/zip"
+}
+
+// sniffText распознаёт текст в UTF-8 и скрипты со строкой #!
+func sniffText(head []byte) string {
+	if bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(trimPartialRune(head)) {
+		return ""
+	}
+
+	if bytes.HasPrefix(head, []byte("#!")) {
+		line, _, _ := bytes.Cut(head[2:], []byte("\n"))
+		fields := strings.Fields(string(line))
+		if len(fields) > 0 {
+			interpreter := filepath.Base(fields[0])
+			if interpreter == "env" && len(fields) > 1 {
+				interpreter = fields[1]
+			}
+			if mimeType, ok := shebangTypes[interpreter]; ok {
+				return mimeType
+			}
+		}
+	}
+
+	trimmed := bytes.TrimSpace(head)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<?xml")):
+		return mimeApplicationXML
+	case hasPrefixFold(trimmed, "<!doctype html"), hasPrefixFold(trimmed, "<html"):
+		return "text/html"
+	}
+	return mimeTextPrefix + "plain"
+}
+
+// hasPrefixFold - bytes.HasPrefix без учёта регистра ASCII
+func hasPrefixFold(b []byte, prefix string) bool {
+	return len(b) >= len(prefix) && strings.EqualFold(string(b[:len(prefix)]), prefix)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Значения флага --sort и ключа sort
+const (
+	sortName  = "name"  // по пути
+	sortMtime = "mtime" // сначала недавно изменённые
+	sortSize  = "size"  // сначала большие
+	sortNone  = "none"  // в порядке обхода
+)
+
+// sortOrder - порядок списка из --sort или ключа sort; пусто и none - порядок
+// обхода, в котором список доходит до fzf сразу, а не после обхода всего дерева
+var sortOrder string
+
+// checkSort проверяет значение --sort и ключа sort
+func checkSort(value string) error {
+	switch value {
+	case "", sortName, sortMtime, sortSize, sortNone:
+		return nil
+	}
+	return fmt.Errorf("sort: unknown value %q (expected %s, %s, %s or %s)", value, sortName, sortMtime, sortSize, sortNone)
+}
+
+// sortedListing сообщает, что список упорядочивает встроенный обходчик: fd,
+// rg и обходчик fzf сортировать не умеют, поэтому с --sort список строит он.
+// Строки --grep и недавние файлы не сортируются.
+func sortedListing() bool {
+	return sortOrder != "" && sortOrder != sortNone && !grepMode && !recentMode && !serverClient
+}
+
+// walkSorted обходит root, как walkFiles, и вызывает emit для файлов в
+// порядке opts.sort. С сортировкой строки выдаются только после обхода.
+func walkSorted(root string, opts walkOptions, emit func(rel string)) error {
+	if opts.sort == "" || opts.sort == sortNone {
+		return walkFiles(root, opts, emit)
+	}
+
+	type entry struct {
+		rel   string
+		size  int64
+		mtime time.Time
+	}
+	var entries []entry
+	err := walkFiles(root, opts, func(rel string) {
+		e := entry{rel: rel}
+		if opts.sort != sortName {
+			if fi, err := os.Stat(filepath.Join(root, rel)); err == nil {
+				e.size, e.mtime = fi.Size(), fi.ModTime()
+			}
+		}
+		entries = append(entries, e)
+	})
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		switch opts.sort {
+		case sortMtime:
+			return b.mtime.Compare(a.mtime)
+		case sortSize:
+			return cmp.Compare(b.size, a.size)
+		}
+		return strings.Compare(a.rel, b.rel)
+	})
+	for _, e := range entries {
+		emit(e.rel)
+	}
+	return err
+}
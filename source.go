@@ -0,0 +1,403 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ignorePatterns - шаблоны имён и путей (относительно начального каталога),
+// которые не попадают в список fzf. Задаются ключом ignore в конфигурации.
+var ignorePatterns []string
+
+// fzfEnv возвращает окружение для запуска fzf. Если заданы шаблоны ignore,
+// источник списка файлов подменяется через FZF_DEFAULT_COMMAND.
+func fzfEnv() []string {
+	env := os.Environ()
+	// fzf выполняет FZF_DEFAULT_COMMAND через $SHELL; в режиме --safe и без
+	// POSIX-шелла используется встроенный обходчик fzf
+	if safeMode || !hasPosixShell() {
+		name := currentFinder().sourceEnv()
+		return slices.DeleteFunc(env, func(kv string) bool {
+			return name != "" && strings.HasPrefix(kv, name+"=")
+		})
+	}
+	return append(env, fzfEnvOverrides()...)
+}
+
+// fzfEnvOverrides возвращает переменные, которые fzf-open добавляет к окружению fzf
+func fzfEnvOverrides() []string {
+	var env []string
+	if powerSaver {
+		env = append(env, powerSaverEnv+"=1")
+	}
+	name := currentFinder().sourceEnv()
+	if source := listSourceCommand(); source != "" && name != "" {
+		env = append(env, name+"="+source)
+	}
+	return env
+}
+
+// listSourceCommand возвращает команду списка для программы выбора: строки
+// файлов в режиме --grep, недавние файлы в режиме --recent, иначе
+// sourceCommand. С colors и icons список оформляет подкоманда colorize.
+func listSourceCommand() string {
+	if grepMode {
+		return grepSourceCommand()
+	}
+	if recentMode {
+		if source := recentSourceCommand(); source != "" {
+			return decoratedCommand(source)
+		}
+	}
+	return decoratedCommand(sourceCommand())
+}
+
+// dirsMode - флаг -D: fzf показывает каталоги вместо файлов. Список каталогов
+// умеют строить fd, find и встроенный обходчик; остальные источники в этом
+// режиме заменяются автоматическим выбором.
+var dirsMode bool
+
+// Встроенные источники списка файлов для ключа source
+const (
+	sourceAuto = "auto" // fd, rg или встроенный обходчик - что найдётся первым
+	sourceFd   = "fd"
+	sourceRg   = "rg"
+	sourceFind = "find"
+	sourceWalk = "walk" // встроенный обходчик (подкоманда files)
+)
+
+// sourceCommand возвращает команду, которая перечисляет файлы для fzf: один
+// из встроенных источников или команду шелла из source. Без source список
+// строит сам fzf, а при заданных ignore - find. С frecency список
+// упорядочивает подкоманда rank.
+func sourceCommand() string {
+	return rankedSourceCommand(unrankedSourceCommand())
+}
+
+// unrankedSourceCommand возвращает команду источника без упорядочивания
+func unrankedSourceCommand() string {
+	switch source := resolvedSource(); source {
+	case "":
+		return ignoreSourceCommand(ignorePatterns)
+	case sourceFind:
+		return findSourceCommand(ignorePatterns, defaultConfig.Hidden)
+	case sourceFd, sourceRg, sourceWalk:
+		return presetSourceCommand(source, defaultConfig.NoIgnore)
+	default:
+		return source
+	}
+}
+
+// nulSeparated сообщает, что строки списка и вывод fzf разделяет NUL (--read0
+// и --print0): имена с переводом строки, табуляцией или пробелами на концах
+// доходят до открытия целиком. Так работают встроенный обходчик fzf, fd, rg и
+// подкоманда files в fzf и skim; команда из source, find, недавние файлы и
+// список сервера остаются построчными.
+func nulSeparated() bool {
+	if !usingFzf() && finderName() != finderSkim {
+		return false
+	}
+	if recentMode || grepMode || serverMode || serverClient {
+		return false
+	}
+	// Без шелла список строит обходчик fzf или fzf-open (writeDirectList)
+	if safeMode || !hasPosixShell() {
+		return true
+	}
+	switch resolvedSource() {
+	case "":
+		return len(ignorePatterns) == 0
+	case sourceFd, sourceRg, sourceWalk:
+		return true
+	}
+	return false
+}
+
+// resolvedSource возвращает source, заменив auto найденным источником
+func resolvedSource() string {
+	if sortedListing() {
+		return sourceWalk
+	}
+	source := defaultConfig.Source
+	if dirsMode && source != sourceFd && source != sourceFind && source != sourceWalk {
+		source = sourceAuto
+	}
+	// Список встроенного обходчика fzf не упорядочить по частоте открытий и не
+	// оформить, а
+	// у других программ выбора обходчика нет
+	if source == "" && (frecencyRanked() || candidateColors() || candidateIcons() || candidateDetails() || !usingFzf()) {
+		source = sourceAuto
+	}
+	if source != sourceAuto {
+		return source
+	}
+	if fdProgram() != "" {
+		return sourceFd
+	}
+	// rg перечисляет только файлы
+	if _, err := cachedLookPath("rg"); err == nil && !dirsMode {
+		return sourceRg
+	}
+	return sourceWalk
+}
+
+// presetSourceCommand возвращает команду источника, который умеет учитывать
+// .gitignore: fd, rg или встроенного обходчика
+func presetSourceCommand(source string, noIgnore bool) string {
+	switch source {
+	case sourceFd:
+		fd := fdProgram()
+		if fd == "" {
+			fd = sourceFd
+		}
+		return fdSourceCommand(fd, noIgnore)
+	case sourceRg:
+		return rgSourceCommand(noIgnore)
+	case sourceWalk:
+		return walkSourceCommand(noIgnore)
+	}
+	return ""
+}
+
+// sourceProgram возвращает программу встроенного источника для проверки в
+// doctor; пусто - внешняя программа не нужна
+func sourceProgram() string {
+	switch defaultConfig.Source {
+	case sourceFd:
+		if fd := fdProgram(); fd != "" {
+			return fd
+		}
+		return sourceFd
+	case sourceRg:
+		return "rg"
+	}
+	return ""
+}
+
+// fdProgram возвращает имя fd в PATH: в Debian и Ubuntu он называется fdfind
+func fdProgram() string {
+	for _, name := range []string{"fd", "fdfind"} {
+		if _, err := cachedLookPath(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// ignoreGlobs переводит шаблоны ignore в шаблоны исключения fd и rg: шаблон с
+// "/" привязывается к начальному каталогу
+func ignoreGlobs(patterns []string) []string {
+	var globs []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+		if strings.Contains(pattern, "/") {
+			pattern = "/" + strings.TrimPrefix(pattern, "/")
+		}
+		globs = append(globs, pattern)
+	}
+	return append(globs, ".git")
+}
+
+// fdSourceCommand - источник fd
+func fdSourceCommand(fd string, noIgnore bool) string {
+	fileType := "f"
+	if dirsMode {
+		fileType = "d"
+	}
+	args := append([]string{fd, "--type", fileType, "--color", "never"}, sourceThreadArgs()...)
+	if defaultConfig.Hidden {
+		args = append(args, "--hidden")
+	}
+	if noIgnore {
+		args = append(args, "--no-ignore")
+	}
+	for _, glob := range ignoreGlobs(ignorePatterns) {
+		args = append(args, "--exclude", glob)
+	}
+	if nulSeparated() {
+		args = append(args, "--print0")
+	}
+	return quoteCommand(args)
+}
+
+// rgSourceCommand - источник rg --files
+func rgSourceCommand(noIgnore bool) string {
+	args := append([]string{"rg", "--files", "--color", "never"}, sourceThreadArgs()...)
+	if defaultConfig.Hidden {
+		args = append(args, "--hidden")
+	}
+	if noIgnore {
+		args = append(args, "--no-ignore")
+	}
+	for _, glob := range ignoreGlobs(ignorePatterns) {
+		args = append(args, "--glob", "!"+glob)
+	}
+	if nulSeparated() {
+		args = append(args, "--null")
+	}
+	return quoteCommand(args)
+}
+
+// walkSourceCommand - встроенный обходчик: fzf вызывает подкоманду files
+func walkSourceCommand(noIgnore bool) string {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(logOut, "Warning: the built-in file walker is unavailable: %v\n", err)
+		return ""
+	}
+	args := []string{exe, "files"}
+	if sortedListing() {
+		args = append(args, "--sort", sortOrder)
+	}
+	if dirsMode {
+		args = append(args, "--dirs")
+	}
+	if defaultConfig.Hidden {
+		args = append(args, "--hidden")
+	}
+	if noIgnore {
+		args = append(args, "--no-ignore")
+	}
+	for _, pattern := range ignorePatterns {
+		args = append(args, "--exclude", pattern)
+	}
+	if nulSeparated() {
+		args = append(args, "-0")
+	}
+	return quoteCommand(args)
+}
+
+// addDirsMode настраивает fzf на выбор каталога в режиме -D. Без шелла
+// FZF_DEFAULT_COMMAND не используется, и каталоги перечисляет сам fzf (0.48+).
+func addDirsMode(opts *pickerOptions) {
+	if !dirsMode {
+		return
+	}
+	opts.Prompt = "Select directory> "
+	if safeMode || !hasPosixShell() {
+		opts.Walker = "dir,follow,hidden"
+	}
+}
+
+// ignoreStateSuffix - суффикс файла состояния переключателя .gitignore (см.
+// pickerStatePath)
+const ignoreStateSuffix = ".ignore"
+
+// addIgnoreToggle добавляет в параметры fzf клавишу переключения .gitignore
+func addIgnoreToggle(opts *pickerOptions) {
+	basePath, err := pickerStatePath()
+	if err != nil {
+		return
+	}
+	statePath := basePath + ignoreStateSuffix
+	bind := ignoreToggleBind(statePath)
+	if bind == "" {
+		return
+	}
+	os.Remove(statePath)
+
+	opts.Binds = append(opts.Binds, bind)
+	hint := defaultConfig.IgnoreToggleKey + ": toggle .gitignore"
+	if opts.Header != "" {
+		hint = opts.Header + " │ " + hint
+	}
+	opts.Header = hint
+}
+
+// ignoreToggleBind возвращает привязку fzf, которая на клавише
+// ignore_toggle_key перезагружает список с .gitignore или без него. Текущее
+// состояние хранится в файле statePath: он есть, пока список переключён.
+// Пусто - источник не учитывает .gitignore или переключать нечем.
+func ignoreToggleBind(statePath string) string {
+	initial, toggled := ignoreToggleSources()
+	if initial == "" || toggled == "" {
+		return ""
+	}
+	if navigationEnabled() {
+		// Список текущего корня навигации строит подкоманда nav: она
+		// выбирает команду по файлу statePath
+		list := navListCommand(strings.TrimSuffix(statePath, ignoreStateSuffix) + navStateSuffix)
+		initial, toggled = list, list
+	} else {
+		initial, toggled = decoratedCommand(initial), decoratedCommand(toggled)
+	}
+
+	// fzf выполняет reload через $SHELL, поэтому переключение написано для sh
+	script := `if [ -e "$1" ]; then rm -f "$1"; eval "$2"; else : > "$1"; eval "$3"; fi`
+	return defaultConfig.IgnoreToggleKey + ":reload:" + quoteCommand([]string{"sh", "-c", script, "sh", statePath, initial, toggled})
+}
+
+// ignoreToggleSources возвращает команды списка с .gitignore в текущем
+// состоянии и с переключённым. Пусто - переключатель недоступен.
+func ignoreToggleSources() (initial, toggled string) {
+	source := resolvedSource()
+	if defaultConfig.IgnoreToggleKey == "" || safeMode || recentMode || grepMode || !hasPosixShell() || !usingFzf() ||
+		(source != sourceFd && source != sourceRg && source != sourceWalk) {
+		return "", ""
+	}
+	initial = rankedSourceCommand(presetSourceCommand(source, defaultConfig.NoIgnore))
+	toggled = rankedSourceCommand(presetSourceCommand(source, !defaultConfig.NoIgnore))
+	if initial == "" || toggled == "" {
+		return "", ""
+	}
+	return initial, toggled
+}
+
+// quoteCommand собирает командную строку шелла из слов
+func quoteCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shCommand оборачивает скрипт в вызов sh -c: fzf выполняет команды списка и
+// reload через $SHELL, а в fish скобки и конвейеры значат другое
+func shCommand(script string) string {
+	return quoteCommand([]string{"sh", "-c", script})
+}
+
+// ignoreSourceCommand строит команду find для шаблонов ignore, если они заданы
+func ignoreSourceCommand(patterns []string) string {
+	if len(patterns) == 0 {
+		return ""
+	}
+	// Встроенный обходчик fzf показывает и скрытые файлы
+	return findSourceCommand(patterns, true)
+}
+
+// findSourceCommand строит команду find, которая перечисляет файлы, пропуская
+// каталоги и файлы по шаблонам. Шаблон без "/" сравнивается с именем на любой
+// глубине, шаблон с "/" - с путём от начального каталога; завершающий "/" игнорируется.
+// Каталог .git пропускается всегда, как и во встроенном обходчике fzf, а
+// без hidden - и все скрытые файлы и каталоги. В режиме -D перечисляются каталоги.
+func findSourceCommand(patterns []string, hidden bool) string {
+	var prune []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+		if strings.Contains(pattern, "/") {
+			prune = append(prune, "-path "+shellQuote("./"+strings.TrimPrefix(pattern, "/")))
+		} else {
+			prune = append(prune, "-name "+shellQuote(pattern))
+		}
+	}
+	prune = append(prune, "-name .git")
+	if !hidden {
+		prune = append(prune, "-name '.*'")
+	}
+
+	fileType := "f"
+	if dirsMode {
+		fileType = "d"
+	}
+	return "find . -mindepth 1 \\( " + strings.Join(prune, " -o ") + " \\) -prune -o -type " + fileType + " -print | sed 's|^\\./||'"
+}
@@ -0,0 +1,10 @@
+//go:build darwin || dragonfly || freebsd
+
+package main
+
+import "syscall"
+
+// statfsTypeName возвращает имя типа файловой системы
+func statfsTypeName(st *syscall.Statfs_t) string {
+	return cString(st.Fstypename[:])
+}
@@ -0,0 +1,8 @@
+package main
+
+import "syscall"
+
+// statfsTypeName возвращает имя типа файловой системы
+func statfsTypeName(st *syscall.Statfs_t) string {
+	return cString(st.F_fstypename[:])
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// killGracePeriod - сколько ждать после SIGTERM, прежде чем добить группу процессов SIGKILL
+const killGracePeriod = 3 * time.Second
+
+// associationTimeouts - ограничения времени работы приложений по ключу ассоциации
+// из таблицы [timeouts]. Действуют, пока fzf-open следит за запуском (-w или -k).
+var associationTimeouts = map[string]time.Duration{}
+
+// launchedProcess - запущенное приложение, за которым следит fzf-open
+type launchedProcess struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+var (
+	launchedMu sync.Mutex
+	launched   []*launchedProcess
+)
+
+// superviseProcess забирает статус завершения запущенного приложения, чтобы оно
+// не оставалось зомби, пока fzf-open ещё работает (например, ждёт Enter в режиме -k),
+// и завершает всю его группу процессов по истечении таймаута ассоциации.
+func superviseProcess(cmd *exec.Cmd, key string) {
+	p := &launchedProcess{cmd: cmd, done: make(chan struct{})}
+
+	launchedMu.Lock()
+	launched = append(launched, p)
+	launchedMu.Unlock()
+
+	go func() {
+		cmd.Wait()
+		close(p.done)
+	}()
+
+	timeout := associationTimeouts[key]
+	if timeout <= 0 {
+		return
+	}
+
+	go func() {
+		select {
+		case <-p.done:
+		case <-time.After(timeout):
+			fmt.Fprintf(logOut, "Warning: %q exceeded its %s timeout, terminating\n", cmd.Path, timeout)
+			killProcessGroup(p)
+		}
+	}()
+}
+
+// killProcessGroup посылает SIGTERM группе процессов, а если она не завершилась
+// за killGracePeriod - SIGKILL
+func killProcessGroup(p *launchedProcess) {
+	signalProcessGroup(p.cmd.Process.Pid, false)
+
+	select {
+	case <-p.done:
+	case <-time.After(killGracePeriod):
+		signalProcessGroup(p.cmd.Process.Pid, true)
+		<-p.done
+	}
+}
+
+// running сообщает, работает ли ещё приложение
+func (p *launchedProcess) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// launchedCount возвращает число запущенных за сессию приложений
+func launchedCount() int {
+	launchedMu.Lock()
+	defer launchedMu.Unlock()
+	return len(launched)
+}
+
+// launchedSince возвращает приложения, запущенные после первых n
+func launchedSince(n int) []*launchedProcess {
+	launchedMu.Lock()
+	defer launchedMu.Unlock()
+	if n >= len(launched) {
+		return nil
+	}
+	return append([]*launchedProcess(nil), launched[n:]...)
+}
+
+// waitLaunched ждёт завершения всех запущенных приложений
+func waitLaunched() {
+	launchedMu.Lock()
+	procs := append([]*launchedProcess(nil), launched...)
+	launchedMu.Unlock()
+
+	for _, p := range procs {
+		<-p.done
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Значения ключа system_files - что делать с системным файлом, который
+// текущий пользователь не может записать, при открытии в текстовом редакторе
+const (
+	systemFilesOff      = "off"
+	systemFilesReadOnly = "read-only" // редактор открывает файл только для чтения
+	systemFilesSudoedit = "sudoedit"  // файл редактируется через sudoedit
+	systemFilesAsk      = "ask"       // выбор в меню; без терминала - только для чтения
+)
+
+// Пункты меню для системного файла
+const (
+	menuSystemReadOnly = "Open read-only"
+	menuSystemSudoedit = "Edit with sudoedit"
+	menuSystemAnyway   = "Open anyway"
+)
+
+// systemDirs - каталоги системы: файлы в них защищаются, даже если
+// принадлежат не root, а системному пользователю
+var systemDirs = []string{"/etc", "/usr", "/boot", "/opt", "/srv", "/var", "/bin", "/sbin", "/lib", "/lib64"}
+
+// readOnlyEditorFlags - флаги редакторов, открывающие файл только для чтения
+var readOnlyEditorFlags = map[string][]string{
+	"vi": {"-R"}, "vim": {"-R"}, "nvim": {"-R"}, "gvim": {"-R"}, "mvim": {"-R"},
+	"nano":  {"-v"},
+	"kak":   {"-ro"},
+	"micro": {"-readonly", "true"},
+}
+
+// checkSystemFiles проверяет значение ключа system_files
+func checkSystemFiles(value string) error {
+	switch value {
+	case "", systemFilesOff, systemFilesReadOnly, systemFilesSudoedit, systemFilesAsk:
+		return nil
+	}
+	return fmt.Errorf("system_files: unknown value %q (expected %s, %s, %s or %s)",
+		value, systemFilesReadOnly, systemFilesSudoedit, systemFilesAsk, systemFilesOff)
+}
+
+// isSystemFile сообщает, что файл лежит в системном каталоге или принадлежит
+// root и текущий пользователь не может его записать
+func isSystemFile(filePath string) bool {
+	fi, err := os.Stat(filePath)
+	if err != nil || !fi.Mode().IsRegular() || canWrite(filePath) {
+		return false
+	}
+	if isOwnedByRoot(fi) {
+		return true
+	}
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return false
+	}
+	for _, dir := range systemDirs {
+		if strings.HasPrefix(abs, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// guardSystemFile применяет system_files к кандидатам на открытие системного
+// файла в текстовом редакторе: отмечает их как только для чтения или ставит
+// впереди sudoedit. ok == false - открытие отменено в меню.
+func guardSystemFile(filePath string, specs []launchSpec) (result []launchSpec, ok bool) {
+	policy := defaultConfig.SystemFiles
+	if policy == "" || policy == systemFilesOff || kioskMode || !isSystemFile(filePath) {
+		return specs, true
+	}
+	editor := false
+	for _, spec := range specs {
+		editor = editor || spec.Key == assocTextEditor
+	}
+	if !editor {
+		return specs, true
+	}
+
+	if policy == systemFilesAsk {
+		policy = systemFilesReadOnly
+		if isInteractive() {
+			choice, err := fzfMenu(fmt.Sprintf("%s is a system file> ", filepath.Base(filePath)), false,
+				menuSystemReadOnly, menuSystemSudoedit, menuSystemAnyway, menuCancel)
+			switch {
+			case err != nil || choice == "" || choice == menuCancel:
+				return nil, false
+			case choice == menuSystemSudoedit:
+				policy = systemFilesSudoedit
+			case choice == menuSystemAnyway:
+				return specs, true
+			}
+		}
+	}
+
+	if policy == systemFilesSudoedit {
+		if _, err := cachedLookPath("sudoedit"); err == nil {
+			// sudoedit спрашивает пароль, поэтому работает в терминале; редактор
+			// он берёт из SUDO_EDITOR, VISUAL или EDITOR
+			fmt.Fprintf(logOut, "Info: %q is a system file, editing it with sudoedit\n", filePath)
+			return append([]launchSpec{{Key: assocTextEditor, Command: "sudoedit", TUI: true}}, specs...), true
+		}
+		fmt.Fprintf(logOut, "Warning: sudoedit is not installed, opening %q read-only\n", filePath)
+	}
+
+	fmt.Fprintf(logOut, "Info: %q is a system file, opening it read-only\n", filePath)
+	result = make([]launchSpec, len(specs))
+	for i, spec := range specs {
+		spec.ReadOnly = spec.Key == assocTextEditor
+		result[i] = spec
+	}
+	return result, true
+}
+
+// readOnlyFlags возвращает флаги, открывающие файл в редакторе только для
+// чтения; nil - редактор таких флагов не знает
+func readOnlyFlags(appPath string) []string {
+	return readOnlyEditorFlags[strings.TrimSuffix(filepath.Base(appPath), ".exe")]
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Пункты меню для unit-файла systemd
+const (
+	menuUnitEdit   = "Edit with systemctl edit"
+	menuUnitStatus = "Show status"
+	menuUnitRaw    = "Open unit file"
+)
+
+// systemdUnitExts - расширения unit-файлов, для которых предлагается меню
+var systemdUnitExts = map[string]bool{
+	"service": true, "timer": true, "socket": true, "path": true,
+	"mount": true, "automount": true, "target": true,
+}
+
+// Каталоги unit-файлов системного и пользовательского менеджера systemd
+var (
+	systemdSystemDirs = []string{"/etc/systemd/system", "/run/systemd/system", "/usr/lib/systemd/system", "/lib/systemd/system"}
+	systemdUserDirs   = []string{"/etc/systemd/user", "/run/systemd/user", "/usr/lib/systemd/user", "/lib/systemd/user"}
+)
+
+// systemdUnitScope определяет, к какому менеджеру относится unit-файл:
+// user - пользовательский (systemctl --user), ok == false - файл лежит вне
+// каталогов systemd
+func systemdUnitScope(filePath string) (user bool, ok bool) {
+	userDirs := systemdUserDirs
+	if dir, err := expandPath("~/.config/systemd/user"); err == nil {
+		userDirs = append([]string{dir}, userDirs...)
+	}
+	if config := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(config) {
+		userDirs = append([]string{filepath.Join(config, "systemd", "user")}, userDirs...)
+	}
+
+	for _, dir := range userDirs {
+		if strings.HasPrefix(filePath, dir+string(filepath.Separator)) {
+			return true, true
+		}
+	}
+	for _, dir := range systemdSystemDirs {
+		if strings.HasPrefix(filePath, dir+string(filepath.Separator)) {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// offerSystemdUnit предлагает для unit-файла systemd редактирование через
+// systemctl edit (изменения попадают в drop-in и переживают обновление пакета),
+// статус unit или обычное открытие файла. Возвращает true, если файл обработан.
+func offerSystemdUnit(ctx context.Context, filePath string, ext string) (bool, error) {
+	if !systemdUnitExts[ext] || kioskMode || !isInteractive() {
+		return false, nil
+	}
+	user, ok := systemdUnitScope(filePath)
+	if !ok {
+		return false, nil
+	}
+	if _, err := cachedLookPath("systemctl"); err != nil {
+		return false, nil
+	}
+
+	unit := filepath.Base(filePath)
+	choice, err := fzfMenu(unit+"> ", false, menuUnitEdit, menuUnitStatus, menuUnitRaw, menuCancel)
+	if err != nil || choice == "" || choice == menuCancel {
+		return true, nil
+	}
+
+	var argv []string
+	switch choice {
+	case menuUnitEdit:
+		argv = systemctlArgv(user, true, "edit", unit)
+	case menuUnitStatus:
+		argv = systemctlArgv(user, false, "status", unit)
+	default:
+		return false, nil
+	}
+
+	spec := launchSpec{Command: strings.Join(argv, " "), Argv: argv, TUI: true}
+	if err := launchFirst(ctx, filePath, spec); err != nil {
+		return true, fmt.Errorf("%s failed: %w", spec.Command, err)
+	}
+	return true, nil
+}
+
+// systemctlArgv возвращает команду systemctl для unit. Изменять системные
+// unit может только root, поэтому для них обычный пользователь получает sudo.
+func systemctlArgv(user bool, modifies bool, args ...string) []string {
+	if user {
+		return append([]string{"systemctl", "--user"}, args...)
+	}
+	argv := append([]string{"systemctl"}, args...)
+	if modifies && os.Geteuid() != 0 {
+		if _, err := cachedLookPath("sudo"); err == nil {
+			argv = append([]string{"sudo"}, argv...)
+		}
+	}
+	return argv
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// rootTabs - вкладки с корнями в режиме --loop: клавиша NextRootKey переключает
+// корень, а запрос для каждого корня запоминается до конца сессии
+type rootTabs struct {
+	roots   []string
+	queries []string
+	current int
+	key     string
+}
+
+// newRootTabs создаёт вкладки из начального каталога и настроенных roots.
+// Возвращает nil, если переключаться не между чем.
+func newRootTabs(cfg *Config) *rootTabs {
+	if len(defaultConfig.Roots) == 0 || defaultConfig.NextRootKey == "" || accessibleMode {
+		return nil
+	}
+
+	t := &rootTabs{key: defaultConfig.NextRootKey}
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if dir == "" || seen[dir] {
+			return
+		}
+		seen[dir] = true
+		t.roots = append(t.roots, dir)
+	}
+
+	add(cfg.StartingDir)
+	for _, root := range defaultConfig.Roots {
+		dir, err := expandPath(root)
+		if err != nil {
+			fmt.Fprintf(logOut, "Warning: skipping root %q: %v\n", root, err)
+			continue
+		}
+		if !kioskAllowed(dir) {
+			fmt.Fprintf(logOut, "Warning: skipping root %q outside kiosk_roots\n", root)
+			continue
+		}
+		add(dir)
+	}
+
+	if len(t.roots) < 2 {
+		return nil
+	}
+	t.queries = make([]string, len(t.roots))
+	return t
+}
+
+func (t *rootTabs) dir() string {
+	return t.roots[t.current]
+}
+
+func (t *rootTabs) next() {
+	t.current = (t.current + 1) % len(t.roots)
+}
+
+func (t *rootTabs) saveQuery(query string) {
+	t.queries[t.current] = query
+}
+
+// pickerOptions возвращает параметры fzf для текущей вкладки: сохранённый запрос,
+// заголовок со списком корней и клавишу переключения
+func (t *rootTabs) pickerOptions() pickerOptions {
+	labels := make([]string, len(t.roots))
+	for i, root := range t.roots {
+		label := rootLabel(root)
+		if i == t.current {
+			label = "[" + label + "]"
+		}
+		labels[i] = label
+	}
+
+	return pickerOptions{
+		Query:      t.queries[t.current],
+		Header:     fmt.Sprintf("%s: next root │ %s", t.key, strings.Join(labels, " ")),
+		Expect:     []string{t.key},
+		PrintQuery: true,
+	}
+}
+
+// rootLabel возвращает короткое имя корня для заголовка fzf
+func rootLabel(dir string) string {
+	if dir == userHomeDir {
+		return "~"
+	}
+	return filepath.Base(dir)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lineSuffixPattern - суффикс ":строка[:столбец][:текст]" в выводе grep -n и rg --vimgrep
+var lineSuffixPattern = regexp.MustCompile(`^(.+?):(\d+)(?::\d+)?(?::.*)?$`)
+
+// splitLineSuffix отделяет от пути номер строки, если сам путь не существует,
+// а путь без суффикса - существует
+func splitLineSuffix(path string) (string, int, bool) {
+	m := lineSuffixPattern.FindStringSubmatch(path)
+	if m == nil {
+		return "", 0, false
+	}
+	if _, err := os.Stat(m[1]); err != nil {
+		return "", 0, false
+	}
+	line, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return m[1], line, true
+}
+
+// expandCommandTemplate подставляет в аргументы команды ассоциации {file},
+// {dir}, {name}, {ext} и {line}. Второе значение сообщает, был ли в аргументах
+// хоть один заполнитель; если нет, путь к файлу добавляется в конец, как раньше.
+// Аргументы уже разделены, поэтому пути с пробелами в кавычках не нуждаются.
+func expandCommandTemplate(args []string, filePath string, line int) ([]string, bool) {
+	if line <= 0 {
+		line = 1
+	}
+	replacer := strings.NewReplacer(
+		"{file}", filePath,
+		"{dir}", filepath.Dir(filePath),
+		"{name}", filepath.Base(filePath),
+		"{ext}", strings.TrimPrefix(filepath.Ext(filePath), "."),
+		"{line}", strconv.Itoa(line),
+	)
+
+	expanded := make([]string, len(args))
+	changed := false
+	for i, arg := range args {
+		expanded[i] = replacer.Replace(arg)
+		if expanded[i] != arg {
+			changed = true
+		}
+	}
+	return expanded, changed
+}
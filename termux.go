@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// isTermux сообщает, запущен ли fzf-open в Termux (Android). Там нет эмулятора
+// терминала, который можно открыть новым окном, а файлы открываются
+// приложениями Android через termux-open.
+func isTermux() bool {
+	return os.Getenv("TERMUX_VERSION") != "" || strings.Contains(os.Getenv("PREFIX"), "/com.termux/")
+}
@@ -0,0 +1,23 @@
+//go:build !unix && !windows
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// openTTY - встроенному выбору здесь неоткуда читать клавиши
+func openTTY() (io.ReadWriter, func(), error) {
+	return nil, nil, errors.New("no terminal support on this platform")
+}
+
+// ttySize возвращает размер терминала по умолчанию
+func ttySize() (rows, cols int) {
+	return 24, 80
+}
+
+// openLineTTY - построчному выбору здесь тоже неоткуда читать ввод
+func openLineTTY() (io.ReadWriter, func(), error) {
+	return nil, nil, errors.New("no terminal support on this platform")
+}
@@ -0,0 +1,76 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// selectTTY - терминал встроенного выбора
+var selectTTY *os.File
+
+// openTTY открывает управляющий терминал и переводит его в неканонический
+// режим без эха. stty есть в любой Unix-системе, поэтому системные вызовы
+// termios, которые различаются между ними, не нужны.
+func openTTY() (*os.File, func(), error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		tty.Close()
+		return nil, nil, err
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		tty.Close()
+		return nil, nil, err
+	}
+	selectTTY = tty
+
+	var once sync.Once
+	return tty, func() {
+		once.Do(func() {
+			stty(tty, strings.TrimSpace(saved))
+			tty.Close()
+		})
+	}, nil
+}
+
+// ttySize возвращает число строк и столбцов терминала выбора
+func ttySize() (rows, cols int) {
+	if selectTTY != nil {
+		if out, err := stty(selectTTY, "size"); err == nil {
+			if fields := strings.Fields(out); len(fields) == 2 {
+				rows, _ = strconv.Atoi(fields[0])
+				cols, _ = strconv.Atoi(fields[1])
+			}
+		}
+	}
+	if rows <= 0 || cols <= 0 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+// stty выполняет stty для терминала tty
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// openLineTTY открывает управляющий терминал в обычном построчном режиме:
+// ввод читается строками с эхом, как в шелле
+func openLineTTY() (*os.File, func(), error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tty, func() { tty.Close() }, nil
+}
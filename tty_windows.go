@@ -0,0 +1,126 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Режимы консоли Windows для встроенного выбора
+const (
+	enableProcessedInput            = 0x0001
+	enableLineInput                 = 0x0002
+	enableEchoInput                 = 0x0004
+	enableVirtualTerminalInput      = 0x0200
+	enableProcessedOutput           = 0x0001
+	enableVirtualTerminalProcessing = 0x0004
+)
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleMode             = kernel32.NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+)
+
+// consoleTTY - консоль встроенного выбора: ввод из CONIN$, вывод в CONOUT$
+type consoleTTY struct {
+	in, out *os.File
+}
+
+func (t consoleTTY) Read(p []byte) (int, error)  { return t.in.Read(p) }
+func (t consoleTTY) Write(p []byte) (int, error) { return t.out.Write(p) }
+
+// selectConsole - консоль встроенного выбора
+var selectConsole *consoleTTY
+
+// openTTY открывает консоль и включает в ней последовательности VT: клавиши
+// приходят так же, как в терминалах Unix, а вывод понимает escape-коды
+func openTTY() (*consoleTTY, func(), error) {
+	in, err := os.OpenFile("CONIN$", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := os.OpenFile("CONOUT$", os.O_RDWR, 0)
+	if err != nil {
+		in.Close()
+		return nil, nil, err
+	}
+
+	var inMode, outMode uint32
+	inHandle, outHandle := syscall.Handle(in.Fd()), syscall.Handle(out.Fd())
+	if err := syscall.GetConsoleMode(inHandle, &inMode); err != nil {
+		in.Close()
+		out.Close()
+		return nil, nil, err
+	}
+	syscall.GetConsoleMode(outHandle, &outMode)
+
+	raw := inMode&^(enableProcessedInput|enableLineInput|enableEchoInput) | enableVirtualTerminalInput
+	if err := setConsoleMode(inHandle, raw); err != nil {
+		in.Close()
+		out.Close()
+		return nil, nil, err
+	}
+	setConsoleMode(outHandle, outMode|enableProcessedOutput|enableVirtualTerminalProcessing)
+
+	tty := &consoleTTY{in: in, out: out}
+	selectConsole = tty
+	var once sync.Once
+	return tty, func() {
+		once.Do(func() {
+			setConsoleMode(inHandle, inMode)
+			setConsoleMode(outHandle, outMode)
+			in.Close()
+			out.Close()
+		})
+	}, nil
+}
+
+// setConsoleMode вызывает SetConsoleMode, которой нет в пакете syscall
+func setConsoleMode(handle syscall.Handle, mode uint32) error {
+	if ok, _, err := procSetConsoleMode.Call(uintptr(handle), uintptr(mode)); ok == 0 {
+		return err
+	}
+	return nil
+}
+
+// ttySize возвращает число строк и столбцов видимой области консоли
+func ttySize() (rows, cols int) {
+	var info struct {
+		size, cursor             [2]int16
+		attributes               uint16
+		left, top, right, bottom int16
+		maxSize                  [2]int16
+	}
+	if selectConsole != nil {
+		ok, _, _ := procGetConsoleScreenBufferInfo.Call(selectConsole.out.Fd(), uintptr(unsafe.Pointer(&info)))
+		if ok != 0 {
+			rows, cols = int(info.bottom-info.top)+1, int(info.right-info.left)+1
+		}
+	}
+	if rows <= 0 || cols <= 0 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+// openLineTTY открывает консоль в обычном построчном режиме: ввод читается
+// строками с эхом
+func openLineTTY() (*consoleTTY, func(), error) {
+	in, err := os.OpenFile("CONIN$", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := os.OpenFile("CONOUT$", os.O_RDWR, 0)
+	if err != nil {
+		in.Close()
+		return nil, nil, err
+	}
+	return &consoleTTY{in: in, out: out}, func() {
+		in.Close()
+		out.Close()
+	}, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// tuiAssociations - ассоциации из таблицы [tui], приложения которых работают
+// в терминале (nvim, ncdu, mpv --vo=tct)
+var tuiAssociations = map[string]bool{}
+
+// inTerminal возвращает команду, запускающую argv в новом окне терминала
+func inTerminal(argv []string) []string {
+	return terminalArgv(defaultConfig.Terminal, "", argv)
+}
+
+// terminalExecArgv - команда в стиле xterm: terminal [флаг заголовка title] -e argv
+func terminalExecArgv(terminal, title string, argv []string) []string {
+	cmd := []string{terminal}
+	if title != "" {
+		cmd = append(cmd, defaultConfig.WinTitleFlag, title)
+	}
+	return append(append(cmd, "-e"), argv...)
+}
+
+// runAttached запускает терминальное приложение в текущем терминале и ждёт его
+// завершения. Код возврата приложения ошибкой запуска не считается.
+func runAttached(spec launchSpec, appPath string, args []string, filePath string) bool {
+	cmd := execCommand(context.Background(), "attach", appPath, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			fmt.Fprintf(logOut, "Error starting application %q for file %q: %v\n", spec.Command, filePath, err)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// verboseMode - флаг -v: перед запуском приложения печатается, как оно выбрано
+var verboseMode bool
+
+// resolutionTrace - как openPath выбрал приложение для файла
+type resolutionTrace struct {
+	extension string // где нашлось расширение; пусто - промах
+	rule      string // сработавшее правило; пусто - ни одно
+}
+
+// printResolution печатает в stderr цепочку выбора приложения деревом:
+// расширение → MIME-тип и откуда он взят → правило → приложения → fallback_opener
+func printResolution(filePath string, info FileTypeInfo, trace resolutionTrace, appKey string, specs []launchSpec) {
+	// Правило с ключом ассоциации тоже говорит о виде файла
+	kindKey := appKey
+	for _, spec := range specs {
+		if kindKey == "" && spec.Key != "" && spec.Key != assocFallbackOpener {
+			kindKey = spec.Key
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  [%s]\n", filePath, fileKindBadge(kindKey, info.MIMEType))
+
+	switch {
+	case info.Ext == "":
+		treeLine(&b, false, "extension", "none")
+	case trace.extension != "":
+		treeLine(&b, false, "extension", fmt.Sprintf(".%s: hit (%s)", info.Ext, trace.extension))
+	default:
+		treeLine(&b, false, "extension", fmt.Sprintf(".%s: miss", info.Ext))
+	}
+
+	if info.MIMEType != "" {
+		treeLine(&b, false, "mime", fmt.Sprintf("%s (%s)", info.MIMEType, mimeTypeSource(filePath)))
+	} else {
+		treeLine(&b, false, "mime", "not determined")
+	}
+
+	if trace.rule != "" {
+		treeLine(&b, false, "rule", trace.rule)
+	} else {
+		treeLine(&b, false, "rule", "none")
+	}
+
+	var apps, fallback []string
+	for _, spec := range specs {
+		if spec.Key == assocFallbackOpener {
+			fallback = append(fallback, specStatus(spec))
+		} else {
+			apps = append(apps, specStatus(spec))
+		}
+	}
+	switch {
+	case len(apps) == 0:
+		treeLine(&b, false, "app", "none")
+	case appKey != "" && trace.rule == "":
+		treeLine(&b, false, "app", appKey+": "+strings.Join(apps, ", "))
+	default:
+		treeLine(&b, false, "app", strings.Join(apps, ", "))
+	}
+	if len(fallback) == 0 {
+		fallback = []string{"none"}
+	}
+	treeLine(&b, true, "fallback", strings.Join(fallback, ", "))
+
+	fmt.Fprint(os.Stderr, b.String())
+}
+
+// treeLine добавляет ветку дерева printResolution
+func treeLine(b *strings.Builder, last bool, name, value string) {
+	branch := "├─"
+	if last {
+		branch = "└─"
+	}
+	fmt.Fprintf(b, "  %s %-9s %s\n", branch, name, value)
+}
+
+// fileKindBadge возвращает метку вида файла: по ассоциации (image_viewer ->
+// image), иначе по первой части MIME-типа
+func fileKindBadge(appKey, mimeType string) string {
+	if appKey != "" {
+		kind, _, _ := strings.Cut(appKey, "_")
+		return kind
+	}
+	if major, _, ok := strings.Cut(mimeType, "/"); ok && major != "" {
+		return major
+	}
+	return "file"
+}
+
+// mimeTypeSource сообщает, откуда getMimeType взял тип файла
+func mimeTypeSource(filePath string) string {
+	ext := filepath.Ext(filePath)
+	switch {
+	case ext != "" && extensionMIMETypes[normalizeExtension(ext)] != "":
+		return "[mime_types]"
+	case builtinExtensionMIME(ext) != "":
+		return "extension"
+	case sniffMimeType(filePath) != "":
+		return "file contents"
+	case mimeQueryTool != "":
+		return mimeQueryTool
+	}
+	return "system"
+}
+
+// specStatus описывает кандидата на запуск: команду и найдена ли она в PATH
+func specStatus(spec launchSpec) string {
+	argv := spec.argv()
+	if len(argv) == 0 {
+		return "(empty)"
+	}
+	if _, err := cachedLookPath(argv[0]); err != nil {
+		return argv[0] + " (not in PATH)"
+	}
+	return argv[0]
+}
+
+// describeFilenameRule описывает правило из [[rules]] его условиями и целью
+func describeFilenameRule(r FilenameRule) string {
+	var conds []string
+	for _, c := range []struct{ name, value string }{
+		{"glob", r.Glob}, {"regex", r.Regex}, {"mime", r.MIME},
+		{"min_size", r.MinSize}, {"max_size", r.MaxSize},
+	} {
+		if c.value != "" {
+			conds = append(conds, fmt.Sprintf("%s = %q", c.name, c.value))
+		}
+	}
+	desc := fmt.Sprintf("[[rules]] %s → %q", strings.Join(conds, ", "), r.Open)
+	if r.Backup {
+		desc += " (backup)"
+	}
+	return desc
+}
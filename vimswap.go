@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Пункты меню для файла, у которого уже есть swap-файл vim
+const (
+	menuSwapReadOnly = "Open read-only"
+	menuSwapRecover  = "Recover from swap file"
+	menuSwapAnyway   = "Edit anyway"
+)
+
+// vimEditors - редакторы семейства vim, которые создают swap-файлы
+var vimEditors = map[string]bool{
+	"vi": true, "vim": true, "nvim": true, "gvim": true, "mvim": true,
+}
+
+// isVimEditor сообщает, относится ли программа к семейству vim
+func isVimEditor(appPath string) bool {
+	return vimEditors[strings.TrimSuffix(filepath.Base(appPath), ".exe")]
+}
+
+// vimSwapFile возвращает swap-файл, оставленный для filePath другим экземпляром
+// vim или упавшим редактором, или пустую строку. Проверяются каталог файла
+// (.имя.swp - 'directory' по умолчанию в vim) и общие каталоги swap-файлов
+// vim и neovim, в которых имя swap-файла - полный путь с % вместо /.
+func vimSwapFile(filePath string) string {
+	dir, name := filepath.Split(filePath)
+	candidates := []string{
+		filepath.Join(dir, "."+name+".swp"),
+		filepath.Join(dir, "."+name+".swo"),
+	}
+
+	encoded := strings.ReplaceAll(filePath, string(filepath.Separator), "%") + ".swp"
+	if state, err := xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state")); err == nil {
+		state = filepath.Dir(state)
+		candidates = append(candidates,
+			filepath.Join(state, "nvim", "swap", encoded),
+			filepath.Join(state, "vim", "swap", encoded))
+	}
+	if home, err := expandPath("~"); err == nil && home != "" {
+		candidates = append(candidates, filepath.Join(home, ".vim", "swap", encoded))
+	}
+
+	for _, candidate := range candidates {
+		if fi, err := os.Stat(candidate); err == nil && fi.Mode().IsRegular() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// vimSwapFlags решает, как открыть файл со swap-файлом: в терминале
+// предлагается выбор, иначе файл открывается только для чтения, чтобы в новом
+// окне не появился запрос восстановления. ok == false - открытие отменено.
+func vimSwapFlags(filePath, swap string) (flags []string, ok bool) {
+	// -n: без своего swap-файла vim не проверяет чужой и не спрашивает о нём
+	readOnly := []string{"-R", "-n"}
+	if !isInteractive() {
+		fmt.Fprintf(logOut, "Warning: %q has a swap file %q, opening it read-only\n", filePath, swap)
+		return readOnly, true
+	}
+
+	choice, err := fzfMenu(fmt.Sprintf("%s is open elsewhere or was not saved> ", filepath.Base(filePath)), false,
+		menuSwapReadOnly, menuSwapRecover, menuSwapAnyway, menuCancel)
+	switch {
+	case err != nil || choice == "" || choice == menuCancel:
+		return nil, false
+	case choice == menuSwapReadOnly:
+		return readOnly, true
+	case choice == menuSwapRecover:
+		return []string{"-r"}, true
+	}
+	return nil, true
+}
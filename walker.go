@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFileNames - файлы со списками исключений в стиле .gitignore, которые
+// учитывает встроенный обходчик (как fd и rg)
+var ignoreFileNames = []string{".gitignore", ".ignore"}
+
+// ignoreRule - одна строка .gitignore
+type ignoreRule struct {
+	base    string // каталог файла исключений относительно корня обхода
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// walkOptions - параметры встроенного обходчика
+type walkOptions struct {
+	hidden   bool     // показывать скрытые файлы и каталоги
+	noIgnore bool     // не учитывать .gitignore и .ignore
+	dirs     bool     // перечислять каталоги вместо файлов
+	exclude  []string // шаблоны ignore из конфигурации
+	sort     string   // порядок --sort; пусто - порядок обхода
+}
+
+// runFiles реализует подкоманду files - встроенный обходчик для source =
+// "walk": печатает файлы текущего каталога относительными путями
+func runFiles(args []string) int {
+	fset := flag.NewFlagSet("files", flag.ExitOnError)
+	var opts walkOptions
+	fset.BoolVar(&opts.hidden, "hidden", false, "Include hidden files")
+	fset.BoolVar(&opts.noIgnore, "no-ignore", false, "Do not respect .gitignore and .ignore")
+	fset.BoolVar(&opts.dirs, "dirs", false, "List directories instead of files")
+	fset.Func("exclude", "Skip files and directories matching this pattern (repeatable)", func(s string) error {
+		opts.exclude = append(opts.exclude, s)
+		return nil
+	})
+	fset.StringVar(&opts.sort, "sort", "", "Sort by name, mtime (newest first) or size (largest first)")
+	null := fset.Bool("0", false, "Separate paths with NUL instead of newline")
+	fset.Parse(args)
+	if err := checkSort(opts.sort); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	sep := byte('\n')
+	if *null {
+		sep = 0
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if err := walkSorted(".", opts, func(rel string) {
+		out.WriteString(rel)
+		out.WriteByte(sep)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// walkFiles обходит root и вызывает emit для каждого файла (с opts.dirs -
+// каждого каталога, кроме самого root) с путём от root.
+// Каталог .git пропускается всегда, символические ссылки на каталоги не
+// раскрываются. Ошибки доступа к отдельным каталогам не прерывают обход.
+func walkFiles(root string, opts walkOptions, emit func(rel string)) error {
+	var rules []ignoreRule
+	for _, pattern := range opts.exclude {
+		pattern = strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+		if strings.Contains(pattern, "/") {
+			pattern = "/" + strings.TrimPrefix(pattern, "/")
+		}
+		if rule, ok := parseIgnoreLine(pattern, ""); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, relErr := filepath.Rel(root, path)
+		if relErr != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." {
+			name := d.Name()
+			skip := name == ".git" || (!opts.hidden && strings.HasPrefix(name, ".")) ||
+				ignoredByRules(rules, rel, d.IsDir())
+			if skip {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		if d.IsDir() {
+			if !opts.noIgnore {
+				rules = append(rules, readIgnoreFiles(path, rel)...)
+			}
+			if opts.dirs && rel != "." {
+				emit(rel)
+			}
+			return nil
+		}
+		if !opts.dirs {
+			emit(rel)
+		}
+		return nil
+	})
+}
+
+// readIgnoreFiles читает .gitignore и .ignore каталога dir (rel - его путь от корня обхода)
+func readIgnoreFiles(dir, rel string) []ignoreRule {
+	if rel == "." {
+		rel = ""
+	}
+	var rules []ignoreRule
+	for _, name := range ignoreFileNames {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreLine(scanner.Text(), rel); ok {
+				rules = append(rules, rule)
+			}
+		}
+		f.Close()
+	}
+	return rules
+}
+
+// parseIgnoreLine разбирает строку в формате .gitignore: "!" отменяет
+// исключение, "/" в конце - только каталоги, "/" в начале или середине
+// привязывает шаблон к каталогу файла, "**" - любое число каталогов
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	expr := globToRegexp(line)
+	if !anchored {
+		// Шаблон без "/" совпадает с именем на любой глубине
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp переводит шаблон .gitignore в регулярное выражение
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// ignoredByRules сообщает, исключён ли путь: решает последнее подошедшее правило
+func ignoredByRules(rules []ignoreRule, rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		path := rel
+		if rule.base != "" {
+			var ok bool
+			if path, ok = strings.CutPrefix(rel, rule.base+"/"); !ok {
+				continue
+			}
+		}
+		if rule.re.MatchString(path) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
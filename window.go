@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// windowPollInterval - как часто проверять появление окна и завершение fzf
+const windowPollInterval = 100 * time.Millisecond
+
+// terminalHold не даёт терминалу, запущенному флагом -n, закрыться сразу после
+// выбора файла: скрипт в терминале ждёт, пока существует holdPath
+type terminalHold struct {
+	holdPath string
+	donePath string
+
+	// detached - команда терминала завершается сразу после открытия окна
+	// (osascript в macOS), конец выбора определяется только по donePath
+	detached bool
+}
+
+func newTerminalHold(statePath string, detached bool) (*terminalHold, error) {
+	h := &terminalHold{holdPath: statePath + ".hold", donePath: statePath + ".done", detached: detached}
+	os.Remove(h.donePath)
+	if err := createPrivateFile(h.holdPath); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// script возвращает хвост команды для терминала. Он выполняется через sh,
+// поэтому не зависит от оболочки пользователя.
+func (h *terminalHold) script() string {
+	return `; sh -c 'touch "$1"; while [ -e "$2" ]; do sleep 0.1; done' sh ` +
+		shellQuote(h.donePath) + " " + shellQuote(h.holdPath)
+}
+
+// run запускает терминал и возвращается, когда fzf в нём завершился,
+// не дожидаясь закрытия самого терминала
+func (h *terminalHold) run(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		h.release()
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	ticker := time.NewTicker(pollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-exited:
+			if h.detached && err == nil {
+				exited = nil
+				continue
+			}
+			h.release()
+			return err
+		case <-ctx.Done():
+			h.release()
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := os.Stat(h.donePath); err == nil {
+				return nil
+			}
+		}
+	}
+}
+
+// release позволяет терминалу закрыться
+func (h *terminalHold) release() {
+	os.Remove(h.holdPath)
+	os.Remove(h.donePath)
+}
+
+// compositorAvailable сообщает, запущен ли композитор (задана его переменная
+// окружения env) и установлена ли его утилита name
+func compositorAvailable(env, name string) bool {
+	if os.Getenv(env) == "" {
+		return false
+	}
+	_, err := cachedLookPath(name)
+	return err == nil
+}
+
+// windowProbe сообщает, есть ли у процесса окно
+type windowProbe func(pid int) bool
+
+// detectWindowProbe выбирает способ опросить оконный менеджер.
+// Возвращает nil, если подходящей утилиты нет.
+func detectWindowProbe() windowProbe {
+	contains := func(name string, args ...string) windowProbe {
+		return func(pid int) bool {
+			out, err := execCommand(context.Background(), "window", name, args...).Output()
+			return err == nil && bytes.Contains(out, []byte(`"pid": `+strconv.Itoa(pid)))
+		}
+	}
+
+	switch {
+	case compositorAvailable("HYPRLAND_INSTANCE_SIGNATURE", "hyprctl"):
+		return contains("hyprctl", "clients", "-j")
+	case compositorAvailable("SWAYSOCK", "swaymsg"):
+		return contains("swaymsg", "-t", "get_tree")
+	case compositorAvailable("DISPLAY", "xdotool"):
+		return func(pid int) bool {
+			return execCommand(context.Background(), "window", "xdotool", "search", "--pid", strconv.Itoa(pid)).Run() == nil
+		}
+	}
+	return nil
+}
+
+// waitForWindow ждёт, пока одно из запущенных приложений создаст окно, но не
+// дольше timeout. Если опросить оконный менеджер нечем или приложение
+// передало файл другому процессу и завершилось, просто выжидает timeout.
+func waitForWindow(procs []*launchedProcess, timeout time.Duration) {
+	deadline := time.After(timeout)
+	probe := detectWindowProbe()
+	if probe == nil || len(procs) == 0 {
+		<-deadline
+		return
+	}
+
+	ticker := time.NewTicker(pollInterval())
+	defer ticker.Stop()
+	for {
+		for _, p := range procs {
+			if p.running() && probe(p.cmd.Process.Pid) {
+				return
+			}
+		}
+
+		select {
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
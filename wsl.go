@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// wslpathTimeout ограничивает перевод пути программой wslpath
+const wslpathTimeout = time.Second
+
+// wslHasDisplay сообщает, может ли WSL показывать окна Linux-приложений (WSLg)
+func wslHasDisplay() bool {
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// isWindowsProgram сообщает, является ли программа исполняемым файлом Windows,
+// запускаемым через interop WSL
+func isWindowsProgram(appPath string) bool {
+	return strings.HasSuffix(strings.ToLower(appPath), ".exe")
+}
+
+// wslSkipNative сообщает, что графическое Linux-приложение в WSL без WSLg
+// открыть негде и файл нужно передать Windows. Терминальные приложения
+// запускаются как обычно.
+func wslSkipNative(appPath string, tui bool) bool {
+	if !isWSL() || wslHasDisplay() || tui {
+		return false
+	}
+	return !isWindowsProgram(appPath) && filepath.Base(appPath) != "wslview"
+}
+
+// wslHostPath переводит путь к файлу в путь Windows (wslpath -w) для программ
+// Windows; остальным путь передаётся без изменений
+func wslHostPath(appPath, filePath string) string {
+	if !isWSL() || !isWindowsProgram(appPath) {
+		return filePath
+	}
+
+	wslpath, err := cachedLookPath("wslpath")
+	if err != nil {
+		return filePath
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wslpathTimeout)
+	defer cancel()
+	out, err := execCommand(ctx, "wslpath", wslpath, "-w", filePath).Output()
+	if err != nil {
+		return filePath
+	}
+	if hostPath := strings.TrimRight(string(out), "\r\n"); hostPath != "" {
+		return hostPath
+	}
+	return filePath
+}
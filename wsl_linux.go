@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// isWSL сообщает, запущен ли fzf-open в WSL: ядро WSL упоминает Microsoft в
+// /proc/version
+var isWSL = sync.OnceValue(func() bool {
+	data, err := os.ReadFile("/proc/version")
+	return err == nil && strings.Contains(strings.ToLower(string(data)), "microsoft")
+})
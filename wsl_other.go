@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// isWSL - WSL бывает только в Linux
+func isWSL() bool {
+	return false
+}